@@ -18,17 +18,20 @@ import (
 	"github.com/keshon/melodix-player/internal/manager"
 	"github.com/keshon/melodix-player/internal/rest"
 	"github.com/keshon/melodix-player/internal/version"
+	"github.com/keshon/melodix-player/mods/music/watcher"
 )
 
 func main() {
 	initLogger()
 	config := loadConfig()
-	initDatabase()
-	startCron()
+	watchConfig()
+	repos := initDatabase(config.DatabaseURL)
+	startCron(repos)
 	discordSession := createDiscordSession(config.DiscordBotToken)
-	bots := startBotHandlers(discordSession)
+	bots := startBotHandlers(discordSession, repos)
 	handleDiscordSession(discordSession)
-	startRestServer(config, bots)
+	startFileWatcher(config, discordSession, repos)
+	startRestServer(config, bots, repos)
 	slog.Infof("%v is now running. Press Ctrl+C to exit", version.AppFullName)
 	waitForExitSignal()
 }
@@ -71,16 +74,23 @@ func loadConfig() *config.Config {
 	return cfg
 }
 
-func initDatabase() {
-	_, err := db.InitDB("./database.db")
+func watchConfig() {
+	if _, err := config.Watch(); err != nil {
+		slog.Error("Error watching config files, hot reload is disabled", err)
+	}
+}
+
+func initDatabase(databaseURL string) *db.Repositories {
+	database, err := db.InitDB(databaseURL)
 	if err != nil {
 		slog.Fatal("Error initializing the database", err)
 		os.Exit(1)
 	}
+	return db.NewGormRepositories(database)
 }
 
-func startCron() {
-	cron := cron.NewCron()
+func startCron(repos *db.Repositories) {
+	cron := cron.NewCron(repos)
 	cron.Start()
 }
 
@@ -94,10 +104,10 @@ func createDiscordSession(token string) *discordgo.Session {
 	return session
 }
 
-func startBotHandlers(session *discordgo.Session) map[string]map[string]botsdef.Discord {
+func startBotHandlers(session *discordgo.Session, repos *db.Repositories) map[string]map[string]botsdef.Discord {
 	bots := make(map[string]map[string]botsdef.Discord)
 
-	guildIDs, err := db.GetAllGuildIDs()
+	guildIDs, err := repos.Guilds.GetAllIDs()
 	if err != nil {
 		log.Fatal("Error retrieving or creating guilds", err)
 	}
@@ -105,7 +115,7 @@ func startBotHandlers(session *discordgo.Session) map[string]map[string]botsdef.
 	for _, id := range guildIDs {
 		bots[id] = make(map[string]botsdef.Discord)
 
-		prefix, err := db.GetGuildPrefix(id)
+		prefix, err := repos.Guilds.GetPrefix(id)
 		if err != nil {
 			log.Fatal("Error retrieving prefix for the guilds", err)
 		}
@@ -115,7 +125,7 @@ func startBotHandlers(session *discordgo.Session) map[string]map[string]botsdef.
 		}
 
 		for _, module := range botsdef.Modules {
-			botInstance := botsdef.CreateBotInstance(session, module)
+			botInstance := botsdef.CreateBotInstance(session, module, repos)
 			if botInstance != nil {
 				bots[id][module] = botInstance
 				botInstance.Start(id, prefix)
@@ -123,7 +133,7 @@ func startBotHandlers(session *discordgo.Session) map[string]map[string]botsdef.
 		}
 	}
 
-	guildManager := manager.NewGuildManager(session, bots)
+	guildManager := manager.NewGuildManager(session, bots, repos)
 	guildManager.Start()
 
 	return bots
@@ -137,7 +147,16 @@ func handleDiscordSession(discordSession *discordgo.Session) {
 	defer discordSession.Close()
 }
 
-func startRestServer(config *config.Config, bots map[string]map[string]botsdef.Discord) {
+func startFileWatcher(config *config.Config, session *discordgo.Session, repos *db.Repositories) {
+	if !config.WatcherEnabled {
+		return
+	}
+	if err := watcher.NewWatcher(session, repos).Start(); err != nil {
+		slog.Error("Error starting the folder watcher", err)
+	}
+}
+
+func startRestServer(config *config.Config, bots map[string]map[string]botsdef.Discord, repos *db.Repositories) {
 	if !config.RestEnabled {
 		return
 	}
@@ -145,7 +164,7 @@ func startRestServer(config *config.Config, bots map[string]map[string]botsdef.D
 		gin.SetMode("release")
 	}
 	router := gin.Default()
-	restAPI := rest.NewRest(bots)
+	restAPI := rest.NewRest(bots, repos)
 	restAPI.Start(router)
 	go func() {
 		if len(config.RestHostname) == 0 {
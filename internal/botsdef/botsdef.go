@@ -3,6 +3,7 @@ package botsdef
 import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/gookit/slog"
+	"github.com/keshon/melodix-player/internal/db"
 	aboutModule "github.com/keshon/melodix-player/mods/about/discord"
 	musicModule "github.com/keshon/melodix-player/mods/music/discord"
 )
@@ -14,12 +15,12 @@ type Discord interface {
 
 var Modules = []string{"aboutModule", "musicModule"}
 
-func CreateBotInstance(session *discordgo.Session, module string) Discord {
+func CreateBotInstance(session *discordgo.Session, module string, repos *db.Repositories) Discord {
 	switch module {
 	case "aboutModule":
 		return aboutModule.NewDiscord(session)
 	case "musicModule":
-		return musicModule.NewDiscord(session)
+		return musicModule.NewDiscord(session, repos)
 
 	// ..add more cases for other modules if needed
 
@@ -3,11 +3,19 @@ package config
 import (
 	"encoding/json"
 	"errors"
-	"log/slog"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
 	"github.com/keshon/melodix-player/mods/music/third_party/dca"
 )
 
@@ -16,6 +24,7 @@ type Config struct {
 	DiscordBotToken            string
 	DiscordAdminUserID         string
 	DiscordAdminChannelID      string
+	DatabaseURL                string
 	RestEnabled                bool
 	RestGinRelease             bool
 	RestHostname               string
@@ -35,53 +44,346 @@ type Config struct {
 	DcaFfmpegBinaryPath        string
 	DcaEncodingLineLog         bool
 	DcaUserAgent               string
+	LibraryDir                 string
+	AttachmentsMaxSizeMB       int
+	AttachmentsFormats         string
+	YoutubeResolver            string
+	YoutubeYtDlpPath           string
+	SubsonicURL                string
+	SubsonicUser               string
+	SubsonicPassword           string
+	RadioDirectoryURL          string
+	AutoCacheEnabled           bool
+	AutoCacheMinPlays          int
+	AutoCacheMaxSizeMB         int
+	PrecodeEnabled             bool
+	PrecodeDir                 string
+	JobsWorkers                int
+	WatcherEnabled             bool
+	WatcherUploadGuildID       string
+	UploadCodec                string
+	UploadBitrate              int
+	StorageBackend             string
+	StorageCacheDir            string
+	StorageUploadDir           string
+	StorageS3Endpoint          string
+	StorageS3Region            string
+	StorageS3Bucket            string
+	StorageS3AccessKey         string
+	StorageS3SecretKey         string
+	StorageS3PathStyle         bool
+	StorageS3PresignMinutes    int
+	QuotaCacheMB               int
+	QuotaCacheFiles            int
+	QuotaUploadMB              int
+	RetentionUnplayedDays      int
+	RetentionKeepTop           int
+}
+
+// DefaultFiles are looked up in the working directory when CONFIG_FILE is not set.
+var DefaultFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+const dotenvFile = ".env"
+
+// field maps a Config member to its config file key and environment variable.
+type field struct {
+	key     string   // dotted key in the config file
+	env     string   // environment variable overriding the file
+	aliases []string // alternative environment variable names
+	secret  bool     // redacted in String() and never hot-reloaded
+	restart bool     // read once at startup, changes need a restart
+	boolInt bool     // int member holding a boolean (1 - true, 0 - false)
+	ptr     func(c *Config) any
+}
+
+var fields = []field{
+	{key: "discord.command_prefix", env: "DISCORD_COMMAND_PREFIX", restart: true, ptr: func(c *Config) any { return &c.DiscordCommandPrefix }},
+	{key: "discord.bot_token", env: "DISCORD_BOT_TOKEN", secret: true, ptr: func(c *Config) any { return &c.DiscordBotToken }},
+	{key: "discord.admin_user_id", env: "DISCORD_ADMIN_USER_ID", restart: true, ptr: func(c *Config) any { return &c.DiscordAdminUserID }},
+	{key: "discord.admin_channel_id", env: "DISCORD_ADMIN_CHANNEL_ID", aliases: []string{"DISCORD_ADMIN_LOG_CHANNEL_ID"}, ptr: func(c *Config) any { return &c.DiscordAdminChannelID }},
+	{key: "database.url", env: "DATABASE_URL", secret: true, ptr: func(c *Config) any { return &c.DatabaseURL }},
+	{key: "rest.enabled", env: "REST_ENABLED", restart: true, ptr: func(c *Config) any { return &c.RestEnabled }},
+	{key: "rest.gin_release", env: "REST_GIN_RELEASE", restart: true, ptr: func(c *Config) any { return &c.RestGinRelease }},
+	{key: "rest.hostname", env: "REST_HOSTNAME", restart: true, ptr: func(c *Config) any { return &c.RestHostname }},
+	{key: "dca.frame_duration", env: "DCA_FRAME_DURATION", ptr: func(c *Config) any { return &c.DcaFrameDuration }},
+	{key: "dca.bitrate", env: "DCA_BITRATE", ptr: func(c *Config) any { return &c.DcaBitrate }},
+	{key: "dca.packet_loss", env: "DCA_PACKET_LOSS", ptr: func(c *Config) any { return &c.DcaPacketLoss }},
+	{key: "dca.raw_output", env: "DCA_RAW_OUTPUT", ptr: func(c *Config) any { return &c.DcaRawOutput }},
+	{key: "dca.application", env: "DCA_APPLICATION", ptr: func(c *Config) any { return &c.DcaApplication }},
+	{key: "dca.compression_level", env: "DCA_COMPRESSION_LEVEL", ptr: func(c *Config) any { return &c.DcaCompressionLevel }},
+	{key: "dca.buffered_frames", env: "DCA_BUFFERED_FRAMES", ptr: func(c *Config) any { return &c.DcaBufferedFrames }},
+	{key: "dca.vbr", env: "DCA_VBR", ptr: func(c *Config) any { return &c.DcaVBR }},
+	{key: "dca.reconnect_at_eof", env: "DCA_RECONNECT_AT_EOF", boolInt: true, ptr: func(c *Config) any { return &c.DcaReconnectAtEOF }},
+	{key: "dca.reconnect_streamed", env: "DCA_RECONNECT_STREAMED", boolInt: true, ptr: func(c *Config) any { return &c.DcaReconnectStreamed }},
+	{key: "dca.reconnect_on_network_error", env: "DCA_RECONNECT_ON_NETWORK_ERROR", boolInt: true, ptr: func(c *Config) any { return &c.DcaReconnectOnNetworkError }},
+	{key: "dca.reconnect_on_http_error", env: "DCA_RECONNECT_ON_HTTTP_ERROR", aliases: []string{"DCA_RECONNECT_ON_HTTP_ERROR"}, ptr: func(c *Config) any { return &c.DcaReconnectOnHttpError }},
+	{key: "dca.reconnect_delay_max", env: "DCA_RECONNECT_MAX", ptr: func(c *Config) any { return &c.DcaReconnectDelayMax }},
+	{key: "dca.ffmpeg_binary_path", env: "DCA_FFMPEG_BINARY_PATH", ptr: func(c *Config) any { return &c.DcaFfmpegBinaryPath }},
+	{key: "dca.encoding_line_log", env: "DCA_ENCODING_LINE_LOG", ptr: func(c *Config) any { return &c.DcaEncodingLineLog }},
+	{key: "dca.user_agent", env: "DCA_USER_AGENT", ptr: func(c *Config) any { return &c.DcaUserAgent }},
+	{key: "library.dir", env: "LIBRARY_DIR", ptr: func(c *Config) any { return &c.LibraryDir }},
+	{key: "attachments.max_size_mb", env: "ATTACHMENTS_MAX_SIZE_MB", ptr: func(c *Config) any { return &c.AttachmentsMaxSizeMB }},
+	{key: "attachments.formats", env: "ATTACHMENTS_FORMATS", ptr: func(c *Config) any { return &c.AttachmentsFormats }},
+	{key: "youtube.resolver", env: "YOUTUBE_RESOLVER", ptr: func(c *Config) any { return &c.YoutubeResolver }},
+	{key: "youtube.ytdlp_path", env: "YOUTUBE_YTDLP_PATH", ptr: func(c *Config) any { return &c.YoutubeYtDlpPath }},
+	{key: "subsonic.url", env: "SUBSONIC_URL", ptr: func(c *Config) any { return &c.SubsonicURL }},
+	{key: "subsonic.user", env: "SUBSONIC_USER", ptr: func(c *Config) any { return &c.SubsonicUser }},
+	{key: "subsonic.password", env: "SUBSONIC_PASSWORD", secret: true, ptr: func(c *Config) any { return &c.SubsonicPassword }},
+	{key: "radio.directory_url", env: "RADIO_DIRECTORY_URL", ptr: func(c *Config) any { return &c.RadioDirectoryURL }},
+	{key: "autocache.enabled", env: "AUTOCACHE_ENABLED", ptr: func(c *Config) any { return &c.AutoCacheEnabled }},
+	{key: "autocache.min_plays", env: "AUTOCACHE_MIN_PLAYS", ptr: func(c *Config) any { return &c.AutoCacheMinPlays }},
+	{key: "autocache.max_size_mb", env: "AUTOCACHE_MAX_SIZE_MB", ptr: func(c *Config) any { return &c.AutoCacheMaxSizeMB }},
+	{key: "precode.enabled", env: "PRECODE_ENABLED", ptr: func(c *Config) any { return &c.PrecodeEnabled }},
+	{key: "precode.dir", env: "PRECODE_DIR", ptr: func(c *Config) any { return &c.PrecodeDir }},
+	{key: "jobs.workers", env: "JOBS_WORKERS", restart: true, ptr: func(c *Config) any { return &c.JobsWorkers }},
+	{key: "watcher.enabled", env: "WATCHER_ENABLED", restart: true, ptr: func(c *Config) any { return &c.WatcherEnabled }},
+	{key: "watcher.upload_guild_id", env: "WATCHER_UPLOAD_GUILD_ID", ptr: func(c *Config) any { return &c.WatcherUploadGuildID }},
+	{key: "upload.codec", env: "UPLOAD_CODEC", ptr: func(c *Config) any { return &c.UploadCodec }},
+	{key: "upload.bitrate", env: "UPLOAD_BITRATE", ptr: func(c *Config) any { return &c.UploadBitrate }},
+	{key: "storage.backend", env: "STORAGE_BACKEND", restart: true, ptr: func(c *Config) any { return &c.StorageBackend }},
+	{key: "storage.cache_dir", env: "STORAGE_CACHE_DIR", restart: true, ptr: func(c *Config) any { return &c.StorageCacheDir }},
+	{key: "storage.upload_dir", env: "STORAGE_UPLOAD_DIR", restart: true, ptr: func(c *Config) any { return &c.StorageUploadDir }},
+	{key: "storage.s3_endpoint", env: "STORAGE_S3_ENDPOINT", ptr: func(c *Config) any { return &c.StorageS3Endpoint }},
+	{key: "storage.s3_region", env: "STORAGE_S3_REGION", ptr: func(c *Config) any { return &c.StorageS3Region }},
+	{key: "storage.s3_bucket", env: "STORAGE_S3_BUCKET", ptr: func(c *Config) any { return &c.StorageS3Bucket }},
+	{key: "storage.s3_access_key", env: "STORAGE_S3_ACCESS_KEY", ptr: func(c *Config) any { return &c.StorageS3AccessKey }},
+	{key: "storage.s3_secret_key", env: "STORAGE_S3_SECRET_KEY", secret: true, ptr: func(c *Config) any { return &c.StorageS3SecretKey }},
+	{key: "storage.s3_path_style", env: "STORAGE_S3_PATH_STYLE", ptr: func(c *Config) any { return &c.StorageS3PathStyle }},
+	{key: "storage.s3_presign_minutes", env: "STORAGE_S3_PRESIGN_MINUTES", ptr: func(c *Config) any { return &c.StorageS3PresignMinutes }},
+	{key: "quota.cache_mb", env: "QUOTA_CACHE_MB", ptr: func(c *Config) any { return &c.QuotaCacheMB }},
+	{key: "quota.cache_files", env: "QUOTA_CACHE_FILES", ptr: func(c *Config) any { return &c.QuotaCacheFiles }},
+	{key: "quota.upload_mb", env: "QUOTA_UPLOAD_MB", ptr: func(c *Config) any { return &c.QuotaUploadMB }},
+	{key: "retention.unplayed_days", env: "RETENTION_UNPLAYED_DAYS", ptr: func(c *Config) any { return &c.RetentionUnplayedDays }},
+	{key: "retention.keep_top", env: "RETENTION_KEEP_TOP", ptr: func(c *Config) any { return &c.RetentionKeepTop }},
 }
 
+var (
+	mu         sync.RWMutex
+	current    *Config
+	generation uint64 // counts the snapshots set to current
+)
+
+// NewConfig returns a copy of the current configuration, loading it on first use.
+// The snapshot is replaced by Reload (see Watch) when the config files change.
 func NewConfig() (*Config, error) {
-	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+	mu.RLock()
+	cfg := current
+	mu.RUnlock()
+
+	if cfg == nil {
+		loaded, err := Load(FilePath())
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		if current == nil {
+			setCurrent(loaded)
+		}
+		cfg = current
+		mu.Unlock()
+	}
+
+	snapshot := *cfg
+	return &snapshot, nil
+}
+
+// Generation identifies the current snapshot, it changes whenever the snapshot
+// is replaced. Values derived from the config can be kept until then.
+func Generation() uint64 {
+	mu.RLock()
+	defer mu.RUnlock()
+	return generation
+}
+
+// setCurrent replaces the snapshot, mu must be locked.
+func setCurrent(cfg *Config) {
+	current = cfg
+	generation++
+}
+
+// FilePath returns the config file in use: CONFIG_FILE if set, otherwise the
+// first existing of DefaultFiles. Empty means env variables only.
+func FilePath() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+
+	for _, path := range DefaultFiles {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// Load builds a configuration from defaults, the config file at path (optional)
+// and environment variables (including .env), in increasing order of precedence.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.apply(values, path); err != nil {
+			return nil, err
+		}
+	}
+
+	env, err := readEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.apply(env, "environment"); err != nil {
 		return nil, err
 	}
 
-	if err := validateMandatoryConfig(); err != nil {
+	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 
-	config := &Config{
-		DiscordCommandPrefix:       os.Getenv("DISCORD_COMMAND_PREFIX"),
-		DiscordBotToken:            os.Getenv("DISCORD_BOT_TOKEN"),
-		DiscordAdminUserID:         os.Getenv("DISCORD_ADMIN_USER_ID"),
-		DiscordAdminChannelID:      os.Getenv("DISCORD_ADMIN_CHANNEL_ID"),
-		RestEnabled:                getenvAsBool("REST_ENABLED"),
-		RestGinRelease:             getenvAsBool("REST_GIN_RELEASE"),
-		RestHostname:               os.Getenv("REST_HOSTNAME"),
-		DcaFrameDuration:           getenvAsInt("DCA_FRAME_DURATION"),
-		DcaBitrate:                 getenvAsInt("DCA_BITRATE"),
-		DcaPacketLoss:              getenvAsInt("DCA_PACKET_LOSS"),
-		DcaRawOutput:               getenvAsBool("DCA_RAW_OUTPUT"),
-		DcaApplication:             dca.AudioApplication(os.Getenv("DCA_APPLICATION")),
-		DcaCompressionLevel:        getenvAsInt("DCA_COMPRESSION_LEVEL"),
-		DcaBufferedFrames:          getenvAsInt("DCA_BUFFERED_FRAMES"),
-		DcaVBR:                     getenvAsBool("DCA_VBR"),
-		DcaReconnectAtEOF:          getenvBoolAsInt("DCA_RECONNECT_AT_EOF"),
-		DcaReconnectStreamed:       getenvBoolAsInt("DCA_RECONNECT_STREAMED"),
-		DcaReconnectOnNetworkError: getenvBoolAsInt("DCA_RECONNECT_ON_NETWORK_ERROR"),
-		DcaReconnectOnHttpError:    os.Getenv("DCA_RECONNECT_ON_HTTTP_ERROR"),
-		DcaReconnectDelayMax:       getenvAsInt("DCA_RECONNECT_MAX"),
-		DcaFfmpegBinaryPath:        os.Getenv("DCA_FFMPEG_BINARY_PATH"),
-		DcaEncodingLineLog:         getenvAsBool("DCA_ENCODING_LINE_LOG"),
-		DcaUserAgent:               os.Getenv("DCA_USER_AGENT"),
-	}
-
-	return config, nil
-}
-
-// Returns the JSON representation of the Config struct.
+	return cfg, nil
+}
+
+// Default returns the configuration used when nothing is set, encoding
+// options are taken from dca.StdEncodeOptions.
+func Default() *Config {
+	std := dca.StdEncodeOptions
+
+	return &Config{
+		DiscordCommandPrefix:       "!",
+		DcaFrameDuration:           std.FrameDuration,
+		DcaBitrate:                 std.Bitrate,
+		DcaPacketLoss:              std.PacketLoss,
+		DcaRawOutput:               std.RawOutput,
+		DcaApplication:             std.Application,
+		DcaCompressionLevel:        std.CompressionLevel,
+		DcaBufferedFrames:          std.BufferedFrames,
+		DcaVBR:                     std.VBR,
+		DcaReconnectAtEOF:          std.ReconnectAtEOF,
+		DcaReconnectStreamed:       std.ReconnectStreamed,
+		DcaReconnectOnNetworkError: std.ReconnectOnNetworkError,
+		DcaReconnectOnHttpError:    std.ReconnectOnHttpError,
+		DcaReconnectDelayMax:       std.ReconnectDelayMax,
+		DcaFfmpegBinaryPath:        std.FfmpegBinaryPath,
+		DcaEncodingLineLog:         std.EncodingLineLog,
+		DcaUserAgent:               std.UserAgent,
+		AttachmentsMaxSizeMB:       25,
+		AttachmentsFormats:         "mp3,ogg,opus,flac,m4a,wav",
+		YoutubeResolver:            "kkdai",
+		RadioDirectoryURL:          "https://all.api.radio-browser.info",
+		AutoCacheMinPlays:          3,
+		AutoCacheMaxSizeMB:         1024,
+		PrecodeDir:                 "./precoded",
+		JobsWorkers:                2,
+		UploadCodec:                "mp3",
+		UploadBitrate:              256,
+		StorageBackend:             "local",
+		StorageCacheDir:            "./cache",
+		StorageUploadDir:           "./upload",
+		StorageS3Region:            "us-east-1",
+		StorageS3PathStyle:         true,
+		StorageS3PresignMinutes:    360,
+	}
+}
+
+// AttachmentFormats returns the allowed attachment file extensions, lowercase and without dot.
+func (c *Config) AttachmentFormats() []string {
+	var formats []string
+	for _, format := range strings.Split(c.AttachmentsFormats, ",") {
+		format = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(format), "."))
+		if format != "" {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// Validate checks value ranges and returns all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%v: %v", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(c.DiscordBotToken != "", "discord.bot_token", "is required (DISCORD_BOT_TOKEN)")
+	check(strings.TrimSpace(c.DiscordCommandPrefix) != "", "discord.command_prefix", "must not be empty")
+	check(c.DcaFrameDuration == 20 || c.DcaFrameDuration == 40 || c.DcaFrameDuration == 60, "dca.frame_duration", "must be 20, 40 or 60, got %v", c.DcaFrameDuration)
+	check(c.DcaBitrate >= 8 && c.DcaBitrate <= 128, "dca.bitrate", "must be between 8 and 128, got %v", c.DcaBitrate)
+	check(c.DcaPacketLoss >= 0 && c.DcaPacketLoss <= 100, "dca.packet_loss", "must be between 0 and 100, got %v", c.DcaPacketLoss)
+	check(c.DcaCompressionLevel >= 0 && c.DcaCompressionLevel <= 10, "dca.compression_level", "must be between 0 and 10, got %v", c.DcaCompressionLevel)
+	check(c.DcaBufferedFrames > 0, "dca.buffered_frames", "must be positive, got %v", c.DcaBufferedFrames)
+	check(c.DcaReconnectDelayMax >= 0, "dca.reconnect_delay_max", "must not be negative, got %v", c.DcaReconnectDelayMax)
+	check(c.SubsonicURL == "" || c.SubsonicUser != "", "subsonic.user", "is required when subsonic.url is set")
+	check(c.RadioDirectoryURL != "", "radio.directory_url", "must not be empty")
+	check(c.AutoCacheMinPlays >= 0, "autocache.min_plays", "must not be negative, got %v", c.AutoCacheMinPlays)
+	check(c.AutoCacheMaxSizeMB > 0, "autocache.max_size_mb", "must be positive, got %v", c.AutoCacheMaxSizeMB)
+	check(!c.PrecodeEnabled || c.PrecodeDir != "", "precode.dir", "is required when precode.enabled is set")
+	check(c.JobsWorkers > 0, "jobs.workers", "must be positive, got %v", c.JobsWorkers)
+	check(c.UploadBitrate > 0, "upload.bitrate", "must be positive, got %v", c.UploadBitrate)
+	check(c.QuotaCacheMB >= 0, "quota.cache_mb", "must not be negative, got %v", c.QuotaCacheMB)
+	check(c.QuotaCacheFiles >= 0, "quota.cache_files", "must not be negative, got %v", c.QuotaCacheFiles)
+	check(c.QuotaUploadMB >= 0, "quota.upload_mb", "must not be negative, got %v", c.QuotaUploadMB)
+	check(c.RetentionUnplayedDays >= 0, "retention.unplayed_days", "must not be negative, got %v", c.RetentionUnplayedDays)
+	check(c.RetentionKeepTop >= 0, "retention.keep_top", "must not be negative, got %v", c.RetentionKeepTop)
+	check(c.AttachmentsMaxSizeMB >= 0, "attachments.max_size_mb", "must not be negative, got %v", c.AttachmentsMaxSizeMB)
+
+	switch c.YoutubeResolver {
+	case "kkdai":
+	case "ytdlp":
+		check(c.YoutubeYtDlpPath != "", "youtube.ytdlp_path", "is required when youtube.resolver is ytdlp")
+	default:
+		check(false, "youtube.resolver", "must be kkdai or ytdlp, got %q", c.YoutubeResolver)
+	}
+
+	switch c.UploadCodec {
+	case "mp3", "aac", "opus", "flac":
+	default:
+		check(false, "upload.codec", "must be mp3, aac, opus or flac, got %q", c.UploadCodec)
+	}
+
+	check(c.StorageCacheDir != "", "storage.cache_dir", "must not be empty")
+	check(c.StorageUploadDir != "", "storage.upload_dir", "must not be empty")
+
+	switch c.StorageBackend {
+	case "local":
+	case "s3":
+		check(c.StorageS3Endpoint != "", "storage.s3_endpoint", "is required when storage.backend is s3")
+		check(c.StorageS3Bucket != "", "storage.s3_bucket", "is required when storage.backend is s3")
+		check(c.StorageS3AccessKey != "" && c.StorageS3SecretKey != "", "storage.s3_access_key", "and storage.s3_secret_key are required when storage.backend is s3")
+		check(c.StorageS3Region != "", "storage.s3_region", "must not be empty")
+		check(c.StorageS3PresignMinutes > 0, "storage.s3_presign_minutes", "must be positive, got %v", c.StorageS3PresignMinutes)
+	default:
+		check(false, "storage.backend", "must be local or s3, got %q", c.StorageBackend)
+	}
+
+	switch c.DcaApplication {
+	case dca.AudioApplicationAudio, dca.AudioApplicationVoip, dca.AudioApplicationLowDelay:
+	default:
+		check(false, "dca.application", "must be audio, voip or lowdelay, got %q", c.DcaApplication)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%v", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// Returns the JSON representation of the Config struct with secrets redacted.
 func (c *Config) String() string {
 	configMap := map[string]interface{}{
 		"DiscordCommandPrefix":       c.DiscordCommandPrefix,
-		"DiscordBotToken":            c.DiscordBotToken,
+		"DiscordBotToken":            redactSecret(c.DiscordBotToken),
 		"DiscordAdminUserID":         c.DiscordAdminUserID,
 		"DiscordAdminChannelID":      c.DiscordAdminChannelID,
+		"DatabaseURL":                redactURL(c.DatabaseURL),
 		"RestEnabled":                c.RestEnabled,
 		"RestGinRelease":             c.RestGinRelease,
 		"RestHostname":               c.RestHostname,
@@ -101,6 +403,40 @@ func (c *Config) String() string {
 		"DcaFfmpegBinaryPath":        c.DcaFfmpegBinaryPath,
 		"DcaEncodingLineLog":         c.DcaEncodingLineLog,
 		"DcaUserAgent":               c.DcaUserAgent,
+		"LibraryDir":                 c.LibraryDir,
+		"AttachmentsMaxSizeMB":       c.AttachmentsMaxSizeMB,
+		"AttachmentsFormats":         c.AttachmentsFormats,
+		"YoutubeResolver":            c.YoutubeResolver,
+		"YoutubeYtDlpPath":           c.YoutubeYtDlpPath,
+		"SubsonicURL":                c.SubsonicURL,
+		"SubsonicUser":               c.SubsonicUser,
+		"SubsonicPassword":           redactSecret(c.SubsonicPassword),
+		"RadioDirectoryURL":          c.RadioDirectoryURL,
+		"AutoCacheEnabled":           c.AutoCacheEnabled,
+		"AutoCacheMinPlays":          c.AutoCacheMinPlays,
+		"AutoCacheMaxSizeMB":         c.AutoCacheMaxSizeMB,
+		"PrecodeEnabled":             c.PrecodeEnabled,
+		"PrecodeDir":                 c.PrecodeDir,
+		"JobsWorkers":                c.JobsWorkers,
+		"WatcherEnabled":             c.WatcherEnabled,
+		"WatcherUploadGuildID":       c.WatcherUploadGuildID,
+		"UploadCodec":                c.UploadCodec,
+		"UploadBitrate":              c.UploadBitrate,
+		"StorageBackend":             c.StorageBackend,
+		"StorageCacheDir":            c.StorageCacheDir,
+		"StorageUploadDir":           c.StorageUploadDir,
+		"StorageS3Endpoint":          c.StorageS3Endpoint,
+		"StorageS3Region":            c.StorageS3Region,
+		"StorageS3Bucket":            c.StorageS3Bucket,
+		"StorageS3AccessKey":         c.StorageS3AccessKey,
+		"StorageS3SecretKey":         redactSecret(c.StorageS3SecretKey),
+		"StorageS3PathStyle":         c.StorageS3PathStyle,
+		"StorageS3PresignMinutes":    c.StorageS3PresignMinutes,
+		"QuotaCacheMB":               c.QuotaCacheMB,
+		"QuotaCacheFiles":            c.QuotaCacheFiles,
+		"QuotaUploadMB":              c.QuotaUploadMB,
+		"RetentionUnplayedDays":      c.RetentionUnplayedDays,
+		"RetentionKeepTop":           c.RetentionKeepTop,
 	}
 
 	jsonString, err := json.MarshalIndent(configMap, "", "    ")
@@ -111,61 +447,245 @@ func (c *Config) String() string {
 	return string(jsonString)
 }
 
-// validateMandatoryConfig checks for the presence of mandatory configuration keys
-// in the environment variables and returns an error if any are missing.
-func validateMandatoryConfig() error {
-	mandatoryKeys := []string{
-		"DISCORD_COMMAND_PREFIX", "DISCORD_BOT_TOKEN", "REST_ENABLED", "DCA_FRAME_DURATION", "DCA_BITRATE", "DCA_PACKET_LOSS",
-		"DCA_RAW_OUTPUT", "DCA_APPLICATION", "DCA_COMPRESSION_LEVEL", "DCA_BUFFERED_FRAMES",
-		"DCA_VBR", "DCA_RECONNECT_AT_EOF", "DCA_RECONNECT_STREAMED", "DCA_RECONNECT_ON_NETWORK_ERROR",
-		"DCA_RECONNECT_ON_HTTTP_ERROR", "DCA_RECONNECT_MAX",
-		"DCA_ENCODING_LINE_LOG", "DCA_USER_AGENT",
+// apply sets fields from raw string values keyed by file key or env variable.
+func (c *Config) apply(values map[string]string, source string) error {
+	var errs []error
+
+	for _, f := range fields {
+		raw, name, ok := f.lookup(values)
+		if !ok {
+			continue
+		}
+		if err := f.set(c, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%v (%v in %v): %v", f.key, name, source, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%v", errors.Join(errs...))
 	}
 
-	for _, key := range mandatoryKeys {
-		if os.Getenv(key) == "" {
-			return errors.New("Missing mandatory configuration: " + key)
+	return nil
+}
+
+func (f field) lookup(values map[string]string) (string, string, bool) {
+	for _, name := range append([]string{f.key, f.env}, f.aliases...) {
+		if raw, ok := values[name]; ok && raw != "" {
+			return raw, name, true
 		}
 	}
+	return "", "", false
+}
 
+func (f field) set(c *Config, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch p := f.ptr(c).(type) {
+	case *string:
+		*p = raw
+	case *dca.AudioApplication:
+		*p = dca.AudioApplication(strings.ToLower(raw))
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		*p = b
+	case *int:
+		if f.boolInt {
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("%q is not a boolean", raw)
+			}
+			*p = 0
+			if b {
+				*p = 1
+			}
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		*p = n
+	default:
+		return fmt.Errorf("unsupported field type %T", p)
+	}
+
+	return nil
+}
+
+// value returns the field value for comparison.
+func (f field) value(c *Config) any {
+	switch p := f.ptr(c).(type) {
+	case *string:
+		return *p
+	case *dca.AudioApplication:
+		return *p
+	case *bool:
+		return *p
+	case *int:
+		return *p
+	}
 	return nil
 }
 
-func getenvAsBool(key string) bool {
-	val := os.Getenv(key)
+// copyValue sets the field of dst to the value it has in src.
+func (f field) copyValue(dst, src *Config) {
+	switch p := f.ptr(dst).(type) {
+	case *string:
+		*p = *f.ptr(src).(*string)
+	case *dca.AudioApplication:
+		*p = *f.ptr(src).(*dca.AudioApplication)
+	case *bool:
+		*p = *f.ptr(src).(*bool)
+	case *int:
+		*p = *f.ptr(src).(*int)
+	}
+}
 
-	boolValue, err := strconv.ParseBool(val)
+// readFile parses a YAML or TOML config file into flat dotted keys.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return false
+		return nil, fmt.Errorf("error reading config file %v: %v", path, err)
+	}
+
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %v (use .yaml, .yml or .toml)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file %v: %v", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.key] = true
 	}
 
-	return boolValue
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys in config file %v: %v", path, strings.Join(unknown, ", "))
+	}
+
+	return values, nil
 }
 
-func getenvAsInt(key string) int {
-	val := os.Getenv(key)
+func flatten(prefix string, node map[string]any, values map[string]string) {
+	for key, value := range node {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
 
-	intValue, err := strconv.Atoi(val)
-	if err != nil {
-		slog.Error("Error parsing integer value from env variable")
-		return 0
+		switch v := value.(type) {
+		case map[string]any:
+			flatten(key, v, values)
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
 	}
+}
 
-	return intValue
+// dotenvValues are the variables exported from the .env file along with the
+// value set, so a changed .env file replaces them on reload while variables
+// set by the process environment keep precedence.
+var (
+	dotenvMu     sync.Mutex
+	dotenvValues = map[string]string{}
+)
+
+// LoadDotenv exports the .env file values to the process environment, for code
+// reading it directly (e.g. HOST) or running without the config.
+func LoadDotenv() error {
+	_, err := readDotenv()
+	return err
 }
 
-func getenvBoolAsInt(key string) int {
-	val := os.Getenv(key)
+func readDotenv() (map[string]string, error) {
+	dotenv, err := godotenv.Read(dotenvFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading %v: %v", dotenvFile, err)
+	}
+
+	dotenvMu.Lock()
+	defer dotenvMu.Unlock()
 
-	boolValue, err := strconv.ParseBool(val)
+	for key, exported := range dotenvValues {
+		if _, ok := dotenv[key]; !ok && os.Getenv(key) == exported {
+			os.Unsetenv(key)
+			delete(dotenvValues, key)
+		}
+	}
+	for key, value := range dotenv {
+		current, ok := os.LookupEnv(key)
+		if exported, fromDotenv := dotenvValues[key]; ok && !(fromDotenv && current == exported) {
+			continue
+		}
+		os.Setenv(key, value)
+		dotenvValues[key] = value
+	}
+
+	return dotenv, nil
+}
+
+// readEnv returns the .env file values overridden by the process environment.
+func readEnv() (map[string]string, error) {
+	values := make(map[string]string)
+
+	dotenv, err := readDotenv()
 	if err != nil {
-		slog.Error("Error parsing bool value from env variable")
-		return 0
+		return nil, err
+	}
+	for key, value := range dotenv {
+		values[key] = value
 	}
 
-	if boolValue {
-		return 1
+	for _, f := range fields {
+		for _, name := range append([]string{f.env}, f.aliases...) {
+			if value, ok := os.LookupEnv(name); ok {
+				values[name] = value
+			}
+		}
 	}
 
-	return 0
+	return values, nil
+}
+
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "xxxxx"
+}
+
+// redactURL hides the password part of a connection URL.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
 }
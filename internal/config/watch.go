@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gookit/slog"
+)
+
+// Reload re-reads the configuration and replaces the current snapshot.
+// Secrets and settings marked as restart-only keep their running values.
+// Returns the keys that changed and were applied.
+func Reload() ([]string, error) {
+	cfg, err := Load(FilePath())
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if current == nil {
+		setCurrent(cfg)
+		return nil, nil
+	}
+
+	var applied []string
+	for _, f := range fields {
+		if f.value(cfg) == f.value(current) {
+			continue
+		}
+
+		if f.secret || f.restart {
+			slog.Warnf("Config key %v changed but requires a restart to take effect", f.key)
+			f.copyValue(cfg, current)
+			continue
+		}
+
+		applied = append(applied, f.key)
+	}
+
+	setCurrent(cfg)
+	return applied, nil
+}
+
+// Watch reloads the configuration whenever the config file or .env changes.
+// The returned function stops watching.
+func Watch() (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error creating config watcher: %v", err)
+	}
+
+	files := map[string]bool{}
+	for _, path := range []string{FilePath(), dotenvFile} {
+		if path == "" {
+			continue
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("error resolving config path %v: %v", path, err)
+		}
+		files[absPath] = true
+
+		// Watch the directory so files replaced by editors are picked up too
+		if err := watcher.Add(filepath.Dir(absPath)); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("error watching %v: %v", filepath.Dir(absPath), err)
+		}
+	}
+
+	go func() {
+		var debounce <-chan time.Time
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				absPath, err := filepath.Abs(event.Name)
+				if err != nil || !files[absPath] {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					debounce = time.After(500 * time.Millisecond)
+				}
+			case <-debounce:
+				debounce = nil
+
+				applied, err := Reload()
+				if err != nil {
+					slog.Errorf("Error reloading config, keeping previous values: %v", err)
+					continue
+				}
+				if len(applied) > 0 {
+					slog.Infof("Config reloaded, updated keys: %v", applied)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Errorf("Config watcher error: %v", err)
+			}
+		}
+	}()
+
+	return func() { watcher.Close() }, nil
+}
@@ -1,16 +1,18 @@
 package cron
 
 import (
-	"crypto/md5"
+	"context"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/gookit/slog"
 
+	"github.com/keshon/melodix-player/internal/config"
 	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/cache"
 	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/sources"
 	"github.com/robfig/cron/v3"
 )
 
@@ -19,10 +21,17 @@ type ICronTasks interface {
 }
 
 type CronTasks struct {
+	repos     *db.Repositories
+	tracks    db.TrackRepository
+	histories db.HistoryRepository
 }
 
-func NewCron() ICronTasks {
-	return &CronTasks{}
+func NewCron(repos *db.Repositories) ICronTasks {
+	return &CronTasks{
+		repos:     repos,
+		tracks:    repos.Tracks,
+		histories: repos.Histories,
+	}
 }
 
 func (ct *CronTasks) Start() {
@@ -53,64 +62,89 @@ func (ct *CronTasks) runAllTasks() {
 		slog.Error("Error processing missing tracks: %v", err)
 	}
 
+	err = ct.scanLibrary()
+	if err != nil {
+		slog.Error("Error scanning music library: %v", err)
+	}
+
+	err = ct.applyRetention()
+	if err != nil {
+		slog.Error("Error applying cache retention: %v", err)
+	}
+
 	err = ct.checkAndTrimLogFile("./logs/all-levels.log")
 	if err != nil {
 		slog.Error("Error checking and trimming log file: %v", err)
 	}
 }
 
+// dbInvalidTracks removes tracks that can no longer be played.
+// Missing song IDs are backfilled once by the database migrations.
 func (ct *CronTasks) dbInvalidTracks() error {
-	tracks, err := db.GetAllTracks()
+	tracks, err := ct.tracks.GetAll()
 	if err != nil {
 		return err
 	}
 
 	for _, track := range tracks {
-		if track.Source == media.SourceYouTube.String() {
-			if track.URL == "" {
-				err = db.DeleteTrack(&track)
-				if err != nil {
-					return err
-				}
-			}
+		invalid := (track.Source == media.SourceYouTube.String() && track.URL == "") ||
+			(track.Source == media.SourceLocalFile.String() && track.Filepath == "")
 
-			if track.SongID == "" {
-				ytID := strings.Split(track.URL, "v=")[1]
-				track.SongID = ytID
-				err = db.UpdateTrack(&track)
-				if err != nil {
-					return err
-				}
+		if invalid {
+			err = ct.tracks.Delete(&track)
+			if err != nil {
+				return err
 			}
 		}
+	}
 
-		if track.Source == media.SourceLocalFile.String() {
-			if track.Filepath == "" {
-				err = db.DeleteTrack(&track)
-				if err != nil {
-					return err
-				}
-			}
+	slog.Info("Done running invalid tracks")
 
-			if track.SongID == "" {
-				songID := md5.Sum([]byte(track.Title))
-				songIDStr := fmt.Sprintf("%x", songID)
-				track.SongID = songIDStr
-				err = db.UpdateTrack(&track)
-				if err != nil {
-					return err
-				}
-			}
+	return nil
+}
+
+// scanLibrary keeps the local music library index in sync with library.dir.
+func (ct *CronTasks) scanLibrary() error {
+	lib := sources.NewLibrary(ct.repos)
+	if lib == nil {
+		return nil
+	}
+
+	_, err := lib.Scan(context.Background())
+	return err
+}
+
+// applyRetention deletes the cached tracks expired by the retention rules of
+// each guild.
+func (ct *CronTasks) applyRetention() error {
+	conf, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+
+	guildIDs, err := ct.repos.Guilds.GetAllIDs()
+	if err != nil {
+		return err
+	}
+
+	for _, guildID := range guildIDs {
+		report, err := cache.NewCache(conf.StorageUploadDir, conf.StorageCacheDir, guildID, ct.repos).Retention(context.Background(), true)
+		if err != nil {
+			slog.Errorf("Error applying cache retention of guild %v: %v", guildID, err)
+			continue
+		}
+		if len(report.Expired) > 0 {
+			slog.Infof("Retention deleted %d cached tracks of guild %v", len(report.Expired), guildID)
 		}
 	}
 
-	slog.Info("Done running invalid tracks")
+	slog.Info("Done running cache retention")
 
 	return nil
 }
 
 func (ct *CronTasks) dbMissingTracks() error {
-	allHistoryRecords, err := db.GetAllHistorySortedBy("")
+	allHistoryRecords, err := ct.histories.GetAllSortedBy("")
 	if err != nil {
 		return err
 	}
@@ -125,9 +159,9 @@ func (ct *CronTasks) dbMissingTracks() error {
 	trackIDs = removeDuplicate(trackIDs)
 	var deletedHistoryRecords uint
 	for _, id := range trackIDs {
-		doesExist, err := DoesTrackExistForHistory(id)
+		doesExist, err := ct.tracks.Exists(id)
 		if err != nil || !doesExist {
-			err = db.DeleteHistory(strconv.Itoa(int(id)))
+			err = ct.histories.DeleteByTrackID(id)
 			if err != nil {
 				return err
 			}
@@ -152,15 +186,6 @@ func removeDuplicate[T comparable](sliceList []T) []T {
 	return list
 }
 
-func DoesTrackExistForHistory(trackID uint) (bool, error) {
-	var count int64
-	err := db.DB.Model(&db.Track{}).Where("id = ?", trackID).Count(&count).Error
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
-}
-
 func (c *CronTasks) checkAndTrimLogFile(filePath string) error {
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type copyStep struct {
+	table string
+	copy  func(src, dst *gorm.DB) (int64, error)
+}
+
+// copySteps lists every table holding application data, parents first.
+var copySteps = []copyStep{
+	{"guilds", copyTable[Guild]},
+	{"tracks", copyTable[Track]},
+	{"histories", copyTable[History]},
+	{"guild_settings", copyTable[GuildSetting]},
+	{"library_tracks", copyTable[LibraryTrack]},
+	{"podcast_feeds", copyTable[PodcastFeed]},
+	{"jobs", copyTable[Job]},
+}
+
+// CopyDatabase copies all application data from src into dst, keeping primary keys.
+// Both databases must already be migrated and dst must be empty.
+func CopyDatabase(src, dst *gorm.DB) (map[string]int64, error) {
+	for _, step := range copySteps {
+		var count int64
+		if err := dst.Table(step.table).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("error counting rows in destination table %v: %v", step.table, err)
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("destination table %v is not empty", step.table)
+		}
+	}
+
+	copied := make(map[string]int64, len(copySteps))
+
+	err := dst.Transaction(func(tx *gorm.DB) error {
+		for _, step := range copySteps {
+			n, err := step.copy(src, tx)
+			if err != nil {
+				return fmt.Errorf("error copying table %v: %v", step.table, err)
+			}
+			copied[step.table] = n
+
+			if err := resetSequence(tx, step.table); err != nil {
+				return fmt.Errorf("error resetting sequence of table %v: %v", step.table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copied, nil
+}
+
+func copyTable[T any](src, dst *gorm.DB) (int64, error) {
+	var total int64
+	var batch []T
+
+	err := src.Model(new(T)).FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		if err := dst.Omit(clause.Associations).Create(&batch).Error; err != nil {
+			return err
+		}
+		total += int64(len(batch))
+		return nil
+	}).Error
+
+	return total, err
+}
+
+// resetSequence moves the Postgres id sequence past the copied rows,
+// other dialects derive the next auto-increment value from the data.
+func resetSequence(tx *gorm.DB, table string) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+
+	var sequence *string
+	if err := tx.Raw("SELECT pg_get_serial_sequence(?, 'id')", table).Scan(&sequence).Error; err != nil {
+		return err
+	}
+	if sequence == nil {
+		return nil
+	}
+
+	return tx.Exec(fmt.Sprintf("SELECT setval('%s', COALESCE((SELECT MAX(id) FROM %s), 0) + 1, false)", *sequence, table)).Error
+}
@@ -10,24 +10,28 @@ type Guild struct {
 	Prefix string
 }
 
-func CreateGuild(guild Guild) error {
-	return DB.Create(&guild).Error
+type GormGuildRepository struct {
+	db *gorm.DB
 }
 
-func GetGuildByID(guildID string) (*Guild, error) {
+func (r *GormGuildRepository) Create(guild Guild) error {
+	return r.db.Create(&guild).Error
+}
+
+func (r *GormGuildRepository) GetByID(guildID string) (*Guild, error) {
 	var guild Guild
-	err := DB.Where("id = ?", guildID).First(&guild).Error
+	err := r.db.Where("id = ?", guildID).First(&guild).Error
 	if err == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
 	return &guild, err
 }
 
-func GetAllGuildIDs() ([]string, error) {
+func (r *GormGuildRepository) GetAllIDs() ([]string, error) {
 	var guilds []Guild
 	var guildIDs []string
 
-	if err := DB.Find(&guilds).Error; err != nil {
+	if err := r.db.Find(&guilds).Error; err != nil {
 		return nil, err
 	}
 
@@ -38,30 +42,30 @@ func GetAllGuildIDs() ([]string, error) {
 	return guildIDs, nil
 }
 
-func DoesGuildExist(guildID string) (bool, error) {
+func (r *GormGuildRepository) Exists(guildID string) (bool, error) {
 	var count int64
-	err := DB.Model(&Guild{}).Where("id = ?", guildID).Count(&count).Error
+	err := r.db.Model(&Guild{}).Where("id = ?", guildID).Count(&count).Error
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func DeleteGuild(guildID string) error {
-	return DB.Where("id = ?", guildID).Delete(&Guild{}).Error
+func (r *GormGuildRepository) Delete(guildID string) error {
+	return r.db.Where("id = ?", guildID).Delete(&Guild{}).Error
 }
 
-func SetGuildPrefix(guildID string, prefix string) error {
-	return DB.Model(&Guild{}).Where("id = ?", guildID).Update("prefix", prefix).Error
+func (r *GormGuildRepository) SetPrefix(guildID string, prefix string) error {
+	return r.db.Model(&Guild{}).Where("id = ?", guildID).Update("prefix", prefix).Error
 }
 
-func ResetGuildPrefix(guildID string) error {
-	return DB.Model(&Guild{}).Where("id = ?", guildID).Update("prefix", "").Error
+func (r *GormGuildRepository) ResetPrefix(guildID string) error {
+	return r.db.Model(&Guild{}).Where("id = ?", guildID).Update("prefix", "").Error
 }
 
-func GetGuildPrefix(guildID string) (string, error) {
+func (r *GormGuildRepository) GetPrefix(guildID string) (string, error) {
 	var guild Guild
-	err := DB.Where("id = ?", guildID).First(&guild).Error
+	err := r.db.Where("id = ?", guildID).First(&guild).Error
 	if err == gorm.ErrRecordNotFound {
 		return "", nil
 	}
@@ -8,32 +8,36 @@ import (
 )
 
 type History struct {
-	ID         uint `gorm:"primaryKey;autoIncrement"`
-	GuildID    string
-	TrackID    uint
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	GuildID    string `gorm:"size:191;uniqueIndex:idx_histories_guild_track"`
+	TrackID    uint   `gorm:"uniqueIndex:idx_histories_guild_track"`
 	PlayCount  uint
 	Duration   float64
 	LastPlayed time.Time
 }
 
-func CreateHistory(history *History) error {
+type GormHistoryRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormHistoryRepository) Create(history *History) error {
 	history.LastPlayed = time.Now()
-	return DB.Create(history).Error
+	return r.db.Create(history).Error
 }
 
-func GetAllHistorySortedBy(sortBy string) ([]History, error) {
+func (r *GormHistoryRepository) GetAllSortedBy(sortBy string) ([]History, error) {
 	var history []History
 	var query *gorm.DB
 
 	switch sortBy {
 	case "duration":
-		query = DB.Order("duration DESC")
+		query = r.db.Order("duration DESC")
 	case "play_count":
-		query = DB.Order("play_count DESC")
+		query = r.db.Order("play_count DESC")
 	case "last_played":
-		query = DB.Order("last_played DESC")
+		query = r.db.Order("last_played DESC")
 	default:
-		query = DB
+		query = r.db
 	}
 
 	if err := query.Find(&history).Error; err != nil {
@@ -43,17 +47,17 @@ func GetAllHistorySortedBy(sortBy string) ([]History, error) {
 	return history, nil
 }
 
-func GetGuildHistorySortedBy(guildID, sortBy string) ([]History, error) {
+func (r *GormHistoryRepository) GetGuildSortedBy(guildID, sortBy string) ([]History, error) {
 	var history []History
 	var query *gorm.DB
 
 	switch sortBy {
 	case "duration":
-		query = DB.Where("guild_id = ?", guildID).Order("duration DESC")
+		query = r.db.Where("guild_id = ?", guildID).Order("duration DESC")
 	case "play_count":
-		query = DB.Where("guild_id = ?", guildID).Order("play_count DESC")
+		query = r.db.Where("guild_id = ?", guildID).Order("play_count DESC")
 	case "last_played":
-		query = DB.Where("guild_id = ?", guildID).Order("last_played DESC")
+		query = r.db.Where("guild_id = ?", guildID).Order("last_played DESC")
 	default:
 		return nil, fmt.Errorf("unsupported sort criteria: %s", sortBy)
 	}
@@ -65,25 +69,25 @@ func GetGuildHistorySortedBy(guildID, sortBy string) ([]History, error) {
 	return history, nil
 }
 
-func DoesHistoryExistForGuild(trackID uint, guildID string) (bool, error) {
+func (r *GormHistoryRepository) ExistsForGuild(trackID uint, guildID string) (bool, error) {
 	var count int64
-	err := DB.Model(&History{}).Where("track_id = ? AND guild_id = ?", trackID, guildID).Count(&count).Error
+	err := r.db.Model(&History{}).Where("track_id = ? AND guild_id = ?", trackID, guildID).Count(&count).Error
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func GetHistoryByTrackIDAndGuildID(trackID uint, guildID string) (*History, error) {
+func (r *GormHistoryRepository) GetByTrackIDAndGuildID(trackID uint, guildID string) (*History, error) {
 	var track History
-	if err := DB.Where("track_id = ? AND guild_id = ?", trackID, guildID).First(&track).Error; err != nil {
+	if err := r.db.Where("track_id = ? AND guild_id = ?", trackID, guildID).First(&track).Error; err != nil {
 		return nil, err
 	}
 	return &track, nil
 }
 
-func UpdateTrackStatsForGuild(trackID uint, guildID string, playCount uint, duration float64) error {
-	return DB.Model(&History{}).
+func (r *GormHistoryRepository) UpdateStatsForGuild(trackID uint, guildID string, playCount uint, duration float64) error {
+	return r.db.Model(&History{}).
 		Where("track_id = ? AND guild_id = ?", trackID, guildID).
 		UpdateColumns(map[string]interface{}{
 			"play_count":  playCount,
@@ -92,15 +96,6 @@ func UpdateTrackStatsForGuild(trackID uint, guildID string, playCount uint, dura
 		}).Error
 }
 
-func DeleteHistory(trackSongID string) error {
-	return DB.Where("track_id = ?", trackSongID).Delete(&History{}).Error
-}
-
-func DoesTrackExistForHistory(trackID uint) (bool, error) {
-	var count int64
-	err := DB.Model(&Track{}).Where("id = ?", trackID).Count(&count).Error
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
+func (r *GormHistoryRepository) DeleteByTrackID(trackID uint) error {
+	return r.db.Where("track_id = ?", trackID).Delete(&History{}).Error
 }
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Job is a background download or extraction run for a guild, along with its
+// last reported progress.
+type Job struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	GuildID    string `gorm:"size:191;index"`
+	Kind       string
+	Input      string
+	Status     string `gorm:"size:32;index"`
+	Stage      string // what the job is doing right now
+	BytesDone  int64
+	BytesTotal int64
+	Processed  float64 // seconds of media processed by ffmpeg
+	Duration   float64 // seconds
+	Result     string
+	Error      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type GormJobRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormJobRepository) Create(job *Job) error {
+	return r.db.Create(job).Error
+}
+
+func (r *GormJobRepository) Update(job *Job) error {
+	return r.db.Save(job).Error
+}
+
+func (r *GormJobRepository) Get(id uint) (*Job, error) {
+	var job Job
+	if err := r.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *GormJobRepository) GetAll(guildID string, limit int) ([]Job, error) {
+	var jobs []Job
+	if err := r.db.Where("guild_id = ?", guildID).Order("id DESC").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (r *GormJobRepository) GetByStatus(statuses ...string) ([]Job, error) {
+	var jobs []Job
+	if err := r.db.Where("status IN ?", statuses).Order("id ASC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LibraryTrack is an audio file of the local music library with the tags read from it.
+type LibraryTrack struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement"`
+	SongID      string `gorm:"size:191;uniqueIndex:idx_library_tracks_song_id"`
+	Path        string
+	Artist      string `gorm:"size:191;index:idx_library_tracks_artist"`
+	Album       string `gorm:"size:191;index:idx_library_tracks_album"`
+	Title       string
+	TrackNumber int
+	Duration    float64 // seconds
+	CoverPath   string
+	Size        int64
+	ModTime     int64 // unix seconds
+}
+
+type GormLibraryRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormLibraryRepository) Save(track *LibraryTrack) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "song_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"path", "artist", "album", "title", "track_number", "duration", "cover_path", "size", "mod_time"}),
+	}).Create(track).Error
+}
+
+func (r *GormLibraryRepository) GetBySongID(songID string) (*LibraryTrack, error) {
+	var track LibraryTrack
+	if err := r.db.Where("song_id = ?", songID).First(&track).Error; err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+func (r *GormLibraryRepository) GetAll() ([]LibraryTrack, error) {
+	var tracks []LibraryTrack
+	if err := r.db.Order("artist ASC, album ASC, track_number ASC, title ASC").Find(&tracks).Error; err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+func (r *GormLibraryRepository) FindByArtist(artist string) ([]LibraryTrack, error) {
+	return r.findLike("artist", artist)
+}
+
+func (r *GormLibraryRepository) FindByAlbum(album string) ([]LibraryTrack, error) {
+	return r.findLike("album", album)
+}
+
+func (r *GormLibraryRepository) findLike(column, value string) ([]LibraryTrack, error) {
+	var tracks []LibraryTrack
+	pattern := "%" + strings.ToLower(value) + "%"
+	if err := r.db.Where("LOWER("+column+") LIKE ?", pattern).Order("artist ASC, album ASC, track_number ASC, title ASC").Find(&tracks).Error; err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+func (r *GormLibraryRepository) Count() (int64, error) {
+	var count int64
+	if err := r.db.Model(&LibraryTrack{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *GormLibraryRepository) Delete(id uint) error {
+	return r.db.Delete(&LibraryTrack{}, id).Error
+}
@@ -0,0 +1,645 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// In-memory repositories mirror the behaviour of the GORM ones, including
+// returning gorm.ErrRecordNotFound for missing records.
+
+type MemoryTrackRepository struct {
+	sync.Mutex
+	tracks map[uint]Track
+	nextID uint
+}
+
+func NewMemoryTrackRepository() *MemoryTrackRepository {
+	return &MemoryTrackRepository{
+		tracks: make(map[uint]Track),
+		nextID: 1,
+	}
+}
+
+func (r *MemoryTrackRepository) Create(track *Track) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if track.SongID != "" {
+		for _, t := range r.tracks {
+			if t.SongID == track.SongID {
+				return fmt.Errorf("track with song id %v already exists", track.SongID)
+			}
+		}
+	}
+
+	if track.ID == 0 {
+		track.ID = r.nextID
+	}
+	if track.ID >= r.nextID {
+		r.nextID = track.ID + 1
+	}
+
+	r.tracks[track.ID] = *track
+	return nil
+}
+
+func (r *MemoryTrackRepository) GetByID(id uint) (*Track, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	track, ok := r.tracks[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &track, nil
+}
+
+func (r *MemoryTrackRepository) GetBySongID(songID string) (*Track, error) {
+	return r.find(func(t Track) bool { return t.SongID == songID })
+}
+
+func (r *MemoryTrackRepository) GetByFilepath(filepath string) (*Track, error) {
+	return r.find(func(t Track) bool { return t.Filepath == filepath })
+}
+
+func (r *MemoryTrackRepository) GetByContentHash(hash string) ([]Track, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var tracks []Track
+	for _, t := range r.tracks {
+		if t.ContentHash == hash {
+			tracks = append(tracks, t)
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].ID < tracks[j].ID })
+	return tracks, nil
+}
+
+func (r *MemoryTrackRepository) GetByURL(url string) (*Track, error) {
+	return r.find(func(t Track) bool { return t.URL == url })
+}
+
+func (r *MemoryTrackRepository) GetAll() ([]Track, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	tracks := make([]Track, 0, len(r.tracks))
+	for _, t := range r.tracks {
+		tracks = append(tracks, t)
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].ID < tracks[j].ID })
+	return tracks, nil
+}
+
+func (r *MemoryTrackRepository) Update(track *Track) error {
+	if track.ID == 0 {
+		return r.Create(track)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.tracks[track.ID] = *track
+	return nil
+}
+
+func (r *MemoryTrackRepository) Delete(track *Track) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.tracks, track.ID)
+	return nil
+}
+
+func (r *MemoryTrackRepository) Exists(id uint) (bool, error) {
+	r.Lock()
+	defer r.Unlock()
+	_, ok := r.tracks[id]
+	return ok, nil
+}
+
+func (r *MemoryTrackRepository) find(match func(Track) bool) (*Track, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var found *Track
+	for _, t := range r.tracks {
+		if match(t) && (found == nil || t.ID < found.ID) {
+			t := t
+			found = &t
+		}
+	}
+	if found == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return found, nil
+}
+
+type MemoryHistoryRepository struct {
+	sync.Mutex
+	records map[uint]History
+	nextID  uint
+}
+
+func NewMemoryHistoryRepository() *MemoryHistoryRepository {
+	return &MemoryHistoryRepository{
+		records: make(map[uint]History),
+		nextID:  1,
+	}
+}
+
+func (r *MemoryHistoryRepository) Create(history *History) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, h := range r.records {
+		if h.GuildID == history.GuildID && h.TrackID == history.TrackID {
+			return fmt.Errorf("history for track %v already exists in guild %v", history.TrackID, history.GuildID)
+		}
+	}
+
+	history.LastPlayed = time.Now()
+	if history.ID == 0 {
+		history.ID = r.nextID
+	}
+	if history.ID >= r.nextID {
+		r.nextID = history.ID + 1
+	}
+
+	r.records[history.ID] = *history
+	return nil
+}
+
+func (r *MemoryHistoryRepository) GetAllSortedBy(sortBy string) ([]History, error) {
+	return r.sorted(func(History) bool { return true }, sortBy), nil
+}
+
+func (r *MemoryHistoryRepository) GetGuildSortedBy(guildID, sortBy string) ([]History, error) {
+	switch sortBy {
+	case "duration", "play_count", "last_played":
+	default:
+		return nil, fmt.Errorf("unsupported sort criteria: %s", sortBy)
+	}
+
+	return r.sorted(func(h History) bool { return h.GuildID == guildID }, sortBy), nil
+}
+
+func (r *MemoryHistoryRepository) ExistsForGuild(trackID uint, guildID string) (bool, error) {
+	_, err := r.GetByTrackIDAndGuildID(trackID, guildID)
+	return err == nil, nil
+}
+
+func (r *MemoryHistoryRepository) GetByTrackIDAndGuildID(trackID uint, guildID string) (*History, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, h := range r.records {
+		if h.TrackID == trackID && h.GuildID == guildID {
+			return &h, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *MemoryHistoryRepository) UpdateStatsForGuild(trackID uint, guildID string, playCount uint, duration float64) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for id, h := range r.records {
+		if h.TrackID == trackID && h.GuildID == guildID {
+			h.PlayCount = playCount
+			h.Duration = duration
+			h.LastPlayed = time.Now()
+			r.records[id] = h
+		}
+	}
+	return nil
+}
+
+func (r *MemoryHistoryRepository) DeleteByTrackID(trackID uint) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for id, h := range r.records {
+		if h.TrackID == trackID {
+			delete(r.records, id)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryHistoryRepository) sorted(match func(History) bool, sortBy string) []History {
+	r.Lock()
+	defer r.Unlock()
+
+	var history []History
+	for _, h := range r.records {
+		if match(h) {
+			history = append(history, h)
+		}
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		switch sortBy {
+		case "duration":
+			return history[i].Duration > history[j].Duration
+		case "play_count":
+			return history[i].PlayCount > history[j].PlayCount
+		case "last_played":
+			return history[i].LastPlayed.After(history[j].LastPlayed)
+		default:
+			return history[i].ID < history[j].ID
+		}
+	})
+
+	return history
+}
+
+type MemoryGuildRepository struct {
+	sync.Mutex
+	guilds map[string]Guild
+}
+
+func NewMemoryGuildRepository() *MemoryGuildRepository {
+	return &MemoryGuildRepository{
+		guilds: make(map[string]Guild),
+	}
+}
+
+func (r *MemoryGuildRepository) Create(guild Guild) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.guilds[guild.ID]; ok {
+		return fmt.Errorf("guild %v already exists", guild.ID)
+	}
+	r.guilds[guild.ID] = guild
+	return nil
+}
+
+func (r *MemoryGuildRepository) GetByID(guildID string) (*Guild, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	guild, ok := r.guilds[guildID]
+	if !ok {
+		return nil, nil
+	}
+	return &guild, nil
+}
+
+func (r *MemoryGuildRepository) GetAllIDs() ([]string, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var guildIDs []string
+	for id := range r.guilds {
+		guildIDs = append(guildIDs, id)
+	}
+	sort.Strings(guildIDs)
+	return guildIDs, nil
+}
+
+func (r *MemoryGuildRepository) Exists(guildID string) (bool, error) {
+	r.Lock()
+	defer r.Unlock()
+	_, ok := r.guilds[guildID]
+	return ok, nil
+}
+
+func (r *MemoryGuildRepository) Delete(guildID string) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.guilds, guildID)
+	return nil
+}
+
+func (r *MemoryGuildRepository) SetPrefix(guildID string, prefix string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if guild, ok := r.guilds[guildID]; ok {
+		guild.Prefix = prefix
+		r.guilds[guildID] = guild
+	}
+	return nil
+}
+
+func (r *MemoryGuildRepository) ResetPrefix(guildID string) error {
+	return r.SetPrefix(guildID, "")
+}
+
+func (r *MemoryGuildRepository) GetPrefix(guildID string) (string, error) {
+	guild, err := r.GetByID(guildID)
+	if err != nil || guild == nil {
+		return "", err
+	}
+	return guild.Prefix, nil
+}
+
+type MemorySettingRepository struct {
+	sync.Mutex
+	settings map[string]map[string]string
+}
+
+func NewMemorySettingRepository() *MemorySettingRepository {
+	return &MemorySettingRepository{
+		settings: make(map[string]map[string]string),
+	}
+}
+
+func (r *MemorySettingRepository) GetAll(guildID string) ([]GuildSetting, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var settings []GuildSetting
+	for name, value := range r.settings[guildID] {
+		settings = append(settings, GuildSetting{GuildID: guildID, Name: name, Value: value})
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Name < settings[j].Name })
+	return settings, nil
+}
+
+func (r *MemorySettingRepository) Get(guildID, name string) (*GuildSetting, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	value, ok := r.settings[guildID][name]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &GuildSetting{GuildID: guildID, Name: name, Value: value}, nil
+}
+
+func (r *MemorySettingRepository) Set(guildID, name, value string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.settings[guildID] == nil {
+		r.settings[guildID] = make(map[string]string)
+	}
+	r.settings[guildID][name] = value
+	return nil
+}
+
+func (r *MemorySettingRepository) Delete(guildID, name string) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.settings[guildID], name)
+	return nil
+}
+
+func (r *MemorySettingRepository) DeleteAll(guildID string) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.settings, guildID)
+	return nil
+}
+
+type MemoryLibraryRepository struct {
+	sync.Mutex
+	tracks map[uint]LibraryTrack
+	nextID uint
+}
+
+func NewMemoryLibraryRepository() *MemoryLibraryRepository {
+	return &MemoryLibraryRepository{
+		tracks: make(map[uint]LibraryTrack),
+		nextID: 1,
+	}
+}
+
+func (r *MemoryLibraryRepository) Save(track *LibraryTrack) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for id, t := range r.tracks {
+		if t.SongID == track.SongID {
+			track.ID = id
+			r.tracks[id] = *track
+			return nil
+		}
+	}
+
+	track.ID = r.nextID
+	r.nextID++
+	r.tracks[track.ID] = *track
+	return nil
+}
+
+func (r *MemoryLibraryRepository) GetBySongID(songID string) (*LibraryTrack, error) {
+	tracks := r.filter(func(t LibraryTrack) bool { return t.SongID == songID })
+	if len(tracks) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &tracks[0], nil
+}
+
+func (r *MemoryLibraryRepository) GetAll() ([]LibraryTrack, error) {
+	return r.filter(func(LibraryTrack) bool { return true }), nil
+}
+
+func (r *MemoryLibraryRepository) FindByArtist(artist string) ([]LibraryTrack, error) {
+	artist = strings.ToLower(artist)
+	return r.filter(func(t LibraryTrack) bool { return strings.Contains(strings.ToLower(t.Artist), artist) }), nil
+}
+
+func (r *MemoryLibraryRepository) FindByAlbum(album string) ([]LibraryTrack, error) {
+	album = strings.ToLower(album)
+	return r.filter(func(t LibraryTrack) bool { return strings.Contains(strings.ToLower(t.Album), album) }), nil
+}
+
+func (r *MemoryLibraryRepository) Count() (int64, error) {
+	r.Lock()
+	defer r.Unlock()
+	return int64(len(r.tracks)), nil
+}
+
+func (r *MemoryLibraryRepository) Delete(id uint) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.tracks, id)
+	return nil
+}
+
+func (r *MemoryLibraryRepository) filter(keep func(LibraryTrack) bool) []LibraryTrack {
+	r.Lock()
+	defer r.Unlock()
+
+	var tracks []LibraryTrack
+	for _, t := range r.tracks {
+		if keep(t) {
+			tracks = append(tracks, t)
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		a, b := tracks[i], tracks[j]
+		if a.Artist != b.Artist {
+			return a.Artist < b.Artist
+		}
+		if a.Album != b.Album {
+			return a.Album < b.Album
+		}
+		if a.TrackNumber != b.TrackNumber {
+			return a.TrackNumber < b.TrackNumber
+		}
+		return a.Title < b.Title
+	})
+	return tracks
+}
+
+type MemoryPodcastRepository struct {
+	sync.Mutex
+	feeds  map[uint]PodcastFeed
+	nextID uint
+}
+
+func NewMemoryPodcastRepository() *MemoryPodcastRepository {
+	return &MemoryPodcastRepository{
+		feeds:  make(map[uint]PodcastFeed),
+		nextID: 1,
+	}
+}
+
+func (r *MemoryPodcastRepository) Save(feed *PodcastFeed) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for id, f := range r.feeds {
+		if f.GuildID == feed.GuildID && f.URL == feed.URL {
+			feed.ID = id
+			r.feeds[id] = *feed
+			return nil
+		}
+	}
+
+	feed.ID = r.nextID
+	r.nextID++
+	r.feeds[feed.ID] = *feed
+	return nil
+}
+
+func (r *MemoryPodcastRepository) Get(guildID, url string) (*PodcastFeed, error) {
+	feeds := r.filter(func(f PodcastFeed) bool { return f.GuildID == guildID && f.URL == url })
+	if len(feeds) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &feeds[0], nil
+}
+
+func (r *MemoryPodcastRepository) GetAll(guildID string) ([]PodcastFeed, error) {
+	return r.filter(func(f PodcastFeed) bool { return f.GuildID == guildID }), nil
+}
+
+func (r *MemoryPodcastRepository) Delete(guildID, url string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for id, f := range r.feeds {
+		if f.GuildID == guildID && f.URL == url {
+			delete(r.feeds, id)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryPodcastRepository) filter(keep func(PodcastFeed) bool) []PodcastFeed {
+	r.Lock()
+	defer r.Unlock()
+
+	var feeds []PodcastFeed
+	for _, f := range r.feeds {
+		if keep(f) {
+			feeds = append(feeds, f)
+		}
+	}
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].ID < feeds[j].ID })
+	return feeds
+}
+
+type MemoryJobRepository struct {
+	sync.Mutex
+	jobs   map[uint]Job
+	nextID uint
+}
+
+func NewMemoryJobRepository() *MemoryJobRepository {
+	return &MemoryJobRepository{
+		jobs:   make(map[uint]Job),
+		nextID: 1,
+	}
+}
+
+func (r *MemoryJobRepository) Create(job *Job) error {
+	r.Lock()
+	defer r.Unlock()
+
+	job.ID = r.nextID
+	r.nextID++
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+func (r *MemoryJobRepository) Update(job *Job) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.jobs[job.ID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	job.UpdatedAt = time.Now()
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+func (r *MemoryJobRepository) Get(id uint) (*Job, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &job, nil
+}
+
+func (r *MemoryJobRepository) GetAll(guildID string, limit int) ([]Job, error) {
+	jobs := r.filter(func(j Job) bool { return j.GuildID == guildID })
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID > jobs[j].ID })
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+func (r *MemoryJobRepository) GetByStatus(statuses ...string) ([]Job, error) {
+	return r.filter(func(j Job) bool {
+		for _, status := range statuses {
+			if j.Status == status {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (r *MemoryJobRepository) filter(keep func(Job) bool) []Job {
+	r.Lock()
+	defer r.Unlock()
+
+	var jobs []Job
+	for _, j := range r.jobs {
+		if keep(j) {
+			jobs = append(jobs, j)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
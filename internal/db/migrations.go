@@ -0,0 +1,501 @@
+package db
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gookit/slog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// SchemaMigration records a migration that has been applied to the database.
+type SchemaMigration struct {
+	Version   string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+// Migration is a single ordered up-step. Up runs inside a transaction together
+// with the insertion of its schema_migrations record.
+type Migration struct {
+	Version     string
+	Description string
+	Up          func(tx *gorm.DB) error
+}
+
+// migrations is the ordered list of schema and data migrations.
+// Append new steps to the end, never reorder or edit already released ones.
+var migrations = []Migration{
+	{
+		Version:     "0001",
+		Description: "initial schema",
+		Up:          migrateInitialSchema,
+	},
+	{
+		Version:     "0002",
+		Description: "backfill missing track song ids",
+		Up:          migrateBackfillTrackSongIDs,
+	},
+	{
+		Version:     "0003",
+		Description: "merge tracks sharing the same song id",
+		Up:          migrateMergeDuplicateTracks,
+	},
+	{
+		Version:     "0004",
+		Description: "merge history records sharing the same guild and track",
+		Up:          migrateMergeDuplicateHistory,
+	},
+	{
+		Version:     "0005",
+		Description: "add unique index on history(guild_id, track_id)",
+		Up: func(tx *gorm.DB) error {
+			return createIndexIfNotExists(tx, &history0005{}, "idx_histories_guild_track")
+		},
+	},
+	{
+		Version:     "0006",
+		Description: "add unique index on tracks(song_id)",
+		Up:          migrateUniqueTrackSongIDs,
+	},
+	{
+		Version:     "0007",
+		Description: "add guild settings table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&guildSetting0007{})
+		},
+	},
+	{
+		Version:     "0008",
+		Description: "add library tracks table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&libraryTrack0008{})
+		},
+	},
+	{
+		Version:     "0009",
+		Description: "add podcast feeds table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&podcastFeed0009{})
+		},
+	},
+	{
+		Version:     "0010",
+		Description: "add jobs table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&job0010{})
+		},
+	},
+	{
+		Version:     "0011",
+		Description: "add content hash of local files to tracks",
+		Up:          migrateTrackContentHash,
+	},
+	{
+		Version:     "0012",
+		Description: "bound the size of indexed song and guild id columns",
+		Up: func(tx *gorm.DB) error {
+			if err := boundColumns(tx, &history0005{}, "GuildID"); err != nil {
+				return err
+			}
+			return boundColumns(tx, &track0006{}, "SongID")
+		},
+	},
+	{
+		Version:     "0013",
+		Description: "clear signed Subsonic stream URLs saved in tracks",
+		Up: func(tx *gorm.DB) error {
+			return tx.Model(&track0006{}).Where("song_id LIKE ?", "subsonic-%").Update("filepath", "").Error
+		},
+	},
+}
+
+// Migrate applies all pending migrations in order.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %v", err)
+	}
+
+	var applied []SchemaMigration
+	if err := db.Find(&applied).Error; err != nil {
+		return fmt.Errorf("error reading applied migrations: %v", err)
+	}
+
+	appliedVersions := make(map[string]bool, len(applied))
+	for _, m := range applied {
+		appliedVersions[m.Version] = true
+	}
+
+	for _, m := range migrations {
+		if appliedVersions[m.Version] {
+			continue
+		}
+
+		slog.Infof("Applying migration %v: %v", m.Version, m.Description)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("error applying migration %v (%v): %v", m.Version, m.Description, err)
+		}
+	}
+
+	return nil
+}
+
+// Frozen copies of the models as they were before migrations existed.
+// Migrations must not depend on the current models, which keep evolving.
+
+type guild0001 struct {
+	ID     string `gorm:"primaryKey"`
+	Name   string
+	Prefix string
+}
+
+func (guild0001) TableName() string { return "guilds" }
+
+type history0001 struct {
+	ID         uint `gorm:"primaryKey;autoIncrement"`
+	GuildID    string
+	TrackID    uint
+	PlayCount  uint
+	Duration   float64
+	LastPlayed time.Time
+}
+
+func (history0001) TableName() string { return "histories" }
+
+type track0001 struct {
+	ID       uint `gorm:"primaryKey;autoIncrement"`
+	SongID   string
+	Title    string
+	URL      string
+	Filepath string
+	Source   string
+}
+
+func (track0001) TableName() string { return "tracks" }
+
+type history0005 struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	GuildID    string `gorm:"size:191;uniqueIndex:idx_histories_guild_track"`
+	TrackID    uint   `gorm:"uniqueIndex:idx_histories_guild_track"`
+	PlayCount  uint
+	Duration   float64
+	LastPlayed time.Time
+}
+
+func (history0005) TableName() string { return "histories" }
+
+type track0006 struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement"`
+	SongID   string `gorm:"size:191;uniqueIndex:idx_tracks_song_id"`
+	Title    string
+	URL      string
+	Filepath string
+	Source   string
+}
+
+func (track0006) TableName() string { return "tracks" }
+
+type guildSetting0007 struct {
+	ID      uint   `gorm:"primaryKey;autoIncrement"`
+	GuildID string `gorm:"size:191;uniqueIndex:idx_guild_settings_guild_name"`
+	Name    string `gorm:"size:191;uniqueIndex:idx_guild_settings_guild_name"`
+	Value   string
+}
+
+func (guildSetting0007) TableName() string { return "guild_settings" }
+
+type libraryTrack0008 struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement"`
+	SongID      string `gorm:"size:191;uniqueIndex:idx_library_tracks_song_id"`
+	Path        string
+	Artist      string `gorm:"size:191;index:idx_library_tracks_artist"`
+	Album       string `gorm:"size:191;index:idx_library_tracks_album"`
+	Title       string
+	TrackNumber int
+	Duration    float64
+	CoverPath   string
+	Size        int64
+	ModTime     int64
+}
+
+func (libraryTrack0008) TableName() string { return "library_tracks" }
+
+type podcastFeed0009 struct {
+	ID              uint   `gorm:"primaryKey;autoIncrement"`
+	GuildID         string `gorm:"size:191;uniqueIndex:idx_podcast_feeds_guild_url"`
+	URL             string `gorm:"size:191;uniqueIndex:idx_podcast_feeds_guild_url"`
+	Title           string
+	LatestGUID      string
+	LatestPublished int64
+}
+
+func (podcastFeed0009) TableName() string { return "podcast_feeds" }
+
+type job0010 struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	GuildID    string `gorm:"size:191;index"`
+	Kind       string
+	Input      string
+	Status     string `gorm:"size:32;index"`
+	Stage      string
+	BytesDone  int64
+	BytesTotal int64
+	Processed  float64
+	Duration   float64
+	Result     string
+	Error      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (job0010) TableName() string { return "jobs" }
+
+type track0011 struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement"`
+	SongID      string `gorm:"size:191"`
+	Title       string
+	URL         string
+	Filepath    string
+	Source      string
+	ContentHash string `gorm:"size:64;index:idx_tracks_content_hash"`
+	FileSize    int64
+	FileModTime int64
+}
+
+func (track0011) TableName() string { return "tracks" }
+
+func migrateInitialSchema(tx *gorm.DB) error {
+	return tx.AutoMigrate(&guild0001{}, &history0001{}, &track0001{})
+}
+
+func migrateBackfillTrackSongIDs(tx *gorm.DB) error {
+	var tracks []track0001
+	if err := tx.Where("song_id = ? OR song_id IS NULL", "").Find(&tracks).Error; err != nil {
+		return err
+	}
+
+	for _, track := range tracks {
+		songID := deriveSongID(track.Source, track.URL, track.Title, track.Filepath)
+		if songID == "" {
+			continue
+		}
+		if err := tx.Model(&track0001{}).Where("id = ?", track.ID).Update("song_id", songID).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deriveSongID reproduces the ids the application assigns to tracks of each source.
+func deriveSongID(source, trackURL, title, filepath string) string {
+	if source == "YouTube" {
+		if id := youtubeVideoID(trackURL); id != "" {
+			return id
+		}
+	}
+
+	key := title
+	if key == "" {
+		key = filepath
+	}
+	if key == "" {
+		key = trackURL
+	}
+	if key == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", md5.Sum([]byte(key)))
+}
+
+func youtubeVideoID(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	if id := u.Query().Get("v"); id != "" {
+		return id
+	}
+	if strings.HasSuffix(u.Host, "youtu.be") {
+		return strings.Trim(u.Path, "/")
+	}
+	return ""
+}
+
+func migrateMergeDuplicateTracks(tx *gorm.DB) error {
+	var duplicates []string
+	err := tx.Model(&track0001{}).
+		Where("song_id <> ?", "").
+		Group("song_id").
+		Having("COUNT(*) > 1").
+		Pluck("song_id", &duplicates).Error
+	if err != nil {
+		return err
+	}
+
+	for _, songID := range duplicates {
+		var tracks []track0001
+		if err := tx.Where("song_id = ?", songID).Order("id ASC").Find(&tracks).Error; err != nil {
+			return err
+		}
+
+		keep := tracks[0]
+		for _, dup := range tracks[1:] {
+			if err := tx.Model(&history0001{}).Where("track_id = ?", dup.ID).Update("track_id", keep.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&track0001{}, dup.ID).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// migrateUniqueTrackSongIDs gives tracks 0002 could not derive a song id for
+// one of their own, empty ids would collide on the unique index.
+func migrateUniqueTrackSongIDs(tx *gorm.DB) error {
+	var ids []uint
+	if err := tx.Model(&track0006{}).Where("song_id = ? OR song_id IS NULL", "").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := tx.Model(&track0006{}).Where("id = ?", id).Update("song_id", fmt.Sprintf("track-%d", id)).Error; err != nil {
+			return err
+		}
+	}
+
+	return createIndexIfNotExists(tx, &track0006{}, "idx_tracks_song_id")
+}
+
+func migrateMergeDuplicateHistory(tx *gorm.DB) error {
+	type pair struct {
+		GuildID string
+		TrackID uint
+	}
+
+	var duplicates []pair
+	err := tx.Model(&history0001{}).
+		Select("guild_id, track_id").
+		Group("guild_id, track_id").
+		Having("COUNT(*) > 1").
+		Scan(&duplicates).Error
+	if err != nil {
+		return err
+	}
+
+	for _, p := range duplicates {
+		var records []history0001
+		if err := tx.Where("guild_id = ? AND track_id = ?", p.GuildID, p.TrackID).Order("id ASC").Find(&records).Error; err != nil {
+			return err
+		}
+
+		keep := records[0]
+		for _, dup := range records[1:] {
+			keep.PlayCount += dup.PlayCount
+			keep.Duration += dup.Duration
+			if dup.LastPlayed.After(keep.LastPlayed) {
+				keep.LastPlayed = dup.LastPlayed
+			}
+			if err := tx.Delete(&history0001{}, dup.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Save(&keep).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateTrackContentHash adds the columns one by one, AutoMigrate would also
+// try to alter the existing ones.
+func migrateTrackContentHash(tx *gorm.DB) error {
+	for _, column := range []string{"ContentHash", "FileSize", "FileModTime"} {
+		if tx.Migrator().HasColumn(&track0011{}, column) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(&track0011{}, column); err != nil {
+			return fmt.Errorf("error adding column %v: %v", column, err)
+		}
+	}
+	return createIndexIfNotExists(tx, &track0011{}, "idx_tracks_content_hash")
+}
+
+// createIndexIfNotExists creates an index declared by the model. MySQL can't
+// index text columns, the string columns of the index are bounded first.
+func createIndexIfNotExists(tx *gorm.DB, model interface{}, name string) error {
+	if tx.Migrator().HasIndex(model, name) {
+		return nil
+	}
+
+	if tx.Dialector.Name() == "mysql" {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		if index := stmt.Schema.LookIndex(name); index != nil {
+			for _, option := range index.Fields {
+				if option.Field.DataType == schema.String {
+					if err := boundColumns(tx, model, option.Field.Name); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return tx.Migrator().CreateIndex(model, name)
+}
+
+// boundColumns alters string columns to the size declared by the model, the
+// columns of databases created before sizes were declared being text. SQLite
+// ignores sizes.
+func boundColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	if tx.Dialector.Name() == "sqlite" {
+		return nil
+	}
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	columnTypes, err := tx.Migrator().ColumnTypes(model)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range fields {
+		field := stmt.Schema.LookUpField(name)
+		if field == nil || field.Size == 0 {
+			return fmt.Errorf("no size declared for %v", name)
+		}
+		for _, columnType := range columnTypes {
+			if columnType.Name() != field.DBName {
+				continue
+			}
+			if length, ok := columnType.Length(); ok && length == int64(field.Size) {
+				break
+			}
+			if err := tx.Migrator().AlterColumn(model, name); err != nil {
+				return fmt.Errorf("error altering column %v: %v", field.DBName, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateTracksWithoutSongID(t *testing.T) {
+	db, err := Open("sqlite://" + filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A database from before migrations, with tracks no song id can be derived for
+	if err := migrateInitialSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&track0001{Source: "LocalFile"})
+	db.Create(&track0001{Source: "LocalFile"})
+	db.Create(&track0001{Title: "Song", Source: "YouTube", URL: "https://www.youtube.com/watch?v=abc"})
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var songIDs []string
+	db.Model(&track0001{}).Order("id ASC").Pluck("song_id", &songIDs)
+	if len(songIDs) != 3 || songIDs[0] != "track-1" || songIDs[1] != "track-2" || songIDs[2] != "abc" {
+		t.Errorf("unexpected song ids: %v", songIDs)
+	}
+}
+
+func TestMigrateSubsonicStreamURLs(t *testing.T) {
+	db, err := Open("sqlite://" + filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateInitialSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&track0001{SongID: "subsonic-s1", Source: "Stream", Filepath: "https://music.example.com/rest/stream?id=s1&u=alice&t=token&s=salt"})
+	db.Create(&track0001{SongID: "radio", Source: "Stream", Filepath: "https://radio.example.com/live"})
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	db.Model(&track0001{}).Order("id ASC").Pluck("filepath", &paths)
+	if len(paths) != 2 || paths[0] != "" || paths[1] != "https://radio.example.com/live" {
+		t.Errorf("unexpected file paths: %v", paths)
+	}
+}
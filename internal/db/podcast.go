@@ -0,0 +1,48 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PodcastFeed is a podcast feed remembered by a guild, along with the newest
+// episode seen by `podcast check`.
+type PodcastFeed struct {
+	ID              uint   `gorm:"primaryKey;autoIncrement"`
+	GuildID         string `gorm:"size:191;uniqueIndex:idx_podcast_feeds_guild_url"`
+	URL             string `gorm:"size:191;uniqueIndex:idx_podcast_feeds_guild_url"`
+	Title           string
+	LatestGUID      string
+	LatestPublished int64 // unix seconds
+}
+
+type GormPodcastRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormPodcastRepository) Save(feed *PodcastFeed) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "guild_id"}, {Name: "url"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "latest_guid", "latest_published"}),
+	}).Create(feed).Error
+}
+
+func (r *GormPodcastRepository) Get(guildID, url string) (*PodcastFeed, error) {
+	var feed PodcastFeed
+	if err := r.db.Where("guild_id = ? AND url = ?", guildID, url).First(&feed).Error; err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+func (r *GormPodcastRepository) GetAll(guildID string) ([]PodcastFeed, error) {
+	var feeds []PodcastFeed
+	if err := r.db.Where("guild_id = ?", guildID).Order("id ASC").Find(&feeds).Error; err != nil {
+		return nil, err
+	}
+	return feeds, nil
+}
+
+func (r *GormPodcastRepository) Delete(guildID, url string) error {
+	return r.db.Where("guild_id = ? AND url = ?", guildID, url).Delete(&PodcastFeed{}).Error
+}
@@ -0,0 +1,109 @@
+package db
+
+import "gorm.io/gorm"
+
+type TrackRepository interface {
+	Create(track *Track) error
+	GetByID(id uint) (*Track, error)
+	GetBySongID(songID string) (*Track, error)
+	GetByFilepath(filepath string) (*Track, error)
+	GetByContentHash(hash string) ([]Track, error)
+	GetByURL(url string) (*Track, error)
+	GetAll() ([]Track, error)
+	Update(track *Track) error
+	Delete(track *Track) error
+	Exists(id uint) (bool, error)
+}
+
+type HistoryRepository interface {
+	Create(history *History) error
+	GetAllSortedBy(sortBy string) ([]History, error)
+	GetGuildSortedBy(guildID, sortBy string) ([]History, error)
+	ExistsForGuild(trackID uint, guildID string) (bool, error)
+	GetByTrackIDAndGuildID(trackID uint, guildID string) (*History, error)
+	UpdateStatsForGuild(trackID uint, guildID string, playCount uint, duration float64) error
+	DeleteByTrackID(trackID uint) error
+}
+
+type GuildRepository interface {
+	Create(guild Guild) error
+	GetByID(guildID string) (*Guild, error)
+	GetAllIDs() ([]string, error)
+	Exists(guildID string) (bool, error)
+	Delete(guildID string) error
+	SetPrefix(guildID string, prefix string) error
+	ResetPrefix(guildID string) error
+	GetPrefix(guildID string) (string, error)
+}
+
+type SettingRepository interface {
+	GetAll(guildID string) ([]GuildSetting, error)
+	Get(guildID, name string) (*GuildSetting, error)
+	Set(guildID, name, value string) error
+	Delete(guildID, name string) error
+	DeleteAll(guildID string) error
+}
+
+type LibraryRepository interface {
+	Save(track *LibraryTrack) error
+	GetBySongID(songID string) (*LibraryTrack, error)
+	GetAll() ([]LibraryTrack, error)
+	FindByArtist(artist string) ([]LibraryTrack, error)
+	FindByAlbum(album string) ([]LibraryTrack, error)
+	Count() (int64, error)
+	Delete(id uint) error
+}
+
+type PodcastRepository interface {
+	Save(feed *PodcastFeed) error
+	Get(guildID, url string) (*PodcastFeed, error)
+	GetAll(guildID string) ([]PodcastFeed, error)
+	Delete(guildID, url string) error
+}
+
+type JobRepository interface {
+	Create(job *Job) error
+	Update(job *Job) error
+	Get(id uint) (*Job, error)
+	GetAll(guildID string, limit int) ([]Job, error)
+	GetByStatus(statuses ...string) ([]Job, error)
+}
+
+// Repositories bundles the data access used across the application so it can be
+// handed to constructors as a single dependency.
+type Repositories struct {
+	Tracks    TrackRepository
+	Histories HistoryRepository
+	Guilds    GuildRepository
+	Settings  SettingRepository
+	Library   LibraryRepository
+	Podcasts  PodcastRepository
+	Jobs      JobRepository
+}
+
+// NewGormRepositories returns repositories backed by the given database.
+func NewGormRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Tracks:    &GormTrackRepository{db: db},
+		Histories: &GormHistoryRepository{db: db},
+		Guilds:    &GormGuildRepository{db: db},
+		Settings:  &GormSettingRepository{db: db},
+		Library:   &GormLibraryRepository{db: db},
+		Podcasts:  &GormPodcastRepository{db: db},
+		Jobs:      &GormJobRepository{db: db},
+	}
+}
+
+// NewMemoryRepositories returns repositories keeping everything in memory,
+// mainly useful for tests.
+func NewMemoryRepositories() *Repositories {
+	return &Repositories{
+		Tracks:    NewMemoryTrackRepository(),
+		Histories: NewMemoryHistoryRepository(),
+		Guilds:    NewMemoryGuildRepository(),
+		Settings:  NewMemorySettingRepository(),
+		Library:   NewMemoryLibraryRepository(),
+		Podcasts:  NewMemoryPodcastRepository(),
+		Jobs:      NewMemoryJobRepository(),
+	}
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GuildSetting is a single per-guild override of a global setting.
+type GuildSetting struct {
+	ID      uint   `gorm:"primaryKey;autoIncrement"`
+	GuildID string `gorm:"size:191;uniqueIndex:idx_guild_settings_guild_name"`
+	Name    string `gorm:"size:191;uniqueIndex:idx_guild_settings_guild_name"`
+	Value   string
+}
+
+type GormSettingRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormSettingRepository) GetAll(guildID string) ([]GuildSetting, error) {
+	var settings []GuildSetting
+	if err := r.db.Where("guild_id = ?", guildID).Order("name ASC").Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (r *GormSettingRepository) Get(guildID, name string) (*GuildSetting, error) {
+	var setting GuildSetting
+	if err := r.db.Where("guild_id = ? AND name = ?", guildID, name).First(&setting).Error; err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+func (r *GormSettingRepository) Set(guildID, name, value string) error {
+	setting := GuildSetting{GuildID: guildID, Name: name, Value: value}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "guild_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value"}),
+	}).Create(&setting).Error
+}
+
+func (r *GormSettingRepository) Delete(guildID, name string) error {
+	return r.db.Where("guild_id = ? AND name = ?", guildID, name).Delete(&GuildSetting{}).Error
+}
+
+func (r *GormSettingRepository) DeleteAll(guildID string) error {
+	return r.db.Where("guild_id = ?", guildID).Delete(&GuildSetting{}).Error
+}
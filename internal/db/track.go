@@ -1,63 +1,92 @@
 package db
 
+import "gorm.io/gorm"
+
 type Track struct {
-	ID        uint `gorm:"primaryKey;autoIncrement"`
-	SongID    string
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	SongID    string `gorm:"size:191;uniqueIndex:idx_tracks_song_id"`
 	Title     string
 	URL       string
 	Filepath  string
 	Source    string
 	Histories []History `gorm:"foreignKey:TrackID"`
+
+	// Local files are identified by the sha256 of their content, which is only
+	// recomputed when their size or modification time changes
+	ContentHash string `gorm:"size:64;index:idx_tracks_content_hash"`
+	FileSize    int64
+	FileModTime int64 // unix nanoseconds
+}
+
+type GormTrackRepository struct {
+	db *gorm.DB
 }
 
-func CreateTrack(track *Track) error {
-	return DB.Create(track).Error
+func (r *GormTrackRepository) Create(track *Track) error {
+	return r.db.Create(track).Error
 }
 
-func GetTrackByID(id uint) (*Track, error) {
+func (r *GormTrackRepository) GetByID(id uint) (*Track, error) {
 	var track Track
-	if err := DB.First(&track, id).Error; err != nil {
+	if err := r.db.First(&track, id).Error; err != nil {
 		return nil, err
 	}
 	return &track, nil
 }
 
-func GetTrackBySongID(songID string) (*Track, error) {
+func (r *GormTrackRepository) GetBySongID(songID string) (*Track, error) {
 	var track Track
-	if err := DB.Where("song_id = ?", songID).First(&track).Error; err != nil {
+	if err := r.db.Where("song_id = ?", songID).First(&track).Error; err != nil {
 		return nil, err
 	}
 	return &track, nil
 }
 
-func UpdateTrack(track *Track) error {
-	return DB.Save(track).Error
+func (r *GormTrackRepository) Update(track *Track) error {
+	return r.db.Save(track).Error
 }
 
-func DeleteTrack(track *Track) error {
-	return DB.Delete(track).Error
+func (r *GormTrackRepository) Delete(track *Track) error {
+	return r.db.Delete(track).Error
 }
 
-func GetAllTracks() ([]Track, error) {
+func (r *GormTrackRepository) GetAll() ([]Track, error) {
 	var tracks []Track
-	if err := DB.Find(&tracks).Error; err != nil {
+	if err := r.db.Find(&tracks).Error; err != nil {
 		return nil, err
 	}
 	return tracks, nil
 }
 
-func GetTrackByFilepath(filepath string) (*Track, error) {
+func (r *GormTrackRepository) GetByFilepath(filepath string) (*Track, error) {
 	var track Track
-	if err := DB.Where("filepath = ?", filepath).First(&track).Error; err != nil {
+	if err := r.db.Where("filepath = ?", filepath).First(&track).Error; err != nil {
 		return nil, err
 	}
 	return &track, nil
 }
 
-func GetTrackByURL(url string) (*Track, error) {
+func (r *GormTrackRepository) GetByContentHash(hash string) ([]Track, error) {
+	var tracks []Track
+	if err := r.db.Where("content_hash = ?", hash).Order("id ASC").Find(&tracks).Error; err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+func (r *GormTrackRepository) GetByURL(url string) (*Track, error) {
 	var track Track
-	if err := DB.Where("url = ?", url).First(&track).Error; err != nil {
+	if err := r.db.Where("url = ?", url).First(&track).Error; err != nil {
 		return nil, err
 	}
 	return &track, nil
 }
+
+func (r *GormTrackRepository) Exists(id uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&Track{}).Where("id = ?", id).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
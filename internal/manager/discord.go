@@ -11,6 +11,7 @@ import (
 	"github.com/keshon/melodix-player/internal/botsdef"
 	"github.com/keshon/melodix-player/internal/config"
 	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/internal/settings"
 )
 
 type IGuildManager interface {
@@ -24,9 +25,12 @@ type GuildManager struct {
 	Bots          map[string]map[string]botsdef.Discord
 	customPrefix  string
 	commandPrefix string
+	adminUserID   string
+	repos         *db.Repositories
+	settings      settings.ISettings
 }
 
-func NewGuildManager(session *discordgo.Session, bots map[string]map[string]botsdef.Discord) IGuildManager {
+func NewGuildManager(session *discordgo.Session, bots map[string]map[string]botsdef.Discord, repos *db.Repositories) IGuildManager {
 	config, err := config.NewConfig()
 	if err != nil {
 		slog.Fatalf("Error loading config:", err)
@@ -38,6 +42,9 @@ func NewGuildManager(session *discordgo.Session, bots map[string]map[string]bots
 		Message:       nil,
 		Bots:          bots,
 		commandPrefix: config.DiscordCommandPrefix,
+		adminUserID:   config.DiscordAdminUserID,
+		repos:         repos,
+		settings:      settings.NewSettings(repos),
 	}
 }
 
@@ -66,7 +73,7 @@ func (gm *GuildManager) Commands(s *discordgo.Session, m *discordgo.MessageCreat
 		return
 	}
 
-	command, _, err := gm.splitCommandFromParameter(messageContentLower, gm.getEffectiveCommandPrefix())
+	command, param, err := gm.splitCommandFromParameter(messageContentLower, gm.getEffectiveCommandPrefix())
 	if err != nil {
 		slog.Error(err)
 		return
@@ -85,6 +92,11 @@ func (gm *GuildManager) Commands(s *discordgo.Session, m *discordgo.MessageCreat
 		{"about", "v"},
 		{"cached"},
 		{"uploaded"},
+		{"library", "lib"},
+		{"podcast", "pod"},
+		{"radio", "fm"},
+		{"jobs"},
+		{"settings"},
 	}
 
 	var commandsList []string
@@ -102,7 +114,7 @@ func (gm *GuildManager) Commands(s *discordgo.Session, m *discordgo.MessageCreat
 
 	if found {
 		guildID := m.GuildID
-		exists, err := db.DoesGuildExist(guildID)
+		exists, err := gm.repos.Guilds.Exists(guildID)
 		if err != nil {
 			slog.Errorf("Error checking if guild is registered: %v", err)
 			return
@@ -121,13 +133,15 @@ func (gm *GuildManager) Commands(s *discordgo.Session, m *discordgo.MessageCreat
 		gm.handleUnregisterCommand()
 	case "whoami":
 		gm.handleWhoamiCommand()
+	case "settings":
+		gm.handleSettingsCommand(param)
 	}
 }
 
 func (gm *GuildManager) handleRegisterCommand() {
 	guildID := gm.GuildID
 
-	exists, err := db.DoesGuildExist(guildID)
+	exists, err := gm.repos.Guilds.Exists(guildID)
 	if err != nil {
 		slog.Errorf("Error checking if guild is registered: %v", err)
 		return
@@ -139,7 +153,7 @@ func (gm *GuildManager) handleRegisterCommand() {
 	}
 
 	guild := db.Guild{ID: guildID, Name: ""}
-	err = db.CreateGuild(guild)
+	err = gm.repos.Guilds.Create(guild)
 	if err != nil {
 		slog.Errorf("Error registering guild: %v", err)
 		gm.sendMessageEmbed(fmt.Sprintf("Error registering guild\n`%v`", err))
@@ -153,7 +167,7 @@ func (gm *GuildManager) handleRegisterCommand() {
 func (gm *GuildManager) handleUnregisterCommand() {
 	guildID := gm.GuildID
 
-	exists, err := db.DoesGuildExist(guildID)
+	exists, err := gm.repos.Guilds.Exists(guildID)
 	if err != nil {
 		slog.Errorf("Error checking if guild is registered: %v", err)
 		return
@@ -164,7 +178,7 @@ func (gm *GuildManager) handleUnregisterCommand() {
 		return
 	}
 
-	err = db.DeleteGuild(guildID)
+	err = gm.repos.Guilds.Delete(guildID)
 	if err != nil {
 		slog.Errorf("Error unregistering guild: %v", err)
 		gm.sendMessageEmbed(fmt.Sprintf("Error registering guild\n`%v`", err))
@@ -183,7 +197,7 @@ func (gm *GuildManager) handleWhoamiCommand() {
 
 func (gm *GuildManager) handleSetCustomPrefixCommand(param string) {
 	slog.Error(param)
-	err := db.SetGuildPrefix(gm.GuildID, param)
+	err := gm.repos.Guilds.SetPrefix(gm.GuildID, param)
 	if err != nil {
 		slog.Errorf("Error setting custom prefix: %v", err)
 		gm.sendMessageEmbed(fmt.Sprintf("Error setting custom prefix\n`%v`", err.Error()))
@@ -198,7 +212,7 @@ func (gm *GuildManager) handleSetCustomPrefixCommand(param string) {
 }
 
 func (gm *GuildManager) handleResetPrefixCommand() {
-	err := db.ResetGuildPrefix(gm.GuildID)
+	err := gm.repos.Guilds.ResetPrefix(gm.GuildID)
 	if err != nil {
 		slog.Errorf("Error reseting prefix", err)
 		gm.sendMessageEmbed(fmt.Sprintf("Error reseting prefix\n`%v`", err.Error()))
@@ -227,7 +241,7 @@ func (gm *GuildManager) setupBotInstance(guildID string) {
 	}
 
 	for _, module := range botsdef.Modules {
-		botInstance := botsdef.CreateBotInstance(session, module)
+		botInstance := botsdef.CreateBotInstance(session, module, gm.repos)
 		if botInstance != nil {
 			gm.Bots[id][module] = botInstance
 			botInstance.Start(id, gm.getEffectiveCommandPrefix())
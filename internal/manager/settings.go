@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gookit/slog"
+)
+
+// Examples:
+// !settings
+// !settings set volume 50
+// !settings reset volume
+// !settings reset
+func (gm *GuildManager) handleSettingsCommand(param string) {
+	args := strings.Fields(param)
+
+	action := "show"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	if action != "show" && !gm.canManageSettings() {
+		gm.sendMessageEmbed("Only server managers can change settings.")
+		return
+	}
+
+	switch {
+	case action == "show":
+		gm.handleShowSettings()
+	case action == "set" && len(args) >= 3:
+		key, value := args[1], strings.Join(args[2:], " ")
+		if err := gm.settings.Set(gm.GuildID, key, value); err != nil {
+			gm.sendMessageEmbed(fmt.Sprintf("Error updating setting\n`%v`", err))
+			return
+		}
+		gm.sendMessageEmbed(fmt.Sprintf("Setting `%v` updated", strings.ToLower(key)))
+	case action == "reset" && len(args) == 2:
+		if err := gm.settings.Reset(gm.GuildID, args[1]); err != nil {
+			gm.sendMessageEmbed(fmt.Sprintf("Error resetting setting\n`%v`", err))
+			return
+		}
+		gm.sendMessageEmbed(fmt.Sprintf("Setting `%v` reset to default", strings.ToLower(args[1])))
+	case action == "reset" && len(args) == 1:
+		if err := gm.settings.ResetAll(gm.GuildID); err != nil {
+			gm.sendMessageEmbed(fmt.Sprintf("Error resetting settings\n`%v`", err))
+			return
+		}
+		gm.sendMessageEmbed("All settings reset to defaults")
+	default:
+		prefix := gm.getEffectiveCommandPrefix()
+		gm.sendMessageEmbed(fmt.Sprintf("Usage:\n`%vsettings` — show settings\n`%vsettings set [key] [value]` — override setting\n`%vsettings reset [key]` — reset setting (all if no key given)", prefix, prefix, prefix))
+	}
+}
+
+func (gm *GuildManager) handleShowSettings() {
+	entries, err := gm.settings.Show(gm.GuildID)
+	if err != nil {
+		slog.Errorf("Error getting settings: %v", err)
+		gm.sendMessageEmbed(fmt.Sprintf("Error getting settings\n`%v`", err))
+		return
+	}
+
+	content := "⚙️ Guild settings\n\n"
+	for _, e := range entries {
+		value := e.Value
+		if value == "" {
+			value = "not set"
+		}
+
+		source := "default"
+		if e.Override {
+			source = "guild"
+		}
+
+		content += fmt.Sprintf("`%v` = `%v` (%v)\n%v\n\n", e.Key, value, source, e.Description)
+	}
+
+	gm.sendMessageEmbed(content)
+}
+
+func (gm *GuildManager) canManageSettings() bool {
+	m := gm.Message
+
+	if m.Author.ID == gm.adminUserID {
+		return true
+	}
+
+	permissions, err := gm.Session.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		slog.Errorf("Error getting user permissions: %v", err)
+		return false
+	}
+
+	return permissions&discordgo.PermissionManageServer != 0
+}
@@ -1,16 +1,21 @@
 package rest
 
 import (
+	"errors"
 	"io"
 
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gookit/slog"
 
 	"github.com/keshon/melodix-player/internal/botsdef"
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/internal/settings"
 	"github.com/keshon/melodix-player/mods/music/history"
+	"github.com/keshon/melodix-player/mods/music/jobs"
 )
 
 type IRest interface {
@@ -18,12 +23,14 @@ type IRest interface {
 }
 
 type Rest struct {
-	Bots map[string]map[string]botsdef.Discord
+	Bots  map[string]map[string]botsdef.Discord
+	repos *db.Repositories
 }
 
-func NewRest(bots map[string]map[string]botsdef.Discord) IRest {
+func NewRest(bots map[string]map[string]botsdef.Discord, repos *db.Repositories) IRest {
 	return &Rest{
-		Bots: bots,
+		Bots:  bots,
+		repos: repos,
 	}
 }
 
@@ -38,6 +45,8 @@ func (r *Rest) Start(router *gin.Engine) {
 	r.registerLogsRoutes(router.Group("/logs"))
 	r.registerGuildRoutes(router.Group("/guild"))
 	r.registerHistoryRoutes(router.Group("/history"))
+	r.registerSettingsRoutes(router.Group("/settings"))
+	r.registerJobsRoutes(router.Group("/jobs"))
 }
 
 type GuildInfo struct {
@@ -137,7 +146,7 @@ func (r *Rest) registerGuildRoutes(router *gin.RouterGroup) {
 func (r *Rest) registerHistoryRoutes(router *gin.RouterGroup) {
 	router.GET("/", func(ctx *gin.Context) {
 
-		h := history.NewHistory()
+		h := history.NewHistory(r.repos)
 
 		history, err := h.GetHistory("", "last_played") // You need to pass appropriate arguments for sorting
 		if err != nil {
@@ -151,7 +160,7 @@ func (r *Rest) registerHistoryRoutes(router *gin.RouterGroup) {
 	router.GET("/:guild_id", func(ctx *gin.Context) {
 		guildID := ctx.Param("guild_id")
 
-		h := history.NewHistory()
+		h := history.NewHistory(r.repos)
 
 		history, err := h.GetHistory(guildID, "last_played") // You need to pass appropriate arguments for sorting
 		if err != nil {
@@ -162,3 +171,114 @@ func (r *Rest) registerHistoryRoutes(router *gin.RouterGroup) {
 		ctx.JSON(http.StatusOK, history)
 	})
 }
+
+// Examples:
+// GET http://localhost:8080/settings/897053062030585916
+// PUT http://localhost:8080/settings/897053062030585916/volume (body: {"value": "50"})
+// DELETE http://localhost:8080/settings/897053062030585916/volume
+// DELETE http://localhost:8080/settings/897053062030585916
+func (r *Rest) registerSettingsRoutes(router *gin.RouterGroup) {
+	s := settings.NewSettings(r.repos)
+
+	router.GET("/:guild_id", func(ctx *gin.Context) {
+		entries, err := s.Show(ctx.Param("guild_id"))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, entries)
+	})
+
+	router.PUT("/:guild_id/:key", func(ctx *gin.Context) {
+		var body struct {
+			Value string `json:"value"`
+		}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := s.Set(ctx.Param("guild_id"), ctx.Param("key"), body.Value); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, "Setting updated")
+	})
+
+	router.DELETE("/:guild_id/:key", func(ctx *gin.Context) {
+		if err := s.Reset(ctx.Param("guild_id"), ctx.Param("key")); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, "Setting reset")
+	})
+
+	router.DELETE("/:guild_id", func(ctx *gin.Context) {
+		if err := s.ResetAll(ctx.Param("guild_id")); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, "Settings reset")
+	})
+}
+
+// Examples:
+// GET http://localhost:8080/jobs/897053062030585916?limit=20
+// GET http://localhost:8080/jobs/897053062030585916/12
+// DELETE http://localhost:8080/jobs/897053062030585916/12
+func (r *Rest) registerJobsRoutes(router *gin.RouterGroup) {
+	router.GET("/:guild_id", func(ctx *gin.Context) {
+		limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+
+		list, err := jobs.Shared(r.repos).List(ctx.Param("guild_id"), limit)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, list)
+	})
+
+	router.GET("/:guild_id/:job_id", func(ctx *gin.Context) {
+		id, err := strconv.ParseUint(ctx.Param("job_id"), 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+			return
+		}
+
+		job, err := jobs.Shared(r.repos).Get(ctx.Param("guild_id"), uint(id))
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, job)
+	})
+
+	router.DELETE("/:guild_id/:job_id", func(ctx *gin.Context) {
+		id, err := strconv.ParseUint(ctx.Param("job_id"), 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+			return
+		}
+
+		if err := jobs.Shared(r.repos).Cancel(ctx.Param("guild_id"), uint(id)); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, jobs.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			ctx.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, "Job canceled")
+	})
+}
@@ -0,0 +1,387 @@
+// Package settings layers per-guild overrides stored in the database over the global config.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/db"
+)
+
+const (
+	KeyBitrate         = "bitrate"
+	KeyVolume          = "volume"
+	KeyIdleTimeout     = "idle_timeout"
+	KeyMaxQueueLength  = "max_queue_length"
+	KeyAllowedSources  = "allowed_sources"
+	KeyAnnounce        = "announce_channel"
+	KeyDJRole          = "dj_role"
+	KeyCacheQuotaMB    = "cache_quota_mb"
+	KeyCacheQuotaFiles = "cache_quota_files"
+	KeyRetentionDays   = "retention_days"
+	KeyRetentionTop    = "retention_keep_top"
+)
+
+// Source names accepted by the allowed_sources setting.
+const (
+	SourceYouTube   = "youtube"
+	SourceStream    = "stream"
+	SourceLocalFile = "localfile"
+)
+
+var allSources = []string{SourceYouTube, SourceStream, SourceLocalFile}
+
+// GuildSettings holds the effective settings of a guild.
+type GuildSettings struct {
+	Bitrate           int
+	Volume            int // percent, 0-100
+	IdleTimeout       time.Duration
+	MaxQueueLength    int // 0 means unlimited
+	AllowedSources    []string
+	AnnounceChannelID string
+	DJRoleID          string
+	CacheQuotaMB      int // 0 means unlimited
+	CacheQuotaFiles   int // 0 means unlimited
+	RetentionDays     int // cached tracks not played for as long are deleted, 0 disables it
+	RetentionKeepTop  int // only as many most played cached tracks are kept, 0 disables it
+}
+
+// IsSourceAllowed reports whether tracks of the given source (see Source* constants) may be queued.
+func (gs *GuildSettings) IsSourceAllowed(source string) bool {
+	for _, s := range gs.AllowedSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Entry describes a single setting as shown to users.
+type Entry struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Override    bool   `json:"override"`
+	Description string `json:"description"`
+}
+
+type ISettings interface {
+	Get(guildID string) (*GuildSettings, error)
+	Show(guildID string) ([]Entry, error)
+	Set(guildID, key, value string) error
+	Reset(guildID, key string) error
+	ResetAll(guildID string) error
+}
+
+type Settings struct {
+	repos    *db.Repositories
+	defaults func() (*GuildSettings, error)
+}
+
+func NewSettings(repos *db.Repositories) ISettings {
+	return &Settings{
+		repos:    repos,
+		defaults: configDefaults.get,
+	}
+}
+
+type definition struct {
+	description string
+	normalize   func(value string) (string, error)
+	apply       func(gs *GuildSettings, value string)
+	format      func(gs *GuildSettings) string
+}
+
+var (
+	snowflakeRegex = regexp.MustCompile(`^\d{17,20}$`)
+	channelRegex   = regexp.MustCompile(`^<#(\d{17,20})>$`)
+	roleRegex      = regexp.MustCompile(`^<@&(\d{17,20})>$`)
+)
+
+var definitions = map[string]definition{
+	KeyBitrate: {
+		description: "Opus bitrate in kbps (8-128)",
+		normalize:   intInRange(8, 128),
+		apply:       func(gs *GuildSettings, v string) { gs.Bitrate, _ = strconv.Atoi(v) },
+		format:      func(gs *GuildSettings) string { return strconv.Itoa(gs.Bitrate) },
+	},
+	KeyVolume: {
+		description: "Default volume in percent (0-100)",
+		normalize:   intInRange(0, 100),
+		apply:       func(gs *GuildSettings, v string) { gs.Volume, _ = strconv.Atoi(v) },
+		format:      func(gs *GuildSettings) string { return strconv.Itoa(gs.Volume) },
+	},
+	KeyIdleTimeout: {
+		description: "How long to stay in the voice channel after the queue ends, e.g. 5m (0 leaves immediately)",
+		normalize:   normalizeDuration,
+		apply:       func(gs *GuildSettings, v string) { gs.IdleTimeout, _ = time.ParseDuration(v) },
+		format:      func(gs *GuildSettings) string { return gs.IdleTimeout.String() },
+	},
+	KeyMaxQueueLength: {
+		description: "Maximum number of tracks in the queue (0 is unlimited)",
+		normalize:   intInRange(0, 10000),
+		apply:       func(gs *GuildSettings, v string) { gs.MaxQueueLength, _ = strconv.Atoi(v) },
+		format:      func(gs *GuildSettings) string { return strconv.Itoa(gs.MaxQueueLength) },
+	},
+	KeyAllowedSources: {
+		description: "Comma separated list of youtube, stream (radio, podcasts, Subsonic..), localfile (or all)",
+		normalize:   normalizeSources,
+		apply:       func(gs *GuildSettings, v string) { gs.AllowedSources = strings.Split(v, ",") },
+		format:      func(gs *GuildSettings) string { return strings.Join(gs.AllowedSources, ",") },
+	},
+	KeyAnnounce: {
+		description: "Channel to announce tracks started from the queue",
+		normalize:   normalizeID(channelRegex),
+		apply:       func(gs *GuildSettings, v string) { gs.AnnounceChannelID = v },
+		format:      func(gs *GuildSettings) string { return gs.AnnounceChannelID },
+	},
+	KeyCacheQuotaMB: {
+		description: "Size limit of the cache folder in MB (0 is unlimited)",
+		normalize:   intInRange(0, 1<<20),
+		apply:       func(gs *GuildSettings, v string) { gs.CacheQuotaMB, _ = strconv.Atoi(v) },
+		format:      func(gs *GuildSettings) string { return strconv.Itoa(gs.CacheQuotaMB) },
+	},
+	KeyCacheQuotaFiles: {
+		description: "Maximum number of files in the cache folder (0 is unlimited)",
+		normalize:   intInRange(0, 1000000),
+		apply:       func(gs *GuildSettings, v string) { gs.CacheQuotaFiles, _ = strconv.Atoi(v) },
+		format:      func(gs *GuildSettings) string { return strconv.Itoa(gs.CacheQuotaFiles) },
+	},
+	KeyRetentionDays: {
+		description: "Delete cached tracks not played for this many days (0 keeps them)",
+		normalize:   intInRange(0, 3650),
+		apply:       func(gs *GuildSettings, v string) { gs.RetentionDays, _ = strconv.Atoi(v) },
+		format:      func(gs *GuildSettings) string { return strconv.Itoa(gs.RetentionDays) },
+	},
+	KeyRetentionTop: {
+		description: "Keep only this many most played cached tracks (0 keeps all)",
+		normalize:   intInRange(0, 1000000),
+		apply:       func(gs *GuildSettings, v string) { gs.RetentionKeepTop, _ = strconv.Atoi(v) },
+		format:      func(gs *GuildSettings) string { return strconv.Itoa(gs.RetentionKeepTop) },
+	},
+	KeyDJRole: {
+		description: "Role required to control playback (empty allows everyone)",
+		normalize:   normalizeID(roleRegex),
+		apply:       func(gs *GuildSettings, v string) { gs.DJRoleID = v },
+		format:      func(gs *GuildSettings) string { return gs.DJRoleID },
+	},
+}
+
+// Keys returns all known setting keys in alphabetical order.
+func Keys() []string {
+	keys := make([]string, 0, len(definitions))
+	for key := range definitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Settings) Get(guildID string) (*GuildSettings, error) {
+	gs, err := s.defaults()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := s.repos.Settings.GetAll(guildID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting settings for guild %v: %v", guildID, err)
+	}
+
+	for _, o := range overrides {
+		def, ok := definitions[o.Name]
+		if !ok {
+			continue
+		}
+		value, err := def.normalize(o.Value)
+		if err != nil {
+			continue
+		}
+		def.apply(gs, value)
+	}
+
+	return gs, nil
+}
+
+func (s *Settings) Show(guildID string) ([]Entry, error) {
+	gs, err := s.Get(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := s.repos.Settings.GetAll(guildID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting settings for guild %v: %v", guildID, err)
+	}
+
+	overridden := make(map[string]bool, len(overrides))
+	for _, o := range overrides {
+		overridden[o.Name] = true
+	}
+
+	var entries []Entry
+	for _, key := range Keys() {
+		def := definitions[key]
+		entries = append(entries, Entry{
+			Key:         key,
+			Value:       def.format(gs),
+			Override:    overridden[key],
+			Description: def.description,
+		})
+	}
+
+	return entries, nil
+}
+
+func (s *Settings) Set(guildID, key, value string) error {
+	def, ok := definitions[strings.ToLower(key)]
+	if !ok {
+		return fmt.Errorf("unknown setting %q, available: %v", key, strings.Join(Keys(), ", "))
+	}
+
+	value, err := def.normalize(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid value for %v: %v", key, err)
+	}
+
+	return s.repos.Settings.Set(guildID, strings.ToLower(key), value)
+}
+
+func (s *Settings) Reset(guildID, key string) error {
+	if _, ok := definitions[strings.ToLower(key)]; !ok {
+		return fmt.Errorf("unknown setting %q, available: %v", key, strings.Join(Keys(), ", "))
+	}
+
+	return s.repos.Settings.Delete(guildID, strings.ToLower(key))
+}
+
+func (s *Settings) ResetAll(guildID string) error {
+	return s.repos.Settings.DeleteAll(guildID)
+}
+
+// cachedDefaults keeps the defaults computed from a config snapshot until the
+// config is reloaded.
+type cachedDefaults struct {
+	mu         sync.Mutex
+	generation uint64
+	defaults   *GuildSettings
+	compute    func() (*GuildSettings, error)
+}
+
+var configDefaults = &cachedDefaults{compute: defaultsFromConfig}
+
+// get returns a copy of the defaults, callers may modify it.
+func (c *cachedDefaults) get() (*GuildSettings, error) {
+	generation := config.Generation()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.defaults == nil || c.generation != generation {
+		gs, err := c.compute()
+		if err != nil {
+			return nil, err
+		}
+		c.defaults, c.generation = gs, generation
+	}
+
+	gs := *c.defaults
+	gs.AllowedSources = append([]string(nil), c.defaults.AllowedSources...)
+	return &gs, nil
+}
+
+func defaultsFromConfig() (*GuildSettings, error) {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %v", err)
+	}
+
+	return &GuildSettings{
+		Bitrate:          cfg.DcaBitrate,
+		Volume:           100,
+		AllowedSources:   append([]string(nil), allSources...),
+		CacheQuotaMB:     cfg.QuotaCacheMB,
+		CacheQuotaFiles:  cfg.QuotaCacheFiles,
+		RetentionDays:    cfg.RetentionUnplayedDays,
+		RetentionKeepTop: cfg.RetentionKeepTop,
+	}, nil
+}
+
+func intInRange(min, max int) func(string) (string, error) {
+	return func(value string) (string, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a number", value)
+		}
+		if n < min || n > max {
+			return "", fmt.Errorf("%v is out of range %v-%v", n, min, max)
+		}
+		return strconv.Itoa(n), nil
+	}
+}
+
+func normalizeDuration(value string) (string, error) {
+	if value == "0" {
+		return "0s", nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a duration (use e.g. 30s, 5m, 1h)", value)
+	}
+	if d < 0 || d > 24*time.Hour {
+		return "", fmt.Errorf("%v is out of range 0-24h", d)
+	}
+	return d.String(), nil
+}
+
+func normalizeSources(value string) (string, error) {
+	if strings.EqualFold(value, "all") {
+		return strings.Join(allSources, ","), nil
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.FieldsFunc(strings.ToLower(value), func(r rune) bool { return r == ',' || r == ' ' }) {
+		valid := false
+		for _, source := range allSources {
+			if part == source {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", fmt.Errorf("unknown source %q, available: %v", part, strings.Join(allSources, ", "))
+		}
+		seen[part] = true
+	}
+
+	var sources []string
+	for _, source := range allSources {
+		if seen[source] {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return "", errors.New("at least one source must be allowed")
+	}
+
+	return strings.Join(sources, ","), nil
+}
+
+func normalizeID(mention *regexp.Regexp) func(string) (string, error) {
+	return func(value string) (string, error) {
+		if snowflakeRegex.MatchString(value) {
+			return value, nil
+		}
+		if matches := mention.FindStringSubmatch(value); matches != nil {
+			return matches[1], nil
+		}
+		return "", fmt.Errorf("%q is not a valid id or mention", value)
+	}
+}
@@ -0,0 +1,144 @@
+package settings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/db"
+)
+
+func newTestSettings() *Settings {
+	return &Settings{
+		repos: db.NewMemoryRepositories(),
+		defaults: func() (*GuildSettings, error) {
+			return &GuildSettings{Bitrate: 96, Volume: 100, AllowedSources: []string{SourceYouTube, SourceStream, SourceLocalFile}}, nil
+		},
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	s := newTestSettings()
+
+	values := map[string]string{
+		KeyBitrate:        "64",
+		KeyVolume:         "50",
+		KeyIdleTimeout:    "5m",
+		KeyMaxQueueLength: "20",
+		KeyAllowedSources: "stream, youtube",
+		KeyAnnounce:       "<#123456789012345678>",
+		KeyDJRole:         "<@&223456789012345678>",
+	}
+	for key, value := range values {
+		if err := s.Set("guild", key, value); err != nil {
+			t.Fatalf("Set(%v, %v) returned error: %v", key, value, err)
+		}
+	}
+
+	gs, err := s.Get("guild")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gs.Bitrate != 64 || gs.Volume != 50 || gs.IdleTimeout != 5*time.Minute || gs.MaxQueueLength != 20 {
+		t.Errorf("unexpected numeric settings: %+v", gs)
+	}
+	if gs.AnnounceChannelID != "123456789012345678" || gs.DJRoleID != "223456789012345678" {
+		t.Errorf("unexpected ids: %+v", gs)
+	}
+	if !gs.IsSourceAllowed(SourceStream) || gs.IsSourceAllowed(SourceLocalFile) {
+		t.Errorf("unexpected allowed sources: %v", gs.AllowedSources)
+	}
+
+	other, _ := s.Get("other")
+	if other.Bitrate != 96 || !other.IsSourceAllowed(SourceLocalFile) {
+		t.Errorf("overrides leaked into another guild: %+v", other)
+	}
+}
+
+func TestSetValidation(t *testing.T) {
+	s := newTestSettings()
+
+	invalid := [][2]string{
+		{"unknown", "1"},
+		{KeyBitrate, "512"},
+		{KeyBitrate, "loud"},
+		{KeyVolume, "-1"},
+		{KeyIdleTimeout, "forever"},
+		{KeyAllowedSources, "spotify"},
+		{KeyAnnounce, "#general"},
+		{KeyDJRole, "<@123456789012345678>"},
+	}
+	for _, tt := range invalid {
+		if err := s.Set("guild", tt[0], tt[1]); err == nil {
+			t.Errorf("Set(%v, %v) expected error", tt[0], tt[1])
+		}
+	}
+}
+
+func TestShowAndReset(t *testing.T) {
+	s := newTestSettings()
+
+	s.Set("guild", KeyVolume, "30")
+	s.Set("guild", KeyBitrate, "32")
+
+	entries, err := s.Show("guild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(Keys()) {
+		t.Fatalf("expected %d entries, got %d", len(Keys()), len(entries))
+	}
+	for _, e := range entries {
+		if e.Key == KeyVolume && (!e.Override || e.Value != "30") {
+			t.Errorf("unexpected volume entry: %+v", e)
+		}
+		if e.Key == KeyAllowedSources && e.Override {
+			t.Errorf("allowed sources should not be overridden: %+v", e)
+		}
+	}
+
+	if err := s.Reset("guild", KeyVolume); err != nil {
+		t.Fatal(err)
+	}
+	gs, _ := s.Get("guild")
+	if gs.Volume != 100 || gs.Bitrate != 32 {
+		t.Errorf("unexpected settings after reset: %+v", gs)
+	}
+
+	if err := s.ResetAll("guild"); err != nil {
+		t.Fatal(err)
+	}
+	gs, _ = s.Get("guild")
+	if gs.Bitrate != 96 {
+		t.Errorf("unexpected settings after reset all: %+v", gs)
+	}
+}
+
+func TestCachedDefaults(t *testing.T) {
+	calls := 0
+	c := &cachedDefaults{compute: func() (*GuildSettings, error) {
+		calls++
+		return &GuildSettings{Bitrate: 96, AllowedSources: []string{SourceYouTube}}, nil
+	}}
+
+	gs, err := c.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs.Bitrate = 64
+	gs.AllowedSources[0] = SourceStream
+
+	gs, err = c.get()
+	if err != nil || calls != 1 {
+		t.Fatalf("expected the defaults to be computed once, got %v calls, %v", calls, err)
+	}
+	if gs.Bitrate != 96 || gs.AllowedSources[0] != SourceYouTube {
+		t.Errorf("cached defaults are modified: %+v", gs)
+	}
+
+	// A new config snapshot computes them again
+	c.generation++
+	if _, err := c.get(); err != nil || calls != 2 {
+		t.Errorf("expected the defaults to be computed again, got %v calls, %v", calls, err)
+	}
+}
@@ -59,6 +59,8 @@ func (d *Discord) handleHelpCommand(param string) {
 
 	add := fmt.Sprintf("`%vadd [title/url/id]` — add track\n", prefix)
 	list := fmt.Sprintf("`%vlist` — show current queue\n", prefix)
+	radio := fmt.Sprintf("`%vradio search [name|tag|country]`, `%vradio play [number]` — find and play radio stations\n", prefix, prefix)
+	podcasts := fmt.Sprintf("`%vpodcast [feed url]` — list episodes of a podcast, `%vplay [feed url] [number|latest]` to play one\n`%vpodcast`, `%vpodcast check` — show remembered podcasts/new episodes\n", prefix, prefix, prefix, prefix)
 
 	history := fmt.Sprintf("`%vhistory` — show played tracks\n", prefix)
 	historyByDuration := fmt.Sprintf("`%vhistory duration` — sort by duration \n", prefix)
@@ -70,13 +72,18 @@ func (d *Discord) handleHelpCommand(param string) {
 
 	cached := fmt.Sprintf("`%vcached` — show cached tracks\n", prefix)
 	cachedSync := fmt.Sprintf("`%vcached sync` — sync added/removed files to with database\n", prefix)
+	cachedVerify := fmt.Sprintf("`%vcached verify [fix]` — report missing, corrupt and duplicate files\n", prefix)
+	cachedRetention := fmt.Sprintf("`%vcached retention` — show quota usage and the tracks retention would delete\n", prefix)
 	curl := fmt.Sprintf("`%vcurl [url]` — cache track (youtube url only)\n", prefix)
-	uploaded := fmt.Sprintf("`%vuploaded` — show uploaded videos\n", prefix)
-	uploadedExtract := fmt.Sprintf("`%vuploaded extract` — extract audio from uploaded videos to cache\n", prefix)
+	uploaded := fmt.Sprintf("`%vuploaded` — show uploaded videos and audio files\n", prefix)
+	uploadedExtract := fmt.Sprintf("`%vuploaded extract` — move audio of uploaded files to cache\n", prefix)
+	jobs := fmt.Sprintf("`%vjobs`, `%vjobs cancel [id]` — show/cancel background downloads and extractions\n", prefix, prefix)
+	library := fmt.Sprintf("`%vlibrary`, `%vlibrary scan` — show/rescan local music library\n", prefix, prefix)
 
 	register := fmt.Sprintf("`%vregister` — enable commands listening\n", prefix)
 	unregister := fmt.Sprintf("`%vunregister` — disable commands listening\n", prefix)
 	whoami := fmt.Sprintf("`%vwhoami` — log user's info\n", prefix)
+	settings := fmt.Sprintf("`%vsettings` — show guild settings\n`%vsettings set [key] [value]`, `%vsettings reset [key]` — change/reset guild settings\n", prefix, prefix, prefix)
 	melodixPrefix := "`melodix-prefix` — print current command prefix\n"
 	melodixPrefixUpdate := "`melodix-prefix-update \"[new_prefix]\"` — set new prefix (in quotes)\n"
 	melodixPreifxReset := fmt.Sprintf("`melodix-prefix-reset` — reset prefix to global one: `%v`\n", cfg.DiscordCommandPrefix)
@@ -84,18 +91,18 @@ func (d *Discord) handleHelpCommand(param string) {
 	title := fmt.Sprintf("ℹ️ %v — Commands Usage\n\n", version.AppName)
 
 	embedMsg := embed.NewEmbed().
-		SetDescription(title+"[title] - track name\n[url] - YouTube URL\n[id] - track id from *History*\n[stream] - valid stream URL (radio).\n\n").
+		SetDescription(title+"[title] - track name\n[url] - YouTube URL\n[id] - track id from *History*\n[stream] - valid stream URL (radio).\n`artist:[name]`, `album:[name]`, `lib:[title]` - tracks from the local music library.\n`subsonic:[title]`, `subsonic:album:[name]`, `subsonic:playlist:[name]` - tracks from the Subsonic media server.\nAudio files attached to the message (or to the replied message) are played too.\n\n").
 		AddField("", "**Playback**\n"+play+skip+pause+stop+"\n`"+prefix+"help play` for more..\n").
 		AddField("", "").
-		AddField("", "**Queue**\n"+add+list+"\n`"+prefix+"help queue` for more..\n").
+		AddField("", "**Queue**\n"+add+list+radio+podcasts+"\n`"+prefix+"help queue` for more..\n").
 		AddField("", "").
 		AddField("", "**History**\n"+history+historyByDuration+historyByPlaycount+"\n").
 		AddField("", "").
 		AddField("", "**Information**\n"+now+help+about+"\n").
 		AddField("", "").
-		AddField("", "**Management**\n"+register+unregister+whoami+melodixPrefix+melodixPrefixUpdate+melodixPreifxReset+"\n").
+		AddField("", "**Management**\n"+register+unregister+whoami+settings+melodixPrefix+melodixPrefixUpdate+melodixPreifxReset+"\n").
 		AddField("", "").
-		AddField("", "**Caching & Sideloading**\nThis commands are for superadmin only.\n"+cached+cachedSync+curl+uploaded+uploadedExtract+"\n").
+		AddField("", "**Caching & Sideloading**\nThis commands are for superadmin only.\n"+cached+cachedSync+cachedVerify+cachedRetention+curl+uploaded+uploadedExtract+jobs+library+"\n").
 		AddField("", "\n\n").
 		SetThumbnail(avatarURL).
 		SetColor(0x9f00d4).
@@ -126,11 +133,13 @@ func (d *Discord) handleHelpPlay() {
 	example3 := fmt.Sprintf("```%vp https://www.youtube.com/watch?v=dQw4w9WgXcQ https://www.youtube.com/watch?v=98MWcF_Ucs0``` (multiple links added, space separated)", prefix)
 	example4 := fmt.Sprintf("```%v> http://stream.radioparadise.com/aac-128```", prefix)
 	example5 := fmt.Sprintf("```%vplay 123``` (assuming track ID in %vhistory is 123)", prefix, prefix)
+	example6 := fmt.Sprintf("```%vplay artist:Daft Punk``` (all tracks of an artist from the local library, `album:` works the same)", prefix)
+	example7 := fmt.Sprintf("```%vplay https://www.youtube.com/playlist?list=PL634F2B56B8C346A2 5-20 reverse``` (songs 5 to 20 of a playlist in reverse order, `shuffle` works the same; `t=` timestamps of YouTube links are honored)", prefix)
 
-	info1 := "title - is a song title, url - YouTube URL, stream - valid stream URL (radio), id - track id from *History*\n\n"
+	info1 := "title - is a song title, url - YouTube URL, stream - valid stream URL (radio), id - track id from *History*\nAudio files attached to the command, or to the message it replies to, are played as well\n\n"
 	info2 := "\n\n⚠️ Spotify links are not supported"
 
-	d.sendMessageEmbed(command1 + command2 + command3 + info1 + command5 + command6 + command7 + command8 + exampleTitle + example1 + example2 + example3 + example4 + example5 + info2)
+	d.sendMessageEmbed(command1 + command2 + command3 + info1 + command5 + command6 + command7 + command8 + exampleTitle + example1 + example2 + example3 + example4 + example5 + example6 + example7 + info2)
 }
 
 func (d *Discord) handleHelpQueue() {
@@ -0,0 +1,286 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/internal/settings"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/precode"
+	"github.com/keshon/melodix-player/mods/music/storage"
+	"github.com/keshon/melodix-player/mods/music/utils"
+)
+
+const autoCacheExt = ".opus"
+
+// AutoCacheTimeout bounds a single background download.
+var AutoCacheTimeout = 30 * time.Minute
+
+var (
+	autoCacheDownloads sync.Map   // song IDs being downloaded
+	autoCacheEvictions sync.Mutex // guards quota accounting
+)
+
+// IAutoCache keeps local copies of the YouTube tracks a guild plays often.
+type IAutoCache interface {
+	Lookup(song *media.Song) *media.Song
+	Played(song *media.Song)
+	Evict() (int, error)
+}
+
+// AutoCache downloads YouTube tracks played more than minPlays times in the guild
+// to its cache folder and evicts the least recently played ones over maxSize bytes.
+type AutoCache struct {
+	*Cache
+	enabled  bool
+	minPlays uint
+	maxSize  int64
+	download func(song *media.Song, path string) error
+}
+
+func NewAutoCache(cacheFolder, guildID string, repos *db.Repositories) IAutoCache {
+	conf, err := config.NewConfig()
+	if err != nil {
+		slog.Errorf("Error loading config, automatic cache is disabled: %v", err)
+		return newAutoCache(cacheFolder, guildID, repos, storage.NewLocal(cacheFolder), false, 0, 0, nil)
+	}
+
+	download := func(song *media.Song, path string) error {
+		return downloadOpus(conf.DcaFfmpegBinaryPath, conf.DcaUserAgent, song.Filepath, path)
+	}
+
+	c := newAutoCache(cacheFolder, guildID, repos, storage.NewStorage(cacheFolder), conf.AutoCacheEnabled, uint(conf.AutoCacheMinPlays), int64(conf.AutoCacheMaxSizeMB)<<20, download)
+	c.settings = settings.NewSettings(repos)
+	return c
+}
+
+func newAutoCache(cacheFolder, guildID string, repos *db.Repositories, store storage.IStorage, enabled bool, minPlays uint, maxSize int64, download func(song *media.Song, path string) error) *AutoCache {
+	return &AutoCache{
+		Cache: &Cache{
+			cacheFolder: cacheFolder,
+			guildID:     guildID,
+			tracks:      repos.Tracks,
+			histories:   repos.Histories,
+			store:       store,
+		},
+		enabled:  enabled,
+		minPlays: minPlays,
+		maxSize:  maxSize,
+		download: download,
+	}
+}
+
+// Lookup returns the song played from its cached file, or nil when there is none.
+func (c *AutoCache) Lookup(song *media.Song) *media.Song {
+	if song == nil || song.Source != media.SourceYouTube {
+		return nil
+	}
+
+	track, err := c.tracks.GetBySongID(song.SongID)
+	if err != nil || track.Filepath == "" {
+		return nil
+	}
+	if _, err := c.store.Stat(context.Background(), track.Filepath); err != nil {
+		return nil
+	}
+
+	cached := *song
+	cached.Filepath = track.Filepath
+	cached.Source = media.SourceLocalFile
+	cached.InputOptions = nil
+	cached.StreamMap = ""
+	return &cached
+}
+
+// Played starts a background download once the song is played often enough.
+// It expects the play to be counted in history already.
+func (c *AutoCache) Played(song *media.Song) {
+	if !c.enabled || song == nil || song.Source != media.SourceYouTube || song.Filepath == "" {
+		return
+	}
+
+	track, err := c.tracks.GetBySongID(song.SongID)
+	if err != nil {
+		return
+	}
+	history, err := c.histories.GetByTrackIDAndGuildID(track.ID, c.guildID)
+	if err != nil || history.PlayCount <= c.minPlays {
+		return
+	}
+	if track.Filepath != "" {
+		if _, err := c.store.Stat(context.Background(), track.Filepath); err == nil {
+			return
+		}
+	}
+
+	if _, loading := autoCacheDownloads.LoadOrStore(song.SongID, true); loading {
+		return
+	}
+
+	go func() {
+		defer autoCacheDownloads.Delete(song.SongID)
+
+		if err := c.cacheSong(song); err != nil {
+			slog.Errorf("Error caching %v: %v", song.Title, err)
+			return
+		}
+		if _, err := c.Evict(); err != nil {
+			slog.Errorf("Error evicting cached tracks: %v", err)
+		}
+	}()
+}
+
+func (c *AutoCache) cacheSong(song *media.Song) error {
+	ctx := context.Background()
+	cacheGuildFolder := filepath.Join(c.cacheFolder, c.guildID)
+	if err := os.MkdirAll(cacheGuildFolder, 0755); err != nil {
+		return fmt.Errorf("error creating cache folder %v", err)
+	}
+
+	name := strings.NewReplacer("/", "-", "\\", "-").Replace(c.sanitizeName(song.Title))
+	audioFilePath := filepath.Join(cacheGuildFolder, name+"-"+song.SongID+autoCacheExt)
+	partFilePath := audioFilePath + PartialExt
+
+	if err := c.download(song, partFilePath); err != nil {
+		os.Remove(partFilePath)
+		return err
+	}
+	if err := os.Rename(partFilePath, audioFilePath); err != nil {
+		os.Remove(partFilePath)
+		return fmt.Errorf("error renaming downloaded file %v", err)
+	}
+
+	id, err := c.importFile(ctx, audioFilePath, audioFilePath)
+	if err != nil {
+		os.Remove(audioFilePath)
+		return err
+	}
+
+	track, err := c.tracks.GetBySongID(song.SongID)
+	if err != nil {
+		c.store.Remove(ctx, audioFilePath)
+		return fmt.Errorf("error getting track from database %v", err)
+	}
+	track.Filepath = audioFilePath
+	track.Source = media.SourceLocalFile.String()
+	id.apply(track)
+	if err := c.tracks.Update(track); err != nil {
+		c.store.Remove(ctx, audioFilePath)
+		return fmt.Errorf("error updating track in database %v", err)
+	}
+
+	slog.Infof("Cached %v to %v", song.Title, audioFilePath)
+	return nil
+}
+
+// Evict removes the least recently played cached tracks until the guild cache fits
+// its quota, turning them back into YouTube tracks. Files cached by other means
+// are neither counted nor removed.
+func (c *AutoCache) Evict() (int, error) {
+	autoCacheEvictions.Lock()
+	defer autoCacheEvictions.Unlock()
+
+	tracks, err := c.tracks.GetAll()
+	if err != nil {
+		return 0, fmt.Errorf("error getting all tracks %v", err)
+	}
+
+	type cachedTrack struct {
+		track      db.Track
+		size       int64
+		lastPlayed time.Time
+	}
+
+	cacheGuildFolder := filepath.Join(c.cacheFolder, c.guildID)
+
+	var cached []cachedTrack
+	var total int64
+	for _, track := range tracks {
+		if !c.isAutoCached(track, cacheGuildFolder) {
+			continue
+		}
+
+		obj, err := c.store.Stat(context.Background(), track.Filepath)
+		if err != nil {
+			continue
+		}
+
+		entry := cachedTrack{track: track, size: obj.Size}
+		if history, err := c.histories.GetByTrackIDAndGuildID(track.ID, c.guildID); err == nil {
+			entry.lastPlayed = history.LastPlayed
+		}
+
+		cached = append(cached, entry)
+		total += entry.size
+	}
+
+	sort.Slice(cached, func(i, j int) bool {
+		return cached[i].lastPlayed.Before(cached[j].lastPlayed)
+	})
+
+	var evicted int
+	for _, entry := range cached {
+		if total <= c.maxSize {
+			break
+		}
+
+		if err := c.store.Remove(context.Background(), entry.track.Filepath); err != nil {
+			return evicted, fmt.Errorf("error removing cached file %v", err)
+		}
+		if err := precode.NewPrecoder(c.cacheFolder).Remove(entry.track.Filepath); err != nil {
+			slog.Warnf("Error removing pre-encoded file: %v", err)
+		}
+
+		entry.track.Filepath = ""
+		entry.track.Source = media.SourceYouTube.String()
+		if err := c.tracks.Update(&entry.track); err != nil {
+			return evicted, fmt.Errorf("error updating track in database %v", err)
+		}
+
+		slog.Infof("Evicted %v from the cache of guild %v", entry.track.Title, c.guildID)
+		total -= entry.size
+		evicted++
+	}
+
+	return evicted, nil
+}
+
+func (c *AutoCache) isAutoCached(track db.Track, cacheGuildFolder string) bool {
+	return track.Filepath != "" &&
+		utils.IsYouTubeURL(track.URL) &&
+		filepath.Dir(track.Filepath) == cacheGuildFolder &&
+		strings.HasSuffix(track.Filepath, "-"+track.SongID+autoCacheExt)
+}
+
+// downloadOpus transcodes the audio of a media URL to an Ogg Opus file.
+func downloadOpus(ffmpegBinaryPath, userAgent, mediaURL, path string) error {
+	if _, err := os.Stat(ffmpegBinaryPath); err != nil {
+		ffmpegBinaryPath = ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), AutoCacheTimeout)
+	defer cancel()
+
+	args := []string{"-y", "-loglevel", "error"}
+	if userAgent != "" {
+		args = append(args, "-user_agent", userAgent)
+	}
+	args = append(args, "-i", mediaURL, "-vn", "-c:a", "libopus", "-b:a", "128k", "-f", "ogg", path)
+
+	output, err := exec.CommandContext(ctx, ffmpegBinaryPath+"ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error downloading audio %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
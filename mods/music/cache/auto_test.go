@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/storage"
+)
+
+func TestAutoCache(t *testing.T) {
+	dir := t.TempDir()
+	repos := db.NewMemoryRepositories()
+
+	// Every download is 1 KiB, the quota fits two of them
+	download := func(song *media.Song, path string) error {
+		return os.WriteFile(path, []byte(strings.Repeat("a", 1024)), 0644)
+	}
+	c := newAutoCache(dir, "guild", repos, storage.NewLocal(dir), true, 2, 2048, download)
+
+	play := func(id string, times uint) *media.Song {
+		song := &media.Song{Title: "Song " + id, URL: "https://www.youtube.com/watch?v=" + id, Filepath: "https://media.example.com/" + id, SongID: id, Source: media.SourceYouTube}
+		repos.Tracks.Create(&db.Track{SongID: id, Title: song.Title, URL: song.URL, Source: media.SourceYouTube.String()})
+		track, _ := repos.Tracks.GetBySongID(id)
+		repos.Histories.Create(&db.History{GuildID: "guild", TrackID: track.ID})
+		repos.Histories.UpdateStatsForGuild(track.ID, "guild", times, 0)
+		return song
+	}
+
+	waitCached := func(song *media.Song) *media.Song {
+		c.Played(song)
+		for i := 0; i < 100; i++ {
+			if _, loading := autoCacheDownloads.Load(song.SongID); !loading {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return c.Lookup(song)
+	}
+
+	if cached := waitCached(play("a", 2)); cached != nil {
+		t.Fatalf("song played twice should not be cached: %+v", cached)
+	}
+
+	// Played three times, oldest first
+	songs := []*media.Song{play("b", 3), play("c", 3), play("d", 3)}
+
+	first := waitCached(songs[0])
+	if first == nil || first.Source != media.SourceLocalFile || filepath.Dir(first.Filepath) != filepath.Join(dir, "guild") || first.URL != songs[0].URL {
+		t.Fatalf("unexpected cached song: %+v", first)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if waitCached(songs[1]) == nil {
+		t.Fatal("expected second song to be cached")
+	}
+
+	// Replaying the first song makes the second one the least recently played
+	track, _ := repos.Tracks.GetBySongID("b")
+	repos.Histories.UpdateStatsForGuild(track.ID, "guild", 4, 0)
+	time.Sleep(10 * time.Millisecond)
+
+	if waitCached(songs[2]) == nil {
+		t.Fatal("expected third song to be cached")
+	}
+
+	if c.Lookup(songs[1]) != nil {
+		t.Error("least recently played song should be evicted")
+	}
+	if c.Lookup(songs[0]) == nil || c.Lookup(songs[2]) == nil {
+		t.Error("recently played songs should stay cached")
+	}
+
+	evictedTrack, _ := repos.Tracks.GetBySongID("c")
+	if evictedTrack.Filepath != "" || evictedTrack.Source != media.SourceYouTube.String() {
+		t.Errorf("evicted track should be a YouTube track again: %+v", evictedTrack)
+	}
+
+	files, _ := os.ReadDir(filepath.Join(dir, "guild"))
+	if len(files) != 2 {
+		t.Errorf("expected 2 cached files, got %d", len(files))
+	}
+}
@@ -1,32 +1,48 @@
 package cache
 
 import (
+	"bufio"
+	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
-	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/config"
 	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/internal/settings"
+	"github.com/keshon/melodix-player/mods/music/jobs"
+	"github.com/keshon/melodix-player/mods/music/library"
 	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/playlist"
 	"github.com/keshon/melodix-player/mods/music/sources"
+	"github.com/keshon/melodix-player/mods/music/storage"
 	"github.com/keshon/melodix-player/mods/music/utils"
 )
 
 type ICache interface {
-	Curl(url string) (string, error)
+	Curl(ctx context.Context, url string, report jobs.Report) (string, error)
+	CacheAttachment(filename, url string, maxSize int64) (*db.Track, error)
 	SyncCachedDir() (int, int, int, error)
+	Verify(ctx context.Context, fix bool, report jobs.Report) (*VerifyReport, error)
+	Retention(ctx context.Context, apply bool) (*RetentionReport, error)
+	Usage(ctx context.Context) (Usage, error)
 	ListCachedFiles() ([]string, error)
 	ListUploadedFiles() ([]string, error)
-	ExtractAudioFromVideo() ([]string, error)
+	ExtractAudioFromVideo(ctx context.Context, report jobs.Report) ([]string, error)
 	syncFilesToDB(guildID string, files []os.FileInfo, cacheGuildFolder string) error
-	downloadFile(filepath, url string) error
-	extractAudio(path, filename string) error
+	downloadFile(ctx context.Context, filepath, url string, report jobs.Report) error
+	convertAudio(ctx context.Context, inputPath, outputBase string, report func(processed, duration time.Duration)) (string, *library.Tags, error)
 	sanitizeName(filename string) string
 	stripExtension(filename string) string
 	humanReadableSize(size int64) string
@@ -37,19 +53,53 @@ type Cache struct {
 	uploadsFolder string
 	cacheFolder   string
 	guildID       string
+	tracks        db.TrackRepository
+	histories     db.HistoryRepository
+	uploadFormat  audioFormat
+	uploadBitrate int // kbps
+	ffmpegPath    string
+	prober        library.Prober
+	store         storage.IStorage
+	settings      settings.ISettings
+	uploadQuota   int64 // bytes, 0 means unlimited
 }
 
 // NewCache initializes a new Cache struct
-func NewCache(uploadsFolder, cacheFolder, guildID string) ICache {
+func NewCache(uploadsFolder, cacheFolder, guildID string, repos *db.Repositories) ICache {
+	conf, err := config.NewConfig()
+	if err != nil {
+		slog.Errorf("Error loading config, storing uploads as mp3: %v", err)
+		conf = config.Default()
+	}
+
+	ffmpegBinaryPath := conf.DcaFfmpegBinaryPath
+	if _, err := os.Stat(ffmpegBinaryPath); err != nil {
+		ffmpegBinaryPath = ""
+	}
+
 	return &Cache{
 		uploadsFolder: uploadsFolder,
 		cacheFolder:   cacheFolder,
 		guildID:       guildID,
+		tracks:        repos.Tracks,
+		histories:     repos.Histories,
+		uploadFormat:  audioFormats[conf.UploadCodec],
+		uploadBitrate: conf.UploadBitrate,
+		ffmpegPath:    ffmpegBinaryPath + "ffmpeg",
+		prober:        library.NewFFprobe(conf.DcaFfmpegBinaryPath),
+		store:         storage.NewStorage(cacheFolder),
+		settings:      settings.NewSettings(repos),
+		uploadQuota:   int64(conf.QuotaUploadMB) << 20,
 	}
 }
 
-func (c *Cache) Curl(url string) (string, error) {
+// Curl downloads a YouTube video and caches its audio, reporting the bytes
+// downloaded and then the time extracted by ffmpeg.
+func (c *Cache) Curl(ctx context.Context, url string, report jobs.Report) (string, error) {
 	uploadsFolder := c.uploadsFolder
+	if report == nil {
+		report = func(jobs.Progress) {}
+	}
 
 	yt := sources.NewYoutube()
 	song, err := yt.FetchOneByURL(url)
@@ -59,10 +109,21 @@ func (c *Cache) Curl(url string) (string, error) {
 	// Generate unique filename
 	fileName := fmt.Sprintf("%d", time.Now().Unix())
 
-	// Download the video
+	// Download the video within the space left in the upload folder
+	space, err := c.uploadSpace()
+	if err != nil {
+		return "", err
+	}
 	videoFilePath := filepath.Join(uploadsFolder, fileName+".mp4")
-	err = c.downloadFile(videoFilePath, song.Filepath)
+	err = c.downloadFileLimited(ctx, videoFilePath, song.Filepath, space, report)
 	if err != nil {
+		os.Remove(videoFilePath)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if errors.Is(err, ErrTooLarge) {
+			return "", fmt.Errorf("%w: the video does not fit in the upload folder limited to %v", ErrQuotaExceeded, c.humanReadableSize(c.uploadQuota))
+		}
 		return "", fmt.Errorf("error downloading video %v", err)
 	}
 
@@ -76,13 +137,19 @@ func (c *Cache) Curl(url string) (string, error) {
 
 	// Check if cache folder for guild exists, create if not
 	cacheGuildFolder := filepath.Join(c.cacheFolder, c.guildID)
-	c.createPathIfNotExists(cacheGuildFolder)
+	if err := c.createPathIfNotExists(cacheGuildFolder); err != nil {
+		return "", err
+	}
 
 	// Extract audio from video
-	audioFilename := c.sanitizeName(song.Title) + ".mp3"
-	audioFilePath := filepath.Join(cacheGuildFolder, audioFilename)
-	err = c.extractAudio(videoFilePath, audioFilePath)
+	audioFilePath, _, err := c.convertAudio(ctx, videoFilePath, filepath.Join(cacheGuildFolder, c.sanitizeName(song.Title)), func(processed, duration time.Duration) {
+		report(jobs.Progress{Stage: "extracting audio", Processed: processed, Duration: duration})
+	})
 	if err != nil {
+		os.Remove(videoFilePath)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 		return "", fmt.Errorf("error extracting audio %v", err)
 	}
 
@@ -92,12 +159,19 @@ func (c *Cache) Curl(url string) (string, error) {
 		return "", fmt.Errorf("error removing temporary video file %v", err)
 	}
 
+	id, err := c.importFile(ctx, audioFilePath, audioFilePath)
+	if err != nil {
+		os.Remove(audioFilePath)
+		return "", err
+	}
+
 	// Check if cached file exists in database
-	existingTrack, err := db.GetTrackBySongID(song.SongID)
+	existingTrack, err := c.tracks.GetBySongID(song.SongID)
 	if err == nil {
 		existingTrack.Filepath = audioFilePath
 		existingTrack.Source = media.SourceLocalFile.String()
-		err := db.UpdateTrack(existingTrack)
+		id.apply(existingTrack)
+		err := c.tracks.Update(existingTrack)
 		if err != nil {
 			return "", fmt.Errorf("error updating track in database %v", err)
 		}
@@ -109,91 +183,189 @@ func (c *Cache) Curl(url string) (string, error) {
 			Source:   media.SourceLocalFile.String(),
 			Filepath: audioFilePath,
 		}
-		err = db.CreateTrack(newTrack)
+		id.apply(newTrack)
+		err = c.tracks.Create(newTrack)
 		if err != nil {
 			return "", fmt.Errorf("error creating track in database %v", err)
 		}
 	}
 
-	// Get the audio file size and format
-	audioFileInfo, err := os.Stat(audioFilePath)
+	audioFileSize := c.humanReadableSize(id.size)
+
+	message := fmt.Sprintf("File Size: %s\nFile Format: %s\nAudio File Size: %s", fileSize, fileFormat, audioFileSize)
+
+	return message, nil
+}
+
+// PartialExt marks files that are still being written to the cache.
+const PartialExt = ".part"
+
+// ErrTooLarge is returned when a download exceeds the allowed size.
+var ErrTooLarge = errors.New("file is too large")
+
+// CacheAttachment downloads a file posted in Discord into the guild cache folder and
+// registers it as a local file track titled with the original filename.
+// A file whose content is cached already is played from the cache, another file
+// posted under a taken name is stored under a new one.
+// maxSize limits the download in bytes, 0 means unlimited.
+func (c *Cache) CacheAttachment(filename, url string, maxSize int64) (*db.Track, error) {
+	ctx := context.Background()
+	cacheGuildFolder := filepath.Join(c.cacheFolder, c.guildID)
+	if err := c.createPathIfNotExists(cacheGuildFolder); err != nil {
+		return nil, err
+	}
+
+	audioFilename := c.sanitizeName(c.stripExtension(filename)) + strings.ToLower(filepath.Ext(filename))
+	audioFilePath := filepath.Join(cacheGuildFolder, audioFilename)
+
+	partFile, err := os.CreateTemp(cacheGuildFolder, audioFilename+"-*"+PartialExt)
 	if err != nil {
-		return "", fmt.Errorf("error getting audio file information %v", err)
+		return nil, fmt.Errorf("failed to create file %v", err)
 	}
+	partFilePath := partFile.Name()
+	partFile.Close()
 
-	audioFileSize := c.humanReadableSize(audioFileInfo.Size())
+	if err := c.downloadFileLimited(ctx, partFilePath, url, maxSize, nil); err != nil {
+		os.Remove(partFilePath)
+		return nil, err
+	}
 
-	message := fmt.Sprintf("File Size: %s\nFile Format: %s\nAudio File Size: %s", fileSize, fileFormat, audioFileSize)
+	file, err := os.Open(partFilePath)
+	if err != nil {
+		os.Remove(partFilePath)
+		return nil, err
+	}
+	hash, err := hashReader(file)
+	file.Close()
+	if err != nil {
+		os.Remove(partFilePath)
+		return nil, fmt.Errorf("error hashing %v: %v", partFilePath, err)
+	}
 
-	return message, nil
+	// The same file posted again is played from the cache
+	if track := c.cachedContent(ctx, cacheGuildFolder, hash); track != nil {
+		os.Remove(partFilePath)
+		return track, nil
+	}
+
+	for i := 1; c.pathTaken(ctx, audioFilePath); i++ {
+		audioFilePath = filepath.Join(cacheGuildFolder, fmt.Sprintf("%s-%d%s", c.stripExtension(audioFilename), i, filepath.Ext(audioFilename)))
+	}
+
+	id, err := c.importFile(ctx, partFilePath, audioFilePath)
+	if err != nil {
+		os.Remove(partFilePath)
+		return nil, err
+	}
+
+	track := &db.Track{
+		SongID:   c.localSongID(id.hash, audioFilePath),
+		Title:    filename,
+		Source:   media.SourceLocalFile.String(),
+		Filepath: audioFilePath,
+	}
+	id.apply(track)
+	if err := c.tracks.Create(track); err != nil {
+		return nil, fmt.Errorf("error creating track in database %v", err)
+	}
+
+	return track, nil
 }
 
 func (c *Cache) SyncCachedDir() (int, int, int, error) {
+	ctx := context.Background()
 	guildID := c.guildID
 	cacheFolder := c.cacheFolder
 
 	var added, updated, removed int
 
-	// Check if the cache folder for the guild exists
 	cacheGuildFolder := filepath.Join(cacheFolder, guildID)
-	_, err := os.Stat(cacheGuildFolder)
-	if os.IsNotExist(err) {
-		return 0, 0, 0, fmt.Errorf("cache folder for guild %s does not exist", guildID)
+
+	// Files copied to the local cache folder are moved to the storage first
+	if !c.store.IsLocal() {
+		if err := c.importLocalFiles(ctx, cacheGuildFolder); err != nil {
+			return 0, 0, 0, err
+		}
 	}
 
 	// Get a list of files in the cache folder
-	files, err := os.ReadDir(cacheGuildFolder)
+	files, err := c.store.List(ctx, cacheGuildFolder)
+	if errors.Is(err, storage.ErrNotExist) {
+		return 0, 0, 0, fmt.Errorf("cache folder for guild %s does not exist", guildID)
+	}
 	if err != nil {
 		return 0, 0, 0, fmt.Errorf("error reading cache folder for guild %s: %v", guildID, err)
 	}
 
 	// Iterate over the files and append their names and IDs to the buffer
 	for _, file := range files {
+		// Skip files still being downloaded and playlists kept next to the tracks
+		if strings.HasSuffix(file.Name(), PartialExt) || playlist.IsPlaylistFile(file.Name()) {
+			continue
+		}
+
 		filenameNoExt := c.stripExtension(file.Name())
 		audioFilename := c.sanitizeName(filenameNoExt) + filepath.Ext(file.Name())
 
 		// Rename the file to formatted name
-		oldPath := filepath.Join(cacheGuildFolder, file.Name())
+		oldPath := file.Path
 		newPath := filepath.Join(cacheGuildFolder, audioFilename)
-
-		// Rename the file to formatted name
-		err := os.Rename(oldPath, newPath)
-		if err != nil {
-			// Handle error if renaming fails
-			fmt.Printf("Error renaming file %s to %s: %v\n", oldPath, newPath, err)
+		if newPath != oldPath {
+			if err := c.store.Rename(ctx, oldPath, newPath); err != nil {
+				// Handle error if renaming fails
+				slog.Errorf("Error renaming file %s to %s: %v", oldPath, newPath, err)
+				newPath = oldPath
+			}
 		}
 
-		filepath := filepath.Join(cacheGuildFolder, file.Name())
-		track, err := db.GetTrackByFilepath(newPath)
-		if err != nil {
-			songID := md5.Sum([]byte(filepath))
-			songIDStr := fmt.Sprintf("%x", songID)
-			err = db.CreateTrack(&db.Track{
-				SongID:   songIDStr,
-				Title:    file.Name(),
-				Filepath: filepath,
-				Source:   media.SourceLocalFile.String(),
-			})
+		filepath := newPath
+		track, err := c.tracks.GetByFilepath(filepath)
+		if err == nil {
+			// Keep the content hash of known files up to date
+			id, err := c.identify(ctx, filepath, track)
 			if err != nil {
-				return 0, 0, 0, fmt.Errorf("error creating track in database %v", err)
+				return 0, 0, 0, fmt.Errorf("error hashing cached file %v", err)
 			}
-			added++
-		} else {
-			if track.Filepath != filepath {
-				track.Filepath = filepath
-				track.Source = media.SourceLocalFile.String()
-				err = db.UpdateTrack(track)
-				if err != nil {
+			if !id.matches(*track) {
+				id.apply(track)
+				if err := c.tracks.Update(track); err != nil {
 					return 0, 0, 0, fmt.Errorf("error updating track in database %v", err)
 				}
-				updated++
 			}
+			continue
+		}
+
+		id, err := c.identify(ctx, filepath, nil)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("error hashing cached file %v", err)
 		}
 
+		// A renamed or moved file keeps its track and history
+		if moved := c.movedTrack(id.hash); moved != nil {
+			moved.Filepath = filepath
+			id.apply(moved)
+			if err := c.tracks.Update(moved); err != nil {
+				return 0, 0, 0, fmt.Errorf("error updating track in database %v", err)
+			}
+			updated++
+			continue
+		}
+
+		newTrack := &db.Track{
+			SongID:   c.localSongID(id.hash, filepath),
+			Title:    audioFilename,
+			Filepath: filepath,
+			Source:   media.SourceLocalFile.String(),
+		}
+		id.apply(newTrack)
+		if err := c.tracks.Create(newTrack); err != nil {
+			return 0, 0, 0, fmt.Errorf("error creating track in database %v", err)
+		}
+		added++
 	}
 
 	// Iterate over database tracks and remove filepaths that no longer exist
-	tracks, err := db.GetAllTracks()
+	tracks, err := c.tracks.GetAll()
 	if err != nil {
 		return 0, 0, 0, fmt.Errorf("error getting all tracks %v", err)
 	}
@@ -202,33 +374,37 @@ func (c *Cache) SyncCachedDir() (int, int, int, error) {
 			continue
 		}
 
-		_, err := os.Stat(track.Filepath)
-		if os.IsNotExist(err) {
-			slog.Info(fmt.Sprintf("%v", track))
-			if track.URL == "" {
-				err = db.DeleteHistory(track.SongID)
-				if err != nil {
-					return 0, 0, 0, fmt.Errorf("error deleting history %v", err)
-				}
-				db.DeleteTrack(&track)
+		// Playlists registered as tracks by earlier syncs
+		if playlist.IsPlaylistFile(track.Filepath) && filepath.Dir(track.Filepath) == cacheGuildFolder {
+			_, trackRemoved, err := c.forget(track)
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			if trackRemoved {
+				removed++
+			}
+			continue
+		}
+
+		_, err := c.store.Stat(ctx, track.Filepath)
+		if errors.Is(err, storage.ErrNotExist) {
+			slog.Infof("%v", track)
+
+			trackUpdated, trackRemoved, err := c.resolveMissing(track)
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			if trackUpdated {
+				updated++
+			}
+			if trackRemoved {
 				removed++
-				continue
-			} else {
-				if utils.IsYouTubeURL(track.URL) {
-					track.Filepath = ""
-					track.Source = media.SourceYouTube.String()
-					err = db.UpdateTrack(&track)
-					if err != nil {
-						return 0, 0, 0, fmt.Errorf("error updating track in database %v", err)
-					}
-					updated++
-				}
 			}
 		}
 	}
 
 	// Iterate over database tracks and fix missing song IDs
-	tracks, err = db.GetAllTracks()
+	tracks, err = c.tracks.GetAll()
 	if err != nil {
 		return 0, 0, 0, fmt.Errorf("error getting all tracks %v", err)
 	}
@@ -237,7 +413,7 @@ func (c *Cache) SyncCachedDir() (int, int, int, error) {
 			songID := md5.Sum([]byte(track.Title))
 			songIDStr := fmt.Sprintf("%x", songID)
 			track.SongID = songIDStr
-			err = db.UpdateTrack(&track)
+			err = c.tracks.Update(&track)
 			if err != nil {
 				return 0, 0, 0, fmt.Errorf("error updating track in database %v", err)
 			}
@@ -247,21 +423,78 @@ func (c *Cache) SyncCachedDir() (int, int, int, error) {
 	return added, updated, removed, nil
 }
 
+// importLocalFiles moves the files found in the local cache folder of the guild
+// to the storage.
+func (c *Cache) importLocalFiles(ctx context.Context, cacheGuildFolder string) error {
+	files, err := os.ReadDir(cacheGuildFolder)
+	if err != nil {
+		return nil
+	}
+
+	for _, file := range files {
+		// Playlists are read from the local disk
+		if file.IsDir() || strings.HasSuffix(file.Name(), PartialExt) || playlist.IsPlaylistFile(file.Name()) {
+			continue
+		}
+		path := filepath.Join(cacheGuildFolder, file.Name())
+		if err := c.store.Import(ctx, path, path); err != nil {
+			return fmt.Errorf("error storing cached file %v", err)
+		}
+	}
+	return nil
+}
+
+// resolveMissing handles a local track whose file is gone: it follows the file
+// moved to another guild folder, turns YouTube tracks back into streamed ones
+// and drops the others along with their history.
+func (c *Cache) resolveMissing(track db.Track) (updated bool, removed bool, err error) {
+	if path := c.movedFile(track); path != "" {
+		track.Filepath = path
+		if err := c.tracks.Update(&track); err != nil {
+			return false, false, fmt.Errorf("error updating track in database %v", err)
+		}
+		return true, false, nil
+	}
+
+	return c.forget(track)
+}
+
+// forget resolves a local track whose file is gone for good: YouTube tracks
+// are played from YouTube again, tracks without a URL are deleted along with
+// their history.
+func (c *Cache) forget(track db.Track) (updated bool, removed bool, err error) {
+	if track.URL == "" {
+		if err := c.histories.DeleteByTrackID(track.ID); err != nil {
+			return false, false, fmt.Errorf("error deleting history %v", err)
+		}
+		c.tracks.Delete(&track)
+		return false, true, nil
+	}
+
+	if utils.IsYouTubeURL(track.URL) {
+		track.Filepath = ""
+		track.Source = media.SourceYouTube.String()
+		if err := c.tracks.Update(&track); err != nil {
+			return false, false, fmt.Errorf("error updating track in database %v", err)
+		}
+		return true, false, nil
+	}
+
+	return false, false, nil
+}
+
 // ListCachedFiles lists cached files
 func (c *Cache) ListCachedFiles() ([]string, error) {
 	// Get the guild ID
 	guildID := c.guildID
 	cacheFolder := c.cacheFolder
 
-	// Check if the cache folder for the guild exists
+	// Get a list of files in the cache folder
 	cacheGuildFolder := filepath.Join(cacheFolder, guildID)
-	_, err := os.Stat(cacheGuildFolder)
-	if os.IsNotExist(err) {
+	files, err := c.store.List(context.Background(), cacheGuildFolder)
+	if errors.Is(err, storage.ErrNotExist) {
 		return []string{}, fmt.Errorf("cache folder for guild %s does not exist", guildID)
 	}
-
-	// Get a list of files in the cache folder
-	files, err := os.ReadDir(cacheGuildFolder)
 	if err != nil {
 		return []string{}, fmt.Errorf("error reading cache folder for guild %s: %v", guildID, err)
 	}
@@ -271,6 +504,9 @@ func (c *Cache) ListCachedFiles() ([]string, error) {
 
 	// Iterate over the files and append their names and IDs to the buffer
 	for _, file := range files {
+		if playlist.IsPlaylistFile(file.Name()) {
+			continue
+		}
 		// Append file name and ID to the buffer
 		filelist = append(filelist, file.Name())
 	}
@@ -279,7 +515,7 @@ func (c *Cache) ListCachedFiles() ([]string, error) {
 }
 
 func (c *Cache) ListUploadedFiles() ([]string, error) {
-	// Scan uploaded folder for video files
+	// Scan uploaded folder for video and audio files
 	files, err := os.ReadDir(c.uploadsFolder)
 	if err != nil {
 		return []string{}, fmt.Errorf("error reading uploaded folder: %v", err)
@@ -289,8 +525,7 @@ func (c *Cache) ListUploadedFiles() ([]string, error) {
 	var filelist []string
 
 	for _, file := range files {
-		// Check if file is a video file
-		if filepath.Ext(file.Name()) == ".mp4" || filepath.Ext(file.Name()) == ".mkv" || filepath.Ext(file.Name()) == ".webm" {
+		if !file.IsDir() && IsUploadFile(file.Name()) {
 			filelist = append(filelist, file.Name())
 		}
 	}
@@ -298,89 +533,138 @@ func (c *Cache) ListUploadedFiles() ([]string, error) {
 	return filelist, nil
 }
 
-func (c *Cache) ExtractAudioFromVideo() ([]string, error) {
+// ExtractAudioFromVideo moves the audio of every uploaded video and audio file to
+// the cache, reporting the time extracted by ffmpeg for the current file. Tracks
+// are titled after the file tags when it has any. Uploads that can't be cached,
+// like those over the cache quota, are kept and listed with the reason.
+func (c *Cache) ExtractAudioFromVideo(ctx context.Context, report jobs.Report) ([]string, error) {
 	uploadsFolder := c.uploadsFolder
 	cacheFolder := c.cacheFolder
 	guildID := c.guildID
 	var filesStats []string
+	if report == nil {
+		report = func(jobs.Progress) {}
+	}
 
 	files, err := os.ReadDir(uploadsFolder)
 	if err != nil {
 		return []string{}, fmt.Errorf("error reading uploaded folder: %v", err)
 	}
 
-	// Iterate each file
+	var videos []os.DirEntry
 	for _, file := range files {
+		if !file.IsDir() && IsUploadFile(file.Name()) {
+			videos = append(videos, file)
+		}
+	}
 
-		// Check if file is a video file
-		if filepath.Ext(file.Name()) == ".mp4" || filepath.Ext(file.Name()) == ".mkv" || filepath.Ext(file.Name()) == ".webm" || filepath.Ext(file.Name()) == ".flv" {
+	// Iterate each file
+	for i, file := range videos {
+		if ctx.Err() != nil {
+			return filesStats, ctx.Err()
+		}
+		stage := fmt.Sprintf("%d/%d %s", i+1, len(videos), file.Name())
+		report(jobs.Progress{Stage: stage})
 
-			// Check if cache folder for guild exists, create if not
-			cacheGuildFolder := filepath.Join(cacheFolder, guildID)
-			c.createPathIfNotExists(cacheGuildFolder)
+		// Check if cache folder for guild exists, create if not
+		cacheGuildFolder := filepath.Join(cacheFolder, guildID)
+		if err := c.createPathIfNotExists(cacheGuildFolder); err != nil {
+			return nil, err
+		}
 
-			// Extract audio from video
-			videoFilePath := filepath.Join(uploadsFolder, file.Name())
-			filenameNoExt := c.stripExtension(file.Name())
-			audioFilename := c.sanitizeName(filenameNoExt) + ".mp3"
-			audioFilePath := filepath.Join(cacheGuildFolder, audioFilename)
-			err = c.extractAudio(videoFilePath, audioFilePath)
-			if err != nil {
-				continue
-			}
+		// Refuse files once the cache is full, the size of the audio is checked on import
+		videoFilePath := filepath.Join(uploadsFolder, file.Name())
+		filenameNoExt := c.stripExtension(file.Name())
+		outputBase := filepath.Join(cacheGuildFolder, c.sanitizeName(filenameNoExt))
+		if err := c.checkQuota(ctx, outputBase+c.uploadFormat.ext, 1); err != nil {
+			filesStats = append(filesStats, notExtracted(file.Name(), err))
+			continue
+		}
 
-			// Remove the temporary video file
-			err = os.Remove(videoFilePath)
-			if err != nil {
-				return []string{}, fmt.Errorf("error removing temporary video file: %v", err)
-			}
+		// Extract audio from video
+		audioFilePath, tags, err := c.convertAudio(ctx, videoFilePath, outputBase, func(processed, duration time.Duration) {
+			report(jobs.Progress{Stage: stage, Processed: processed, Duration: duration})
+		})
+		if ctx.Err() != nil {
+			return filesStats, ctx.Err()
+		}
+		if err != nil {
+			slog.Errorf("Error extracting audio from %v: %v", file.Name(), err)
+			filesStats = append(filesStats, notExtracted(file.Name(), err))
+			continue
+		}
+		audioFilename := filepath.Base(audioFilePath)
+		title := trackTitle(tags, audioFilename)
 
-			// Check if cached file exists in database
-			song, err := db.GetTrackByFilepath(audioFilename)
-			if err == nil {
-				song.Filepath = audioFilePath
-				err := db.UpdateTrack(song)
-				if err != nil {
-					continue
-				}
-			} else {
-				newTrack := &db.Track{
-					Title:    audioFilename,
-					Source:   media.SourceLocalFile.String(),
-					Filepath: audioFilePath,
-				}
-				err = db.CreateTrack(newTrack)
-				if err != nil {
-					continue
-				}
-			}
+		id, err := c.importFile(ctx, audioFilePath, audioFilePath)
+		if err != nil {
+			os.Remove(audioFilePath)
+			slog.Errorf("Error storing audio of %v: %v", file.Name(), err)
+			filesStats = append(filesStats, notExtracted(file.Name(), err))
+			continue
+		}
 
-			// Get the audio file size and format
-			audioFileInfo, err := os.Stat(audioFilePath)
-			if err != nil {
-				return []string{}, fmt.Errorf("error getting audio file information: %v", err)
+		// Check if cached file exists in database
+		song, err := c.tracks.GetByFilepath(audioFilePath)
+		if err == nil {
+			song.Filepath = audioFilePath
+			song.Title = title
+			id.apply(song)
+			err = c.tracks.Update(song)
+		} else {
+			newTrack := &db.Track{
+				SongID:   c.localSongID(id.hash, audioFilePath),
+				Title:    title,
+				Source:   media.SourceLocalFile.String(),
+				Filepath: audioFilePath,
 			}
+			id.apply(newTrack)
+			err = c.tracks.Create(newTrack)
+		}
+		if err != nil {
+			c.store.Remove(ctx, audioFilePath)
+			slog.Errorf("Error saving track of %v: %v", file.Name(), err)
+			filesStats = append(filesStats, notExtracted(file.Name(), err))
+			continue
+		}
 
-			fileSize := c.humanReadableSize(audioFileInfo.Size())
+		// The upload is removed once its audio is cached
+		if err := os.Remove(videoFilePath); err != nil {
+			return filesStats, fmt.Errorf("error removing uploaded file: %v", err)
+		}
 
-			// Send message with audio extraction information
-			fileSizeAndFormat := fmt.Sprintf("%s ` %s `", audioFileInfo.Name(), fileSize)
-			filesStats = append(filesStats, fileSizeAndFormat)
+		fileSize := c.humanReadableSize(id.size)
 
-		}
+		// Send message with audio extraction information
+		fileSizeAndFormat := fmt.Sprintf("%s ` %s `", audioFilename, fileSize)
+		filesStats = append(filesStats, fileSizeAndFormat)
 	}
 
 	return filesStats, nil
 }
 
-func (c *Cache) downloadFile(filepath, url string) error {
+// notExtracted describes an upload kept in the upload folder because of err.
+func notExtracted(name string, err error) string {
+	return fmt.Sprintf("❗️ %s is kept in the upload folder: %v", name, err)
+}
+
+func (c *Cache) downloadFile(ctx context.Context, filepath, url string, report jobs.Report) error {
+	return c.downloadFileLimited(ctx, filepath, url, 0, report)
+}
+
+func (c *Cache) downloadFileLimited(ctx context.Context, filepath, url string, maxSize int64, report jobs.Report) error {
 	out, err := os.Create(filepath)
 	if err != nil {
 		return fmt.Errorf("failed to create file %v", err)
 	}
 	defer out.Close()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to download file %v", err)
 	}
@@ -390,29 +674,138 @@ func (c *Cache) downloadFile(filepath, url string) error {
 		return fmt.Errorf("bad status %s", resp.Status)
 	}
 
-	_, err = io.Copy(out, resp.Body)
+	var body io.Reader = resp.Body
+	if maxSize > 0 {
+		if resp.ContentLength > maxSize {
+			return ErrTooLarge
+		}
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+	if report != nil {
+		body = io.TeeReader(body, &progressWriter{total: resp.ContentLength, report: report})
+	}
+
+	written, err := io.Copy(out, body)
 	if err != nil {
 		return fmt.Errorf("error copying file %v", err)
 	}
+	if maxSize > 0 && written > maxSize {
+		return ErrTooLarge
+	}
 
 	return nil
 }
 
-func (c *Cache) extractAudio(videoFilePath, audioFilePath string) error {
-	cmd := exec.Command("ffmpeg", "-i", videoFilePath, "-vn", "-acodec", "libmp3lame", "-b:a", "256k", audioFilePath)
-	err := cmd.Run()
+// runFFmpeg runs ffmpeg writing outputPath, reporting the time processed as read
+// from ffmpeg -progress and the duration printed by ffmpeg on stderr.
+func (c *Cache) runFFmpeg(ctx context.Context, args []string, outputPath string, report func(processed, duration time.Duration)) error {
+	args = append([]string{"-nostdin", "-nostats", "-progress", "pipe:1"}, args...)
+	cmd := exec.CommandContext(ctx, c.ffmpegPath, args...)
+
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("error extracting audio %v", err)
 	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("error extracting audio %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error extracting audio %v", err)
+	}
 
-	fmt.Printf("Audio extracted and saved to: %s\n", audioFilePath)
+	var duration atomic.Int64
+	var lastLine string
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if d, ok := parseFFmpegDuration(scanner.Text()); ok && duration.Load() == 0 {
+				duration.Store(int64(d))
+			}
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				lastLine = line
+			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if processed, ok := parseFFmpegProgress(scanner.Text()); ok && report != nil {
+			report(processed, time.Duration(duration.Load()))
+		}
+	}
+	<-stderrDone
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			os.Remove(outputPath)
+			return ctx.Err()
+		}
+		return fmt.Errorf("error extracting audio %v: %v", err, lastLine)
+	}
+
+	slog.Infof("Audio extracted and saved to: %s", outputPath)
 	return nil
 }
 
+// parseFFmpegProgress reads the processed time from an out_time_us line of
+// ffmpeg -progress output. out_time_ms holds microseconds as well.
+func parseFFmpegProgress(line string) (time.Duration, bool) {
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok || (key != "out_time_us" && key != "out_time_ms") {
+		return 0, false
+	}
+	us, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || us < 0 {
+		return 0, false
+	}
+	return time.Duration(us) * time.Microsecond, true
+}
+
+// parseFFmpegDuration reads the input duration from a line like
+// "  Duration: 00:03:21.45, start: 0.000000, bitrate: 128 kb/s".
+func parseFFmpegDuration(line string) (time.Duration, bool) {
+	_, rest, ok := strings.Cut(line, "Duration: ")
+	if !ok {
+		return 0, false
+	}
+	value, _, _ := strings.Cut(rest, ",")
+
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)), true
+}
+
+// progressWriter reports the bytes written through it as download progress.
+type progressWriter struct {
+	done   int64
+	total  int64
+	report jobs.Report
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.done += int64(len(p))
+	w.report(jobs.Progress{Stage: "downloading", BytesDone: w.done, BytesTotal: w.total})
+	return len(p), nil
+}
+
 func (c *Cache) createPathIfNotExists(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		err := os.MkdirAll(path, 0755)
-		return fmt.Errorf("error creating path %v", err)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return fmt.Errorf("error creating path %v", err)
+		}
 	}
 	return nil
 }
@@ -443,6 +836,10 @@ func (c *Cache) stripExtension(filename string) string {
 }
 
 func (c *Cache) humanReadableSize(size int64) string {
+	return humanSize(size)
+}
+
+func humanSize(size int64) string {
 	const (
 		b = 1 << (10 * iota)
 		kb
@@ -483,20 +880,20 @@ func (c *Cache) syncFilesToDB(guildID string, files []os.FileInfo, cacheGuildFol
 		err := os.Rename(oldPath, newPath)
 		if err != nil {
 			// Handle error if renaming fails
-			fmt.Printf("Error renaming file %s to %s: %v\n", oldPath, newPath, err)
+			slog.Errorf("Error renaming file %s to %s: %v", oldPath, newPath, err)
 			continue
 		}
 
 		filepath := filepath.Join(cacheGuildFolder, file.Name())
-		_, err = db.GetTrackByFilepath(newPath)
+		_, err = c.tracks.GetByFilepath(newPath)
 		if err != nil {
-			db.CreateTrack(&db.Track{
+			c.tracks.Create(&db.Track{
 				Title:    file.Name(),
 				Filepath: filepath,
 				Source:   media.SourceLocalFile.String(),
 			})
 		} else {
-			db.UpdateTrack(&db.Track{
+			c.tracks.Update(&db.Track{
 				Filepath: filepath,
 				Source:   media.SourceLocalFile.String(),
 			})
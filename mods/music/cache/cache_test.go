@@ -0,0 +1,443 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/internal/settings"
+	"github.com/keshon/melodix-player/mods/music/library"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/storage"
+	"github.com/keshon/melodix-player/mods/music/storage/storagetest"
+)
+
+func TestCacheAttachment(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat(strings.TrimPrefix(r.URL.Path, "/"), 1024)))
+	}))
+	defer srv.Close()
+
+	cacheDir := t.TempDir()
+	c := NewCache(t.TempDir(), cacheDir, "guild", db.NewMemoryRepositories())
+
+	track, err := c.CacheAttachment("My Song.MP3", srv.URL+"/a", 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Title != "My Song.MP3" || track.Source != media.SourceLocalFile.String() {
+		t.Errorf("unexpected track: %+v", track)
+	}
+	if filepath.Dir(track.Filepath) != filepath.Join(cacheDir, "guild") || filepath.Ext(track.Filepath) != ".mp3" {
+		t.Errorf("unexpected file path: %v", track.Filepath)
+	}
+	if info, err := os.Stat(track.Filepath); err != nil || info.Size() != 1024 {
+		t.Errorf("attachment is not downloaded: %v", err)
+	}
+
+	// The same content is reused whatever its name
+	again, err := c.CacheAttachment("Other Name.mp3", srv.URL+"/a", 2048)
+	if err != nil || again.ID != track.ID {
+		t.Errorf("expected the cached track to be reused, got %+v, %v", again, err)
+	}
+
+	// Another file posted under the same name is stored next to the first one
+	other, err := c.CacheAttachment("My Song.MP3", srv.URL+"/b", 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == track.ID || other.Filepath == track.Filepath {
+		t.Errorf("expected a new track, got %+v", other)
+	}
+	if data, err := os.ReadFile(other.Filepath); err != nil || string(data) != strings.Repeat("b", 1024) {
+		t.Errorf("unexpected content of %v: %v", other.Filepath, err)
+	}
+	if data, err := os.ReadFile(track.Filepath); err != nil || string(data) != strings.Repeat("a", 1024) {
+		t.Errorf("first attachment is overwritten: %v", err)
+	}
+
+	_, err = c.CacheAttachment("big.ogg", srv.URL+"/a", 512)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "guild", "big.ogg")); !os.IsNotExist(err) {
+		t.Errorf("oversized attachment is left in the cache")
+	}
+	if files, _ := filepath.Glob(filepath.Join(cacheDir, "guild", "*"+PartialExt)); len(files) != 0 {
+		t.Errorf("partial downloads are left in the cache: %v", files)
+	}
+}
+
+func TestCacheS3(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("attachment"))
+	}))
+	defer srv.Close()
+
+	bucket := storagetest.NewS3Server("music")
+	defer bucket.Close()
+
+	cacheDir := t.TempDir()
+	repos := db.NewMemoryRepositories()
+	c := NewCache(t.TempDir(), cacheDir, "guild", repos).(*Cache)
+	c.store = storage.NewS3(storage.S3Options{Endpoint: bucket.URL, Bucket: "music", AccessKey: storagetest.AccessKey, SecretKey: storagetest.SecretKey, PathStyle: true}, cacheDir)
+
+	track, err := c.CacheAttachment("song.mp3", srv.URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(track.Filepath); !os.IsNotExist(err) {
+		t.Errorf("attachment is left in the local cache folder: %v", err)
+	}
+
+	// Files copied to the local cache folder are uploaded by sync
+	os.WriteFile(filepath.Join(cacheDir, "guild", "copied file.mp3"), []byte("copied"), 0644)
+	if added, _, _, err := c.SyncCachedDir(); err != nil || added != 1 {
+		t.Fatalf("unexpected sync result: %v, %v", added, err)
+	}
+
+	if keys := bucket.Keys(); len(keys) != 2 || keys[0] != "guild/copied-file.mp3" || keys[1] != "guild/song.mp3" {
+		t.Errorf("unexpected bucket content: %v", keys)
+	}
+	if files, err := c.ListCachedFiles(); err != nil || len(files) != 2 {
+		t.Errorf("unexpected cached files: %v, %v", files, err)
+	}
+}
+
+func TestParseFFmpegOutput(t *testing.T) {
+	if d, ok := parseFFmpegDuration("  Duration: 00:03:21.50, start: 0.000000, bitrate: 128 kb/s"); !ok || d != 3*time.Minute+21500*time.Millisecond {
+		t.Errorf("unexpected duration: %v, %v", d, ok)
+	}
+	if _, ok := parseFFmpegDuration("  Duration: N/A, bitrate: N/A"); ok {
+		t.Error("unknown duration is parsed")
+	}
+
+	if d, ok := parseFFmpegProgress("out_time_us=61500000"); !ok || d != 61500*time.Millisecond {
+		t.Errorf("unexpected progress: %v, %v", d, ok)
+	}
+	for _, line := range []string{"out_time=00:01:01.500000", "out_time_us=N/A", "progress=continue"} {
+		if _, ok := parseFFmpegProgress(line); ok {
+			t.Errorf("%q is parsed as progress", line)
+		}
+	}
+}
+
+type fakeProber struct {
+	tags    library.Tags
+	noAudio string // base name of a file without audio
+}
+
+func (p *fakeProber) Probe(ctx context.Context, path string) (*library.Tags, error) {
+	if filepath.Base(path) == p.noAudio {
+		return &library.Tags{}, nil
+	}
+	tags := p.tags
+	return &tags, nil
+}
+
+func (p *fakeProber) ExtractCover(ctx context.Context, path, dest string) error {
+	return nil
+}
+
+func TestExtractAudioUpload(t *testing.T) {
+	uploads := t.TempDir()
+	cacheDir := t.TempDir()
+	repos := db.NewMemoryRepositories()
+
+	c := NewCache(uploads, cacheDir, "guild", repos).(*Cache)
+	c.uploadFormat = audioFormats["mp3"]
+	c.prober = &fakeProber{tags: library.Tags{Codec: "mp3", Artist: "Band", Title: "Song"}}
+
+	os.WriteFile(filepath.Join(uploads, "My Song.MP3"), []byte("audio"), 0644)
+	os.WriteFile(filepath.Join(uploads, "notes.txt"), []byte("text"), 0644)
+
+	if files, _ := c.ListUploadedFiles(); len(files) != 1 || files[0] != "My Song.MP3" {
+		t.Errorf("unexpected uploaded files: %v", files)
+	}
+
+	// An mp3 upload is copied as it is, named after its tags in the database
+	stats, err := c.ExtractAudioFromVideo(context.Background(), nil)
+	if err != nil || len(stats) != 1 {
+		t.Fatalf("unexpected extraction result: %v, %v", stats, err)
+	}
+
+	tracks, _ := repos.Tracks.GetAll()
+	if len(tracks) != 1 || tracks[0].Title != "Band - Song" || tracks[0].Filepath != filepath.Join(cacheDir, "guild", "My-Song.mp3") {
+		t.Fatalf("unexpected tracks: %+v", tracks)
+	}
+	if data, err := os.ReadFile(tracks[0].Filepath); err != nil || string(data) != "audio" {
+		t.Errorf("upload is not copied: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(uploads, "My Song.MP3")); !os.IsNotExist(err) {
+		t.Errorf("upload is not removed: %v", err)
+	}
+}
+
+func TestExtractAudioUploadOverQuota(t *testing.T) {
+	uploads := t.TempDir()
+	cacheDir := t.TempDir()
+	repos := db.NewMemoryRepositories()
+
+	c := NewCache(uploads, cacheDir, "guild", repos).(*Cache)
+	c.uploadFormat = audioFormats["mp3"]
+	c.prober = &fakeProber{tags: library.Tags{Codec: "mp3"}}
+	fake := &fakeSettings{gs: settings.GuildSettings{CacheQuotaMB: 1}}
+	c.settings = fake
+
+	upload := filepath.Join(uploads, "big.mp3")
+	os.WriteFile(upload, []byte(strings.Repeat("a", 2<<20)), 0644)
+
+	// The audio over the quota is refused on import and the upload is kept
+	stats, err := c.ExtractAudioFromVideo(context.Background(), nil)
+	if err != nil || len(stats) != 1 || !strings.Contains(stats[0], ErrQuotaExceeded.Error()) {
+		t.Fatalf("unexpected extraction result: %v, %v", stats, err)
+	}
+	if _, err := os.Stat(upload); err != nil {
+		t.Errorf("upload is removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "guild", "big.mp3")); !os.IsNotExist(err) {
+		t.Errorf("audio over the quota is left in the cache")
+	}
+
+	// A full cache is refused before extracting
+	fake.gs = settings.GuildSettings{CacheQuotaFiles: 1}
+	os.WriteFile(filepath.Join(cacheDir, "guild", "cached.mp3"), []byte("audio"), 0644)
+	c.prober = nil
+	stats, err = c.ExtractAudioFromVideo(context.Background(), nil)
+	if err != nil || len(stats) != 1 || !strings.Contains(stats[0], ErrQuotaExceeded.Error()) {
+		t.Fatalf("unexpected extraction result: %v, %v", stats, err)
+	}
+	if _, err := os.Stat(upload); err != nil {
+		t.Errorf("upload is removed: %v", err)
+	}
+	if tracks, _ := repos.Tracks.GetAll(); len(tracks) != 0 {
+		t.Errorf("unexpected tracks: %+v", tracks)
+	}
+}
+
+func TestSyncCachedDirKeepsRenamedTracks(t *testing.T) {
+	cacheDir := t.TempDir()
+	repos := db.NewMemoryRepositories()
+	c := NewCache(t.TempDir(), cacheDir, "guild", repos)
+
+	os.MkdirAll(filepath.Join(cacheDir, "guild"), 0755)
+	os.MkdirAll(filepath.Join(cacheDir, "other"), 0755)
+	os.WriteFile(filepath.Join(cacheDir, "guild", "song.mp3"), []byte("audio"), 0644)
+
+	if added, _, _, err := c.SyncCachedDir(); err != nil || added != 1 {
+		t.Fatalf("unexpected sync result: %v, %v", added, err)
+	}
+	track, err := repos.Tracks.GetByFilepath(filepath.Join(cacheDir, "guild", "song.mp3"))
+	if err != nil || track.ContentHash == "" || track.SongID != track.ContentHash {
+		t.Fatalf("unexpected track: %+v, %v", track, err)
+	}
+	repos.Histories.Create(&db.History{GuildID: "guild", TrackID: track.ID, PlayCount: 3})
+
+	// Renamed within the guild folder
+	os.Rename(filepath.Join(cacheDir, "guild", "song.mp3"), filepath.Join(cacheDir, "guild", "renamed.mp3"))
+	if added, updated, removed, err := c.SyncCachedDir(); err != nil || added != 0 || updated != 1 || removed != 0 {
+		t.Fatalf("unexpected sync result: %v, %v, %v, %v", added, updated, removed, err)
+	}
+
+	// Moved to another guild folder
+	os.Rename(filepath.Join(cacheDir, "guild", "renamed.mp3"), filepath.Join(cacheDir, "other", "renamed.mp3"))
+	if added, updated, removed, err := c.SyncCachedDir(); err != nil || added != 0 || updated != 1 || removed != 0 {
+		t.Fatalf("unexpected sync result: %v, %v, %v, %v", added, updated, removed, err)
+	}
+
+	moved, err := repos.Tracks.GetByID(track.ID)
+	if err != nil || moved.Filepath != filepath.Join(cacheDir, "other", "renamed.mp3") {
+		t.Fatalf("moved file is not followed: %+v, %v", moved, err)
+	}
+	if history, err := repos.Histories.GetByTrackIDAndGuildID(track.ID, "guild"); err != nil || history.PlayCount != 3 {
+		t.Errorf("history is lost: %+v, %v", history, err)
+	}
+}
+
+func TestSyncCachedDirSkipsPlaylists(t *testing.T) {
+	cacheDir := t.TempDir()
+	repos := db.NewMemoryRepositories()
+	c := NewCache(t.TempDir(), cacheDir, "guild", repos)
+
+	os.MkdirAll(filepath.Join(cacheDir, "guild"), 0755)
+	os.WriteFile(filepath.Join(cacheDir, "guild", "song.mp3"), []byte("audio"), 0644)
+	os.WriteFile(filepath.Join(cacheDir, "guild", "mix.m3u"), []byte("song.mp3\n"), 0644)
+
+	// A playlist registered as a track by an earlier sync is removed
+	legacy := filepath.Join(cacheDir, "guild", "party.pls")
+	os.WriteFile(legacy, []byte("[playlist]\nFile1=song.mp3\n"), 0644)
+	repos.Tracks.Create(&db.Track{SongID: "party", Title: "party.pls", Filepath: legacy, Source: media.SourceLocalFile.String()})
+
+	if added, _, removed, err := c.SyncCachedDir(); err != nil || added != 1 || removed != 1 {
+		t.Fatalf("unexpected sync result: %v, %v, %v", added, removed, err)
+	}
+	if tracks, _ := repos.Tracks.GetAll(); len(tracks) != 1 || filepath.Base(tracks[0].Filepath) != "song.mp3" {
+		t.Errorf("unexpected tracks: %+v", tracks)
+	}
+	if files, err := c.ListCachedFiles(); err != nil || len(files) != 1 || files[0] != "song.mp3" {
+		t.Errorf("unexpected cached files: %v, %v", files, err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "guild", "mix.m3u")); err != nil {
+		t.Errorf("playlist is removed: %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	cacheDir := t.TempDir()
+	repos := db.NewMemoryRepositories()
+	c := NewCache(t.TempDir(), cacheDir, "guild", repos).(*Cache)
+	c.prober = &fakeProber{tags: library.Tags{Codec: "mp3"}, noAudio: "broken.mp3"}
+
+	guildFolder := filepath.Join(cacheDir, "guild")
+	os.MkdirAll(guildFolder, 0755)
+	os.MkdirAll(filepath.Join(cacheDir, "other"), 0755)
+	os.WriteFile(filepath.Join(guildFolder, "a.mp3"), []byte("audio"), 0644)
+	os.WriteFile(filepath.Join(guildFolder, "b.mp3"), []byte("audio"), 0644)
+	os.WriteFile(filepath.Join(cacheDir, "other", "c.mp3"), []byte("audio"), 0644)
+	os.WriteFile(filepath.Join(guildFolder, "broken.mp3"), []byte("junk"), 0644)
+	// Playlists are neither probed nor compared
+	os.WriteFile(filepath.Join(guildFolder, "mix.m3u"), []byte("a.mp3\n"), 0644)
+	os.WriteFile(filepath.Join(guildFolder, "copy.m3u"), []byte("a.mp3\n"), 0644)
+	if _, _, _, err := c.SyncCachedDir(); err != nil {
+		t.Fatal(err)
+	}
+	repos.Tracks.Create(&db.Track{SongID: "gone", Source: media.SourceLocalFile.String(), Filepath: filepath.Join(guildFolder, "gone.mp3")})
+
+	result, err := c.Verify(context.Background(), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Missing) != 1 || len(result.Corrupt) != 1 || len(result.Duplicates) != 1 || len(result.Duplicates[0]) != 3 {
+		t.Fatalf("unexpected report: %+v", result)
+	}
+
+	result, err = c.Verify(context.Background(), true, nil)
+	if err != nil || result.Fixed != 3 {
+		t.Fatalf("unexpected fix result: %+v, %v", result, err)
+	}
+	if _, err := os.Stat(filepath.Join(guildFolder, CorruptDir, "broken.mp3")); err != nil {
+		t.Errorf("corrupt file is not moved: %v", err)
+	}
+	for _, name := range []string{"mix.m3u", "copy.m3u"} {
+		if _, err := os.Stat(filepath.Join(guildFolder, name)); err != nil {
+			t.Errorf("playlist %v is not kept: %v", name, err)
+		}
+	}
+
+	// A single copy is left in the guild folder, both tracks point at it
+	tracks, _ := repos.Tracks.GetAll()
+	for _, track := range tracks {
+		if filepath.Dir(track.Filepath) == guildFolder && track.Filepath != filepath.Join(guildFolder, "a.mp3") {
+			t.Errorf("track is not pointing at the kept copy: %+v", track)
+		}
+	}
+
+	result, err = c.Verify(context.Background(), false, nil)
+	if err != nil || len(result.Missing)+len(result.Corrupt) != 0 || len(result.Duplicates[0]) != 2 {
+		t.Errorf("unexpected report after fix: %+v, %v", result, err)
+	}
+}
+
+type fakeSettings struct {
+	settings.ISettings
+	gs settings.GuildSettings
+}
+
+func (s *fakeSettings) Get(guildID string) (*settings.GuildSettings, error) {
+	gs := s.gs
+	return &gs, nil
+}
+
+func TestQuotaAndRetention(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	cacheDir := t.TempDir()
+	repos := db.NewMemoryRepositories()
+	c := NewCache(t.TempDir(), cacheDir, "guild", repos).(*Cache)
+	fake := &fakeSettings{gs: settings.GuildSettings{CacheQuotaFiles: 2}}
+	c.settings = fake
+
+	played, err := c.CacheAttachment("played.mp3", srv.URL+"/played", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := c.CacheAttachment("stale.mp3", srv.URL+"/stale", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CacheAttachment("extra.mp3", srv.URL+"/extra", 0); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "guild", "extra.mp3")); !os.IsNotExist(err) {
+		t.Errorf("file over the quota is left in the cache")
+	}
+
+	repos.Histories.Create(&db.History{GuildID: "guild", TrackID: played.ID, PlayCount: 5})
+	stale.FileModTime = time.Now().AddDate(0, 0, -100).UnixNano()
+	repos.Tracks.Update(stale)
+
+	// A dry run only reports the stale track
+	fake.gs.RetentionDays = 90
+	report, err := c.Retention(context.Background(), false)
+	if err != nil || report.Usage.Files != 2 || len(report.Expired) != 1 || report.Expired[0].Track.ID != stale.ID {
+		t.Fatalf("unexpected report: %+v, %v", report, err)
+	}
+	if _, err := os.Stat(stale.Filepath); err != nil {
+		t.Fatalf("dry run deleted the file: %v", err)
+	}
+
+	fake.gs.RetentionDays = 0
+	fake.gs.RetentionKeepTop = 1
+	if report, err = c.Retention(context.Background(), true); err != nil || len(report.Expired) != 1 || report.Expired[0].Track.ID != stale.ID {
+		t.Fatalf("unexpected report: %+v, %v", report, err)
+	}
+	if _, err := os.Stat(stale.Filepath); !os.IsNotExist(err) {
+		t.Errorf("expired file is not deleted: %v", err)
+	}
+	if _, err := repos.Tracks.GetByID(stale.ID); err == nil {
+		t.Errorf("expired track is not deleted")
+	}
+	if _, err := os.Stat(played.Filepath); err != nil {
+		t.Errorf("most played file is deleted: %v", err)
+	}
+}
+
+func TestRetentionWithoutModTime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	repos := db.NewMemoryRepositories()
+	c := NewCache(t.TempDir(), t.TempDir(), "guild", repos).(*Cache)
+	c.settings = &fakeSettings{gs: settings.GuildSettings{RetentionDays: 90}}
+
+	// Tracks cached before content hashes were added have no modification time
+	legacy, err := c.CacheAttachment("legacy.mp3", srv.URL+"/legacy", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy.FileModTime = 0
+	repos.Tracks.Update(legacy)
+
+	report, err := c.Retention(context.Background(), false)
+	if err != nil || len(report.Expired) != 0 {
+		t.Errorf("unexpected report: %+v, %v", report, err)
+	}
+
+	old := time.Now().AddDate(0, 0, -100)
+	os.Chtimes(legacy.Filepath, old, old)
+	if report, err = c.Retention(context.Background(), false); err != nil || len(report.Expired) != 1 {
+		t.Errorf("unexpected report: %+v, %v", report, err)
+	}
+}
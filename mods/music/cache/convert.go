@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keshon/melodix-player/mods/music/library"
+)
+
+// UploadExtensions lists the video and audio files picked up from the upload folder.
+var UploadExtensions = []string{
+	".mp4", ".mkv", ".webm", ".flv", ".mov", ".avi", ".m4v", ".mpg", ".mpeg", ".ts",
+	".mp3", ".flac", ".wav", ".m4a", ".ogg", ".opus",
+}
+
+// IsUploadFile reports whether name has one of the upload extensions.
+func IsUploadFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range UploadExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// audioFormat is a codec audio is stored with in the cache.
+type audioFormat struct {
+	codec    string // codec name reported by ffprobe
+	encoder  string
+	ext      string
+	cover    bool // the container keeps cover art
+	lossless bool
+}
+
+var audioFormats = map[string]audioFormat{
+	"mp3":  {codec: "mp3", encoder: "libmp3lame", ext: ".mp3", cover: true},
+	"aac":  {codec: "aac", encoder: "aac", ext: ".m4a", cover: true},
+	"opus": {codec: "opus", encoder: "libopus", ext: ".opus"},
+	"flac": {codec: "flac", encoder: "flac", ext: ".flac", cover: true, lossless: true},
+}
+
+// convertAudio stores the audio of inputPath as outputBase plus the extension of
+// the upload codec and returns the written path along with the input tags. Audio
+// already using the codec is copied when the container matches too, remuxed
+// otherwise, and only re-encoded when the codec differs.
+func (c *Cache) convertAudio(ctx context.Context, inputPath, outputBase string, report func(processed, duration time.Duration)) (string, *library.Tags, error) {
+	tags, err := c.prober.Probe(ctx, inputPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if tags.Codec == "" {
+		return "", nil, fmt.Errorf("%v has no audio", filepath.Base(inputPath))
+	}
+
+	format := c.uploadFormat
+	outputPath := outputBase + format.ext
+
+	if tags.Codec == format.codec && strings.EqualFold(filepath.Ext(inputPath), format.ext) {
+		if err := copyFile(inputPath, outputPath); err != nil {
+			os.Remove(outputPath)
+			return "", nil, err
+		}
+		return outputPath, tags, nil
+	}
+
+	args := []string{"-i", inputPath, "-map", "0:a:0"}
+	if tags.HasCover && format.cover {
+		args = append(args, "-map", "0:"+strconv.Itoa(tags.CoverStream), "-c:v", "copy", "-disposition:v:0", "attached_pic")
+	}
+
+	if tags.Codec == format.codec {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args, "-c:a", format.encoder)
+		if !format.lossless {
+			args = append(args, "-b:a", fmt.Sprintf("%dk", c.uploadBitrate))
+		}
+	}
+
+	// Ogg files keep their tags on the audio stream, write the main ones to the
+	// output container explicitly
+	metadata := [][2]string{{"title", tags.Title}, {"artist", tags.Artist}, {"album", tags.Album}}
+	if tags.TrackNumber > 0 {
+		metadata = append(metadata, [2]string{"track", strconv.Itoa(tags.TrackNumber)})
+	}
+	for _, tag := range metadata {
+		if tag[1] != "" {
+			args = append(args, "-metadata", tag[0]+"="+tag[1])
+		}
+	}
+
+	args = append(args, outputPath)
+
+	if err := c.runFFmpeg(ctx, args, outputPath, report); err != nil {
+		return "", nil, err
+	}
+	return outputPath, tags, nil
+}
+
+// trackTitle names a track after its tags, falling back to the given name.
+func trackTitle(tags *library.Tags, fallback string) string {
+	if tags == nil || tags.Title == "" {
+		return fallback
+	}
+	if tags.Artist != "" {
+		return tags.Artist + " - " + tags.Title
+	}
+	return tags.Title
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("error opening %v: %v", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("error creating %v: %v", dst, err)
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("error copying %v: %v", src, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/playlist"
+	"github.com/keshon/melodix-player/mods/music/sources"
+	"github.com/keshon/melodix-player/mods/music/storage"
+)
+
+// fileIdentity is the content hash of a cached file along with the size and
+// modification time it was computed for.
+type fileIdentity struct {
+	hash    string
+	size    int64
+	modTime int64 // unix nanoseconds
+}
+
+// identify hashes the stored file by streaming it, unless the known track
+// already holds the hash for the current size and modification time.
+func (c *Cache) identify(ctx context.Context, path string, known *db.Track) (fileIdentity, error) {
+	obj, err := c.store.Stat(ctx, path)
+	if err != nil {
+		return fileIdentity{}, err
+	}
+
+	id := fileIdentity{size: obj.Size, modTime: obj.ModTime.UnixNano()}
+	if known != nil && known.ContentHash != "" && known.FileSize == id.size && known.FileModTime == id.modTime {
+		id.hash = known.ContentHash
+		return id, nil
+	}
+
+	file, err := c.store.Open(ctx, path)
+	if err != nil {
+		return fileIdentity{}, err
+	}
+	defer file.Close()
+
+	id.hash, err = hashReader(file)
+	if err != nil {
+		return fileIdentity{}, fmt.Errorf("error hashing %v: %v", path, err)
+	}
+	return id, nil
+}
+
+// importFile moves a file written to the local cache folder to the storage and
+// returns its identity, hashed before it leaves the local disk. Files beyond the
+// cache quota of the guild are refused with ErrQuotaExceeded.
+func (c *Cache) importFile(ctx context.Context, localPath, path string) (fileIdentity, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fileIdentity{}, err
+	}
+	hash, err := hashReader(file)
+	file.Close()
+	if err != nil {
+		return fileIdentity{}, fmt.Errorf("error hashing %v: %v", localPath, err)
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fileIdentity{}, err
+	}
+	if err := c.checkQuota(ctx, path, info.Size()); err != nil {
+		return fileIdentity{}, err
+	}
+
+	if err := c.store.Import(ctx, localPath, path); err != nil {
+		return fileIdentity{}, fmt.Errorf("error storing %v: %v", path, err)
+	}
+
+	obj, err := c.store.Stat(ctx, path)
+	if err != nil {
+		return fileIdentity{}, err
+	}
+	return fileIdentity{hash: hash, size: obj.Size, modTime: obj.ModTime.UnixNano()}, nil
+}
+
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (id fileIdentity) apply(track *db.Track) {
+	track.ContentHash = id.hash
+	track.FileSize = id.size
+	track.FileModTime = id.modTime
+}
+
+func (id fileIdentity) matches(track db.Track) bool {
+	return track.ContentHash == id.hash && track.FileSize == id.size && track.FileModTime == id.modTime
+}
+
+// localSongID names a new local file track after its content, falling back to
+// its path for copies of a file that is already tracked.
+func (c *Cache) localSongID(hash, path string) string {
+	if hash != "" {
+		if _, err := c.tracks.GetBySongID(hash); err != nil {
+			return hash
+		}
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(path)))
+}
+
+// movedTrack returns the local track of the content whose file is gone, which
+// happens when the file was renamed or moved to another guild folder.
+func (c *Cache) movedTrack(hash string) *db.Track {
+	tracks, err := c.tracks.GetByContentHash(hash)
+	if err != nil {
+		return nil
+	}
+
+	for _, track := range tracks {
+		if track.Source != media.SourceLocalFile.String() {
+			continue
+		}
+		if _, err := c.store.Stat(context.Background(), track.Filepath); errors.Is(err, storage.ErrNotExist) {
+			return &track
+		}
+	}
+	return nil
+}
+
+// cachedContent returns the local track of the content stored in folder, if any.
+func (c *Cache) cachedContent(ctx context.Context, folder, hash string) *db.Track {
+	tracks, err := c.tracks.GetByContentHash(hash)
+	if err != nil {
+		return nil
+	}
+
+	for _, track := range tracks {
+		if track.Source != media.SourceLocalFile.String() || filepath.Dir(track.Filepath) != folder {
+			continue
+		}
+		if _, err := c.store.Stat(ctx, track.Filepath); err == nil {
+			return &track
+		}
+	}
+	return nil
+}
+
+// pathTaken tells whether a file is stored at path or a track refers to it.
+func (c *Cache) pathTaken(ctx context.Context, path string) bool {
+	if _, err := c.store.Stat(ctx, path); err == nil {
+		return true
+	}
+	_, err := c.tracks.GetByFilepath(path)
+	return err == nil
+}
+
+// movedFile looks for the content of a track whose file is gone in every guild
+// cache folder and returns its new path.
+func (c *Cache) movedFile(track db.Track) string {
+	if track.ContentHash == "" {
+		return ""
+	}
+
+	for _, obj := range c.cachedFiles() {
+		if obj.Size != track.FileSize {
+			continue
+		}
+		// Files tracked already are copies, not the moved one
+		if _, err := c.tracks.GetByFilepath(obj.Path); err == nil {
+			continue
+		}
+		if id, err := c.identify(context.Background(), obj.Path, nil); err == nil && id.hash == track.ContentHash {
+			return obj.Path
+		}
+	}
+	return ""
+}
+
+// IsGuildFolder tells guild folders apart from other folders kept in the cache
+// folder, like library covers.
+func IsGuildFolder(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && name != sources.LibraryCoverFolder
+}
+
+// cachedFiles lists the audio files of every guild cache folder, leaving out
+// playlists kept there.
+func (c *Cache) cachedFiles() []storage.Object {
+	ctx := context.Background()
+
+	folders, err := c.store.Folders(ctx, c.cacheFolder)
+	if err != nil {
+		return nil
+	}
+
+	var objects []storage.Object
+	for _, folder := range folders {
+		if !IsGuildFolder(folder) {
+			continue
+		}
+		files, err := c.store.List(ctx, filepath.Join(c.cacheFolder, folder))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if !strings.HasSuffix(file.Path, PartialExt) && !playlist.IsPlaylistFile(file.Path) {
+				objects = append(objects, file)
+			}
+		}
+	}
+	return objects
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/mods/music/storage"
+)
+
+// ErrQuotaExceeded is returned when a file does not fit the quota of the guild
+// cache folder or of the upload folder.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits the guild cache folder, zero values are unlimited.
+type Quota struct {
+	MaxBytes int64
+	MaxFiles int
+}
+
+// Usage is what the guild cache folder holds.
+type Usage struct {
+	Bytes int64
+	Files int
+}
+
+// quota returns the limits set for the guild, or none when its settings can't be read.
+func (c *Cache) quota() Quota {
+	if c.settings == nil {
+		return Quota{}
+	}
+
+	gs, err := c.settings.Get(c.guildID)
+	if err != nil {
+		slog.Warnf("Error getting cache quota of guild %v: %v", c.guildID, err)
+		return Quota{}
+	}
+	return Quota{MaxBytes: int64(gs.CacheQuotaMB) << 20, MaxFiles: gs.CacheQuotaFiles}
+}
+
+// Usage sums the files of the guild cache folder.
+func (c *Cache) Usage(ctx context.Context) (Usage, error) {
+	files, err := c.store.List(ctx, filepath.Join(c.cacheFolder, c.guildID))
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return Usage{}, err
+	}
+
+	var usage Usage
+	for _, file := range files {
+		if strings.HasSuffix(file.Path, PartialExt) {
+			continue
+		}
+		usage.Bytes += file.Size
+		usage.Files++
+	}
+	return usage, nil
+}
+
+// checkQuota tells whether a file of size bytes can be stored at path, a file
+// already there being replaced.
+func (c *Cache) checkQuota(ctx context.Context, path string, size int64) error {
+	quota := c.quota()
+	if quota.MaxBytes == 0 && quota.MaxFiles == 0 {
+		return nil
+	}
+
+	usage, err := c.Usage(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting cache usage %v", err)
+	}
+	if obj, err := c.store.Stat(ctx, path); err == nil {
+		usage.Bytes -= obj.Size
+		usage.Files--
+	}
+
+	if quota.MaxBytes > 0 && usage.Bytes+size > quota.MaxBytes {
+		return fmt.Errorf("%w: the cache of this server is limited to %v, %v are used", ErrQuotaExceeded, c.humanReadableSize(quota.MaxBytes), c.humanReadableSize(usage.Bytes))
+	}
+	if quota.MaxFiles > 0 && usage.Files+1 > quota.MaxFiles {
+		return fmt.Errorf("%w: the cache of this server is limited to %d files", ErrQuotaExceeded, quota.MaxFiles)
+	}
+	return nil
+}
+
+// uploadSpace returns how many bytes may still be written to the upload folder,
+// or 0 when it is unlimited.
+func (c *Cache) uploadSpace() (int64, error) {
+	if c.uploadQuota == 0 {
+		return 0, nil
+	}
+
+	files, err := os.ReadDir(c.uploadsFolder)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("error reading uploaded folder: %v", err)
+	}
+
+	var used int64
+	for _, file := range files {
+		if info, err := file.Info(); err == nil && !file.IsDir() {
+			used += info.Size()
+		}
+	}
+
+	if used >= c.uploadQuota {
+		return 0, fmt.Errorf("%w: the upload folder is limited to %v", ErrQuotaExceeded, c.humanReadableSize(c.uploadQuota))
+	}
+	return c.uploadQuota - used, nil
+}
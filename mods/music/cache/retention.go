@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/precode"
+)
+
+// retentionListLimit bounds the tracks listed in a report to fit a message.
+const retentionListLimit = 20
+
+// ExpiredTrack is a cached track the retention rules of the guild delete.
+type ExpiredTrack struct {
+	Track      db.Track
+	Size       int64
+	PlayCount  uint
+	LastPlayed time.Time // zero when never played
+	Cached     time.Time // modification time of the file
+	Reason     string
+}
+
+// RetentionReport lists the cached tracks of a guild deleted by its retention
+// rules, or that would be on a dry run.
+type RetentionReport struct {
+	Usage    Usage
+	Quota    Quota
+	Days     int
+	KeepTop  int
+	Expired  []ExpiredTrack
+	Applied  bool
+	Released int64
+}
+
+// Retention evaluates the retention rules of the guild on its cached tracks:
+// tracks not played for RetentionDays and tracks beyond the RetentionKeepTop
+// most played ones expire. With apply, their files are deleted, YouTube tracks
+// being played from YouTube again and other tracks removed with their history.
+func (c *Cache) Retention(ctx context.Context, apply bool) (*RetentionReport, error) {
+	if c.settings == nil {
+		return nil, fmt.Errorf("no settings for guild %v", c.guildID)
+	}
+	gs, err := c.settings.Get(c.guildID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting settings %v", err)
+	}
+
+	usage, err := c.Usage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting cache usage %v", err)
+	}
+
+	report := &RetentionReport{
+		Usage:   usage,
+		Quota:   Quota{MaxBytes: int64(gs.CacheQuotaMB) << 20, MaxFiles: gs.CacheQuotaFiles},
+		Days:    gs.RetentionDays,
+		KeepTop: gs.RetentionKeepTop,
+		Applied: apply,
+	}
+	if report.Days == 0 && report.KeepTop == 0 {
+		return report, nil
+	}
+
+	tracks, err := c.tracks.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("error getting all tracks %v", err)
+	}
+
+	cacheGuildFolder := filepath.Join(c.cacheFolder, c.guildID)
+
+	var cached []ExpiredTrack
+	for _, track := range tracks {
+		if track.Source != media.SourceLocalFile.String() || filepath.Dir(track.Filepath) != cacheGuildFolder {
+			continue
+		}
+		obj, err := c.store.Stat(ctx, track.Filepath)
+		if err != nil {
+			continue
+		}
+
+		// Tracks from before content hashes have no modification time until synced
+		entry := ExpiredTrack{Track: track, Size: obj.Size, Cached: obj.ModTime}
+		if track.FileModTime != 0 {
+			entry.Cached = time.Unix(0, track.FileModTime)
+		}
+		if history, err := c.histories.GetByTrackIDAndGuildID(track.ID, c.guildID); err == nil {
+			entry.PlayCount = history.PlayCount
+			entry.LastPlayed = history.LastPlayed
+		}
+		cached = append(cached, entry)
+	}
+
+	// Most played first, the most recently played first among equals
+	sort.SliceStable(cached, func(i, j int) bool {
+		if cached[i].PlayCount != cached[j].PlayCount {
+			return cached[i].PlayCount > cached[j].PlayCount
+		}
+		return cached[i].LastPlayed.After(cached[j].LastPlayed)
+	})
+
+	deadline := time.Now().AddDate(0, 0, -report.Days)
+	for i, entry := range cached {
+		switch {
+		case report.KeepTop > 0 && i >= report.KeepTop:
+			entry.Reason = fmt.Sprintf("not in the top %d", report.KeepTop)
+		case report.Days > 0 && entry.LastPlayed.IsZero() && entry.Cached.Before(deadline):
+			entry.Reason = fmt.Sprintf("never played in %d days", report.Days)
+		case report.Days > 0 && !entry.LastPlayed.IsZero() && entry.LastPlayed.Before(deadline):
+			entry.Reason = fmt.Sprintf("not played for %d days", report.Days)
+		default:
+			continue
+		}
+		report.Expired = append(report.Expired, entry)
+	}
+
+	if !apply {
+		return report, nil
+	}
+
+	for _, entry := range report.Expired {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if err := c.store.Remove(ctx, entry.Track.Filepath); err != nil {
+			return report, fmt.Errorf("error removing cached file %v", err)
+		}
+		if err := precode.NewPrecoder(c.cacheFolder).Remove(entry.Track.Filepath); err != nil {
+			slog.Warnf("Error removing pre-encoded file: %v", err)
+		}
+		if _, _, err := c.forget(entry.Track); err != nil {
+			return report, err
+		}
+
+		slog.Infof("Retention removed %v from the cache of guild %v: %v", entry.Track.Title, c.guildID, entry.Reason)
+		report.Released += entry.Size
+	}
+
+	return report, nil
+}
+
+// String formats the report for a message.
+func (r *RetentionReport) String() string {
+	var b strings.Builder
+
+	usage := humanSize(r.Usage.Bytes)
+	if r.Quota.MaxBytes > 0 {
+		usage += " of " + humanSize(r.Quota.MaxBytes)
+	}
+	files := fmt.Sprintf("%d", r.Usage.Files)
+	if r.Quota.MaxFiles > 0 {
+		files += fmt.Sprintf(" of %d", r.Quota.MaxFiles)
+	}
+	fmt.Fprintf(&b, "**Usage:** %s, %s files\n", usage, files)
+
+	var rules []string
+	if r.Days > 0 {
+		rules = append(rules, fmt.Sprintf("delete tracks not played for %d days", r.Days))
+	}
+	if r.KeepTop > 0 {
+		rules = append(rules, fmt.Sprintf("keep the %d most played tracks", r.KeepTop))
+	}
+	if len(rules) == 0 {
+		b.WriteString("**Retention:** none, cached tracks are kept")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "**Retention:** %s\n", strings.Join(rules, ", "))
+
+	if len(r.Expired) == 0 {
+		b.WriteString("\n✅ No cached track expired")
+		return b.String()
+	}
+
+	var size int64
+	for _, entry := range r.Expired {
+		size += entry.Size
+	}
+	verb := "Would delete"
+	if r.Applied {
+		verb = "Deleted"
+	}
+	fmt.Fprintf(&b, "\n**%s:** %d tracks, %s\n", verb, len(r.Expired), humanSize(size))
+	for i, entry := range r.Expired {
+		if i == retentionListLimit {
+			fmt.Fprintf(&b, "…and %d more\n", len(r.Expired)-i)
+			break
+		}
+		fmt.Fprintf(&b, "`%s` %s\n", filepath.Base(entry.Track.Filepath), entry.Reason)
+	}
+	return b.String()
+}
@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/jobs"
+	"github.com/keshon/melodix-player/mods/music/library"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/storage"
+)
+
+// CorruptDir is the folder of a guild cache folder corrupt files are moved to
+// when fixing the cache.
+const CorruptDir = ".corrupt"
+
+// VerifyReport lists the problems found in the cache of a guild.
+type VerifyReport struct {
+	Missing    []string   // tracks whose file is gone
+	Corrupt    []string   // files ffprobe finds no audio in
+	Duplicates [][]string // files sharing the same content, in any guild folder
+	Fixed      int
+}
+
+// Verify checks the cache folder of the guild for missing, corrupt and
+// duplicate files. With fix, missing tracks are resolved like SyncCachedDir
+// does, corrupt files are moved to the CorruptDir folder and duplicate copies
+// within the guild folder are removed, their tracks pointing at the kept copy.
+func (c *Cache) Verify(ctx context.Context, fix bool, report jobs.Report) (*VerifyReport, error) {
+	if report == nil {
+		report = func(jobs.Progress) {}
+	}
+
+	cacheGuildFolder := filepath.Join(c.cacheFolder, c.guildID)
+	if _, err := c.store.List(ctx, cacheGuildFolder); errors.Is(err, storage.ErrNotExist) {
+		return nil, fmt.Errorf("cache folder for guild %s does not exist", c.guildID)
+	}
+
+	result := &VerifyReport{}
+
+	// Tracks of the guild folder whose file is gone
+	tracks, err := c.tracks.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("error getting all tracks %v", err)
+	}
+	for _, track := range tracks {
+		if track.Source != media.SourceLocalFile.String() || filepath.Dir(track.Filepath) != cacheGuildFolder {
+			continue
+		}
+		if _, err := c.store.Stat(ctx, track.Filepath); !errors.Is(err, storage.ErrNotExist) {
+			continue
+		}
+
+		result.Missing = append(result.Missing, track.Filepath)
+		if fix {
+			updated, removed, err := c.resolveMissing(track)
+			if err != nil {
+				return nil, err
+			}
+			if updated || removed {
+				result.Fixed++
+			}
+		}
+	}
+
+	// Hash the files of every guild folder to find copies of the guild files
+	files := c.cachedFiles()
+	byHash := map[string][]string{}
+	for i, file := range files {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		report(jobs.Progress{Stage: fmt.Sprintf("checking files %d/%d", i+1, len(files))})
+
+		path := file.Path
+		track, err := c.tracks.GetByFilepath(path)
+		if err != nil {
+			track = nil
+		}
+
+		inGuild := filepath.Dir(path) == cacheGuildFolder
+		if inGuild {
+			if tags, err := c.probe(ctx, path); err != nil || tags.Codec == "" {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				result.Corrupt = append(result.Corrupt, path)
+				if fix {
+					if err := c.moveCorrupt(ctx, path, track); err != nil {
+						return nil, err
+					}
+					result.Fixed++
+				}
+				continue
+			}
+		}
+
+		id, err := c.identify(ctx, path, track)
+		if err != nil {
+			return nil, fmt.Errorf("error hashing cached file %v", err)
+		}
+		byHash[id.hash] = append(byHash[id.hash], path)
+	}
+
+	for _, group := range byHash {
+		if len(group) < 2 {
+			continue
+		}
+		inGuild := 0
+		for _, path := range group {
+			if filepath.Dir(path) == cacheGuildFolder {
+				inGuild++
+			}
+		}
+		if inGuild == 0 {
+			continue
+		}
+
+		sort.Strings(group)
+		result.Duplicates = append(result.Duplicates, group)
+
+		if fix && inGuild > 1 {
+			fixed, err := c.removeCopies(ctx, group, cacheGuildFolder)
+			if err != nil {
+				return nil, err
+			}
+			result.Fixed += fixed
+		}
+	}
+	sort.Slice(result.Duplicates, func(i, j int) bool {
+		return result.Duplicates[i][0] < result.Duplicates[j][0]
+	})
+
+	return result, nil
+}
+
+// moveCorrupt moves a corrupt file out of the guild folder and resolves its
+// track as if the file was removed.
+func (c *Cache) moveCorrupt(ctx context.Context, path string, track *db.Track) error {
+	corruptPath := filepath.Join(filepath.Dir(path), CorruptDir, filepath.Base(path))
+	if err := c.store.Rename(ctx, path, corruptPath); err != nil {
+		return fmt.Errorf("error moving corrupt file %v", err)
+	}
+
+	if track != nil {
+		// The content is not there anymore, do not follow it to a copy
+		track.ContentHash = ""
+		if _, _, err := c.resolveMissing(*track); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeCopies keeps a single copy of the content in the guild folder, the
+// first tracked one, and points the tracks of the removed copies at it so
+// their history is kept.
+func (c *Cache) removeCopies(ctx context.Context, group []string, cacheGuildFolder string) (int, error) {
+	var copies []string
+	tracks := map[string]*db.Track{}
+	for _, path := range group {
+		if filepath.Dir(path) != cacheGuildFolder {
+			continue
+		}
+		copies = append(copies, path)
+		if track, err := c.tracks.GetByFilepath(path); err == nil {
+			tracks[path] = track
+		}
+	}
+
+	kept := copies[0]
+	for _, path := range copies {
+		if tracks[path] != nil {
+			kept = path
+			break
+		}
+	}
+
+	removed := 0
+	for _, path := range copies {
+		if path == kept {
+			continue
+		}
+		if err := c.store.Remove(ctx, path); err != nil {
+			return removed, fmt.Errorf("error removing duplicate file %v", err)
+		}
+		if track := tracks[path]; track != nil {
+			track.Filepath = kept
+			if err := c.tracks.Update(track); err != nil {
+				return removed, fmt.Errorf("error updating track in database %v", err)
+			}
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// probe reads the tags of a stored file, from a presigned URL for buckets.
+func (c *Cache) probe(ctx context.Context, path string) (*library.Tags, error) {
+	location, err := c.store.Location(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return c.prober.Probe(ctx, location)
+}
+
+// String formats the report for a message.
+func (r *VerifyReport) String() string {
+	var b strings.Builder
+
+	if len(r.Missing)+len(r.Corrupt)+len(r.Duplicates) == 0 {
+		b.WriteString("✅ No problems found in cached files")
+	} else {
+		b.WriteString("🩺 Cached files verified\n")
+	}
+
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n**%s:** %d\n", title, len(lines))
+		for _, line := range lines {
+			fmt.Fprintf(&b, "`%s`\n", line)
+		}
+	}
+	section("Missing", r.Missing)
+	section("Corrupt", r.Corrupt)
+
+	var duplicates []string
+	for _, group := range r.Duplicates {
+		duplicates = append(duplicates, strings.Join(group, "` = `"))
+	}
+	section("Duplicates", duplicates)
+
+	if r.Fixed > 0 {
+		fmt.Fprintf(&b, "\n**Fixed:** %d", r.Fixed)
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/mods/music/cache"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+// messageAttachments returns the attachments of the command message, or of the
+// message it replies to when it has none.
+func (d *Discord) messageAttachments() []*discordgo.MessageAttachment {
+	m := d.Message
+	if len(m.Message.Attachments) > 0 {
+		return m.Message.Attachments
+	}
+
+	if m.Message.ReferencedMessage != nil {
+		return m.Message.ReferencedMessage.Attachments
+	}
+
+	if ref := m.Message.MessageReference; ref != nil && ref.MessageID != "" {
+		referenced, err := d.Session.ChannelMessage(ref.ChannelID, ref.MessageID)
+		if err != nil {
+			slog.Warnf("Error fetching referenced message %v: %v", ref.MessageID, err)
+			return nil
+		}
+		return referenced.Attachments
+	}
+
+	return nil
+}
+
+// songsFromAttachments caches audio attachments as local file tracks, noting
+// the ones rejected by the configured format and size limits.
+func (d *Discord) songsFromAttachments(attachments []*discordgo.MessageAttachment) (songs []*media.Song, notices []string) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		slog.Errorf("Error loading config: %v", err)
+		return nil, []string{"Attachments can't be processed right now."}
+	}
+
+	maxSize := int64(cfg.AttachmentsMaxSizeMB) << 20
+	formats := cfg.AttachmentFormats()
+
+	c := d.newCache()
+
+	for _, attachment := range attachments {
+		format := strings.ToLower(strings.TrimPrefix(filepath.Ext(attachment.Filename), "."))
+		if !slices.Contains(formats, format) {
+			notices = append(notices, fmt.Sprintf("`%v` skipped: allowed formats are %v.", attachment.Filename, strings.Join(formats, ", ")))
+			continue
+		}
+		if maxSize > 0 && int64(attachment.Size) > maxSize {
+			notices = append(notices, fmt.Sprintf("`%v` skipped: files are limited to %v MB.", attachment.Filename, cfg.AttachmentsMaxSizeMB))
+			continue
+		}
+
+		track, err := c.CacheAttachment(attachment.Filename, attachment.URL, maxSize)
+		if err != nil {
+			if errors.Is(err, cache.ErrTooLarge) {
+				notices = append(notices, fmt.Sprintf("`%v` skipped: files are limited to %v MB.", attachment.Filename, cfg.AttachmentsMaxSizeMB))
+			} else {
+				slog.Errorf("Error caching attachment %v: %v", attachment.Filename, err)
+				notices = append(notices, fmt.Sprintf("`%v` skipped: download failed.", attachment.Filename))
+			}
+			continue
+		}
+
+		songs = append(songs, &media.Song{
+			Title:    track.Title,
+			Filepath: track.Filepath,
+			SongID:   track.SongID,
+			Source:   media.SourceLocalFile,
+		})
+	}
+
+	return songs, notices
+}
@@ -1,11 +1,12 @@
 package discord
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/gookit/slog"
 	"github.com/keshon/melodix-player/internal/config"
-	"github.com/keshon/melodix-player/mods/music/cache"
+	"github.com/keshon/melodix-player/mods/music/jobs"
 )
 
 func (d *Discord) handleCacheListCommand(param string) {
@@ -19,11 +20,7 @@ func (d *Discord) handleCacheListCommand(param string) {
 		return
 	}
 
-	guildID := d.GuildID
-	uploadsFolder := "./upload"
-	cacheFolder := "./cache"
-
-	c := cache.NewCache(uploadsFolder, cacheFolder, guildID)
+	c := d.newCache()
 
 	if param == "" {
 		list, err := c.ListCachedFiles()
@@ -50,4 +47,25 @@ func (d *Discord) handleCacheListCommand(param string) {
 			d.editMessageEmbed("🗃 All cached files are synced successfully\n\nUse `"+d.prefix+"cached` command to see available files\n\n**Added:** "+fmt.Sprintf("%d", added)+"\n**Updated:** "+fmt.Sprintf("%d", updated)+"\n**Removed:** "+fmt.Sprintf("%d", removed), msg.ID)
 		}
 	}
+
+	if param == "verify" || param == "verify fix" {
+		fix := param == "verify fix"
+		run := func(ctx context.Context, report jobs.Report) (string, error) {
+			result, err := c.Verify(ctx, fix, report)
+			if err != nil {
+				return "", err
+			}
+			return result.String(), nil
+		}
+		d.submitJob(jobs.KindVerify, param, run)
+	}
+
+	if param == "retention" {
+		report, err := c.Retention(context.Background(), false)
+		if err != nil {
+			d.sendMessageEmbed(err.Error())
+			return
+		}
+		d.sendMessageEmbed("🧹 Cache quota and retention (dry run)\n\n" + report.String())
+	}
 }
@@ -1,9 +1,11 @@
 package discord
 
 import (
+	"context"
+
 	"github.com/gookit/slog"
 	"github.com/keshon/melodix-player/internal/config"
-	"github.com/keshon/melodix-player/mods/music/cache"
+	"github.com/keshon/melodix-player/mods/music/jobs"
 )
 
 func (d *Discord) handleCacheUrlCommand(param string) {
@@ -17,24 +19,15 @@ func (d *Discord) handleCacheUrlCommand(param string) {
 		return
 	}
 
-	guildID := d.GuildID
-	uploadsFolder := "./upload"
-	cacheFolder := "./cache"
-
-	c := cache.NewCache(uploadsFolder, cacheFolder, guildID)
+	c := d.newCache()
 
 	if param == "" {
 		d.sendMessageEmbed("Error: No URL specified")
 		return
 	}
 
-	waitMsg := d.sendMessageEmbed("Please wait...")
-
-	resp, err := c.Curl(param)
-	if err != nil {
-		d.sendMessageEmbed(err.Error())
-		return
+	run := func(ctx context.Context, report jobs.Report) (string, error) {
+		return c.Curl(ctx, param, report)
 	}
-
-	d.editMessageEmbed(resp, waitMsg.ID)
+	d.submitJob(jobs.KindCurl, param, run)
 }
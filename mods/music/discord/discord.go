@@ -9,7 +9,12 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/gookit/slog"
 	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/internal/settings"
+	"github.com/keshon/melodix-player/mods/music/cache"
+	"github.com/keshon/melodix-player/mods/music/history"
 	"github.com/keshon/melodix-player/mods/music/player"
+	"github.com/keshon/melodix-player/mods/music/radio"
 )
 
 type Discord struct {
@@ -19,9 +24,15 @@ type Discord struct {
 	GuildID          string
 	IsInstanceActive bool
 	prefix           string
+	adminUserID      string
+	uploadsFolder    string
+	cacheFolder      string
+	repos            *db.Repositories
+	settings         settings.ISettings
+	radioStations    []radio.Station // results of the last radio search
 }
 
-func NewDiscord(session *discordgo.Session) *Discord {
+func NewDiscord(session *discordgo.Session, repos *db.Repositories) *Discord {
 	config, err := config.NewConfig()
 	if err != nil {
 		slog.Fatalf("Error loading config: %v", err)
@@ -32,6 +43,11 @@ func NewDiscord(session *discordgo.Session) *Discord {
 		Message:          nil,
 		IsInstanceActive: true,
 		prefix:           config.DiscordCommandPrefix,
+		adminUserID:      config.DiscordAdminUserID,
+		uploadsFolder:    config.StorageUploadDir,
+		cacheFolder:      config.StorageCacheDir,
+		repos:            repos,
+		settings:         settings.NewSettings(repos),
 	}
 }
 
@@ -40,7 +56,7 @@ func (d *Discord) Start(guildID string, commandPrefix string) {
 
 	d.GuildID = guildID
 	d.Session.AddHandler(d.Commands)
-	d.Player = player.NewPlayer(guildID, d.Session)
+	d.Player = player.NewPlayer(guildID, d.Session, history.NewHistory(d.repos), d.settings, cache.NewAutoCache(d.cacheFolder, guildID, d.repos))
 	d.prefix = commandPrefix
 }
 
@@ -82,6 +98,10 @@ func (d *Discord) Commands(s *discordgo.Session, m *discordgo.MessageCreate) {
 		{"cached", "cl"},
 		{"uploaded", "ul"},
 		{"now", "n"},
+		{"library", "lib"},
+		{"podcast", "pod"},
+		{"radio", "fm"},
+		{"jobs"},
 	}
 
 	canonical := getCanonicalCommand(command, aliases)
@@ -91,6 +111,14 @@ func (d *Discord) Commands(s *discordgo.Session, m *discordgo.MessageCreate) {
 
 	slog.Infof("Received command \"%v\" (canonical \"%v\"), parameter \"%v\"", command, canonical, param)
 
+	switch canonical {
+	case "play", "add", "skip", "stop", "pause", "resume":
+		if !d.hasDJPermission() {
+			d.sendMessageEmbed("Only members with the DJ role can control playback.")
+			return
+		}
+	}
+
 	switch canonical {
 	case "pause":
 		d.handlePauseCommand()
@@ -116,6 +144,14 @@ func (d *Discord) Commands(s *discordgo.Session, m *discordgo.MessageCreate) {
 		d.handleUploadListCommand(param)
 	case "now":
 		d.handleNowPlayngCommand()
+	case "library":
+		d.handleLibraryCommand(param)
+	case "radio":
+		d.handleRadioCommand(param)
+	case "podcast":
+		d.handlePodcastCommand(param)
+	case "jobs":
+		d.handleJobsCommand(param)
 	}
 }
 
@@ -157,6 +193,42 @@ func getCanonicalCommand(command string, commandAliases [][]string) string {
 	return ""
 }
 
+// hasDJPermission checks the message author against the guild's DJ role (if any is set).
+func (d *Discord) hasDJPermission() bool {
+	m := d.Message
+
+	if m.Author.ID == d.adminUserID {
+		return true
+	}
+
+	guildSettings, err := d.settings.Get(d.GuildID)
+	if err != nil {
+		slog.Errorf("Error getting guild settings: %v", err)
+		return false
+	}
+
+	if guildSettings.DJRoleID == "" {
+		return true
+	}
+
+	member := m.Member
+	if member == nil {
+		member, err = d.Session.State.Member(d.GuildID, m.Author.ID)
+		if err != nil {
+			slog.Errorf("Error getting guild member: %v", err)
+			return false
+		}
+	}
+
+	for _, roleID := range member.Roles {
+		if roleID == guildSettings.DJRoleID {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (d *Discord) findUserVoiceState(userID string, voiceStates []*discordgo.VoiceState) (*discordgo.VoiceState, bool) {
 	for _, vs := range voiceStates {
 		if vs.UserID == userID {
@@ -203,14 +275,17 @@ func (d *Discord) sendMessageEmbed(embedStr string) *discordgo.Message {
 }
 
 func (d *Discord) editMessageEmbed(embedStr string, messageID string) *discordgo.Message {
-	s := d.Session
-	m := d.Message
+	return d.editChannelMessageEmbed(embedStr, d.Message.Message.ChannelID, messageID)
+}
 
+// editChannelMessageEmbed edits a message outside of the handled command, such as
+// from background jobs, when d.Message may already belong to another command.
+func (d *Discord) editChannelMessageEmbed(embedStr, channelID, messageID string) *discordgo.Message {
 	embedBody := embed.NewEmbed().
 		SetDescription(embedStr).
 		SetColor(0x9f00d4).MessageEmbed
 
-	msg, err := s.ChannelMessageEditEmbed(m.Message.ChannelID, messageID, embedBody)
+	msg, err := d.Session.ChannelMessageEditEmbed(channelID, messageID, embedBody)
 	if err != nil {
 		slog.Error("Error sending 'stopped playback' message", err)
 	}
@@ -218,6 +293,11 @@ func (d *Discord) editMessageEmbed(embedStr string, messageID string) *discordgo
 	return msg
 }
 
+// newCache returns the cache of the guild in the configured folders.
+func (d *Discord) newCache() cache.ICache {
+	return cache.NewCache(d.uploadsFolder, d.cacheFolder, d.GuildID, d.repos)
+}
+
 func (d *Discord) SetCommandPrefix(prefix string) {
 	d.prefix = prefix
 }
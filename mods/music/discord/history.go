@@ -23,7 +23,7 @@ func (d *Discord) handleHistoryCommand(param string) {
 		sortBy, title = "duration", " — by total duration"
 	}
 
-	historyManager := history.NewHistory()
+	historyManager := history.NewHistory(d.repos)
 	historyList, err := historyManager.GetHistory(d.GuildID, sortBy)
 	if err != nil {
 		slog.Error("Error retrieving history", err)
@@ -0,0 +1,91 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/jobs"
+)
+
+// maxListedJobs keeps the job list within the embed size limit.
+const maxListedJobs = 10
+
+func (d *Discord) handleJobsCommand(param string) {
+	action, arg, _ := strings.Cut(param, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch action {
+	case "":
+		d.listJobs()
+	case "cancel":
+		d.cancelJob(arg)
+	default:
+		d.sendMessageEmbed(fmt.Sprintf("Usage: `%vjobs` or `%vjobs cancel [id]`", d.prefix, d.prefix))
+	}
+}
+
+func (d *Discord) listJobs() {
+	list, err := jobs.Shared(d.repos).List(d.GuildID, maxListedJobs)
+	if err != nil {
+		d.sendMessageEmbed(fmt.Sprintf("Error getting jobs\n\n*details:*\n`%v`", err))
+		return
+	}
+	if len(list) == 0 {
+		d.sendMessageEmbed("🗂 No jobs yet")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("🗂 Recent jobs\n\n")
+	for _, job := range list {
+		b.WriteString(jobs.Describe(job))
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\nUse `%vjobs cancel [id]` to stop a queued or running job", d.prefix)
+
+	d.sendMessageEmbed(b.String())
+}
+
+func (d *Discord) cancelJob(arg string) {
+	if d.adminUserID != d.Message.Author.ID {
+		d.sendMessageEmbed("Only admins can use this command")
+		return
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil {
+		d.sendMessageEmbed(fmt.Sprintf("Usage: `%vjobs cancel [id]`", d.prefix))
+		return
+	}
+
+	if err := jobs.Shared(d.repos).Cancel(d.GuildID, uint(id)); err != nil {
+		d.sendMessageEmbed(fmt.Sprintf("Error canceling job #%d\n\n*details:*\n`%v`", id, err))
+		return
+	}
+
+	d.sendMessageEmbed(fmt.Sprintf("⛔ Canceling job #%d", id))
+}
+
+// submitJob queues the job and keeps a message of the current channel updated
+// with its progress, replaced by the job result once done.
+func (d *Discord) submitJob(kind, input string, run jobs.RunFunc) {
+	msg := d.sendMessageEmbed("🕒 Queued...")
+	if msg == nil {
+		return
+	}
+	channelID := msg.ChannelID
+
+	notify := func(job db.Job) {
+		text := jobs.Describe(job)
+		if job.Status == jobs.StatusDone {
+			text = job.Result
+		}
+		d.editChannelMessageEmbed(text, channelID, msg.ID)
+	}
+
+	if _, err := jobs.Shared(d.repos).Submit(d.GuildID, kind, input, run, notify); err != nil {
+		d.editChannelMessageEmbed(fmt.Sprintf("Error starting job\n\n*details:*\n`%v`", err), channelID, msg.ID)
+	}
+}
@@ -0,0 +1,41 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/keshon/melodix-player/mods/music/sources"
+)
+
+func (d *Discord) handleLibraryCommand(param string) {
+	if d.adminUserID != d.Message.Author.ID {
+		d.sendMessageEmbed("Only admins can use this command")
+		return
+	}
+
+	lib := sources.NewLibrary(d.repos)
+	if lib == nil {
+		d.sendMessageEmbed("Music library is not configured, set `library.dir` (`LIBRARY_DIR`) first")
+		return
+	}
+
+	switch param {
+	case "":
+		count, err := lib.Count()
+		if err != nil {
+			d.sendMessageEmbed(err.Error())
+			return
+		}
+		d.sendMessageEmbed(fmt.Sprintf("📚 Music library\n\n**Indexed tracks:** %d\n\nUse `%vlibrary scan` to rescan the library directory", count, d.prefix))
+	case "scan":
+		msg := d.sendMessageEmbed("⏳ Scanning music library...")
+		result, err := lib.Scan(context.Background())
+		if err != nil {
+			d.editMessageEmbed(err.Error(), msg.ID)
+			return
+		}
+		d.editMessageEmbed(fmt.Sprintf("📚 Music library is scanned\n\n**Added:** %d\n**Updated:** %d\n**Removed:** %d\n**Failed:** %d", result.Added, result.Updated, result.Removed, result.Failed), msg.ID)
+	default:
+		d.sendMessageEmbed(fmt.Sprintf("Unknown parameter, use `%vlibrary` or `%vlibrary scan`", d.prefix, d.prefix))
+	}
+}
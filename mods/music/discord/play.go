@@ -1,22 +1,19 @@
 package discord
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"net/url"
-	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
 	embed "github.com/Clinet/discordgo-embed"
 	"github.com/bwmarrin/discordgo"
 	"github.com/gookit/slog"
-	"github.com/keshon/melodix-player/internal/db"
-	"github.com/keshon/melodix-player/mods/music/history"
+	"github.com/keshon/melodix-player/internal/settings"
 	"github.com/keshon/melodix-player/mods/music/media"
 	"github.com/keshon/melodix-player/mods/music/player"
+	"github.com/keshon/melodix-player/mods/music/playlist"
 	"github.com/keshon/melodix-player/mods/music/sources"
 	"github.com/keshon/melodix-player/mods/music/utils"
 )
@@ -25,7 +22,18 @@ func (d *Discord) handlePlayCommand(param string, enqueueOnly bool) {
 	s := d.Session
 	m := d.Message
 
-	if param == "" {
+	// Playlist files attached to the message (or the one it replies to) are
+	// queued along with the query, other attachments are played as audio files
+	var audioAttachments []*discordgo.MessageAttachment
+	for _, attachment := range d.messageAttachments() {
+		if playlist.IsPlaylistFile(attachment.Filename) {
+			param = strings.TrimSpace(param + " " + attachment.URL)
+		} else {
+			audioAttachments = append(audioAttachments, attachment)
+		}
+	}
+
+	if param == "" && len(audioAttachments) == 0 {
 		return
 	}
 
@@ -39,22 +47,6 @@ func (d *Discord) handlePlayCommand(param string, enqueueOnly bool) {
 		slog.Error("Error sending 'please wait' message: %v", err)
 	}
 
-	originType, origins := splitParamsToOriginsAndType(param)
-
-	if len(origins) <= 0 {
-		embedStr = "No songs or streams were found by your query."
-		embedMsg = embed.NewEmbed().
-			SetColor(0x9f00d4).
-			SetDescription(embedStr).
-			SetColor(0x9f00d4).MessageEmbed
-
-		_, err := s.ChannelMessageEditEmbed(m.Message.ChannelID, pleaseWaitMessage.ID, embedMsg)
-		if err != nil {
-			slog.Error("Error sending 'please wait' message: %v", err)
-		}
-		return
-	}
-
 	channel, err := s.State.Channel(m.Message.ChannelID)
 	if err != nil {
 		slog.Error("Error getting channel: %v", err)
@@ -78,172 +70,122 @@ func (d *Discord) handlePlayCommand(param string, enqueueOnly bool) {
 		return
 	}
 
-	songs, err := getSongsFromSources(originType, origins, m.GuildID)
+	songs, attachmentNotices := d.songsFromAttachments(audioAttachments)
+	if len(attachmentNotices) > 0 {
+		d.sendMessageEmbed(strings.Join(attachmentNotices, "\n"))
+	}
+
+	if param != "" {
+		registry := sources.NewRegistry(sources.ProviderOptions{GuildID: m.GuildID, Repos: d.repos})
+		resolved, err := registry.Resolve(context.Background(), param)
+		if err != nil {
+			embedStr = fmt.Sprintf("%v\n\n*details:*\n`%v`", "Error forming playlist", err)
+			embedMsg = embed.NewEmbed().
+				SetColor(0x9f00d4).
+				SetDescription(embedStr).
+				SetColor(0x9f00d4).MessageEmbed
+			_, err := s.ChannelMessageEditEmbed(m.Message.ChannelID, pleaseWaitMessage.ID, embedMsg)
+			if err != nil {
+				slog.Error("Error sending 'please wait' message: %v", err)
+			}
+			return
+		}
+		songs = append(songs, resolved...)
+	}
+
+	if len(songs) == 0 {
+		s.ChannelMessageDelete(m.Message.ChannelID, pleaseWaitMessage.ID)
+		return
+	}
+
+	d.queueSongs(songs, enqueueOnly, pleaseWaitMessage.ID)
+}
+
+// queueSongs applies the guild queue settings and plays or enqueues the songs,
+// reporting the outcome in the given message.
+func (d *Discord) queueSongs(songs []*media.Song, enqueueOnly bool, messageID string) {
+	s := d.Session
+	m := d.Message
+
+	songs, notice, err := d.applyQueueSettings(songs)
 	if err != nil {
-		embedStr = fmt.Sprintf("%v\n\n*details:*\n`%v`", "Error forming playlist", err)
-		embedMsg = embed.NewEmbed().
+		embedMsg := embed.NewEmbed().
 			SetColor(0x9f00d4).
-			SetDescription(embedStr).
+			SetDescription(err.Error()).
 			SetColor(0x9f00d4).MessageEmbed
-		_, err := s.ChannelMessageEditEmbed(m.Message.ChannelID, pleaseWaitMessage.ID, embedMsg)
+		_, err := s.ChannelMessageEditEmbed(m.Message.ChannelID, messageID, embedMsg)
 		if err != nil {
 			slog.Error("Error sending 'please wait' message: %v", err)
 		}
 		return
 	}
+	if notice != "" {
+		d.sendMessageEmbed(notice)
+	}
 
 	// Enqueue playlist to the player
 	if d.Player.GetCurrentSong() != nil {
 		enqueueOnly = true
 	}
-	err = playOrEnqueue(d, songs, s, m, enqueueOnly, pleaseWaitMessage.ID)
+	err = playOrEnqueue(d, songs, s, m, enqueueOnly, messageID)
 	if err != nil {
 		slog.Error(err)
 
-		embedStr = fmt.Sprintf("%v\n\n*details:*\n`%v`", "Error enqueuing/playing playlist", err)
-		embedMsg = embed.NewEmbed().
+		embedStr := fmt.Sprintf("%v\n\n*details:*\n`%v`", "Error enqueuing/playing playlist", err)
+		embedMsg := embed.NewEmbed().
 			SetColor(0x9f00d4).
 			SetDescription(embedStr).
 			SetColor(0x9f00d4).MessageEmbed
 
-		s.ChannelMessageEditEmbed(m.Message.ChannelID, pleaseWaitMessage.ID, embedMsg)
+		s.ChannelMessageEditEmbed(m.Message.ChannelID, messageID, embedMsg)
 		return
 	}
 }
 
-func getSongsFromSources(originType string, songsOrigins []string, guildID string) ([]*media.Song, error) {
-	var songsList []*media.Song
-	var allErrors []error // Slice to store all encountered errors
-
-	youtube := sources.NewYoutube()
-	stream := sources.NewStream()
-
-	slog.Info("Getting songs list and their type:")
-	for _, songOrigin := range songsOrigins {
-		slog.Info(" - ", songOrigin, originType)
+// applyQueueSettings drops songs from sources the guild does not allow and
+// trims the list to the free space left in the queue.
+func (d *Discord) applyQueueSettings(songs []*media.Song) ([]*media.Song, string, error) {
+	guildSettings, err := d.settings.Get(d.GuildID)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error getting guild settings\n\n*details:*\n`%v`", err)
 	}
 
-	for _, songOrigin := range songsOrigins {
-
-		var songs []*media.Song
-		var err error
-
-		switch originType {
-		case "local_file":
-			slog.Info("Local file: ", songOrigin)
-
-			songPath := filepath.Join("cache", guildID, songOrigin)
-
-			if _, err := os.Stat(songPath); os.IsNotExist(err) {
-				allErrors = append(allErrors, fmt.Errorf("no such file or directory: %v", err))
-				continue
-			}
-
-			var song *media.Song
-			existingTrack, err := db.GetTrackByFilepath(songPath)
-			if err == nil {
-				song = &media.Song{
-					SongID:   existingTrack.SongID,
-					Title:    existingTrack.Title,
-					URL:      existingTrack.URL,
-					Filepath: existingTrack.Filepath,
-				}
-			} else {
-				song = &media.Song{
-					Title:    songOrigin,
-					Filepath: songPath,
-				}
-			}
-
-			song.Source = media.SourceLocalFile
-
-			songs = append(songs, song)
-		case "history_id":
-			slog.Info("History ID: ", songOrigin)
-
-			id, err := strconv.Atoi(songOrigin)
-			if err != nil {
-				allErrors = append(allErrors, fmt.Errorf("cannot convert string id to int id: %v", err))
-				continue
-			}
-			h := history.NewHistory()
-			track, err := h.GetTrackFromHistory(guildID, uint(id))
-			if err != nil {
-				slog.Error("Error getting track from history: %v", err)
-				allErrors = append(allErrors, fmt.Errorf("error getting track from history with ID %v: %v", id, err))
-				continue
-			}
-
-			var song []*media.Song
-
-			switch track.Source {
-			case "YouTube":
-				slog.Info("Track is from YouTube")
-				song, err = youtube.FetchManyByURL(track.URL)
-				if err != nil {
-					slog.Error("Error fetching song from youtube URL: %v", err)
-					allErrors = append(allErrors, fmt.Errorf("%v", err))
-				}
-			case "Stream":
-				slog.Info("Track is from Stream")
-				song, err = stream.FetchManyByManyURLs([]string{track.URL})
-				if err != nil {
-					slog.Error("Error fetching stream from URL: %v", err)
-					allErrors = append(allErrors, fmt.Errorf("%v", err))
-				}
-			case "LocalFile":
-				slog.Info("Track is from LocalFile")
-				song = []*media.Song{{
-					SongID:   track.SongID,
-					Title:    track.Title,
-					URL:      track.URL,
-					Filepath: track.Filepath,
-					Source:   media.SourceLocalFile,
-				}}
-			}
-
-			if allErrors != nil {
-				allErrors = append(allErrors, fmt.Errorf("%v", err))
-				continue
-			}
+	var notices []string
 
-			songs = append(songs, song...)
-		case "youtube_title":
-			slog.Info("Youtube title: ", songOrigin)
-			songs, err = youtube.FetchManyByTitle(songOrigin)
-			if err != nil {
-				slog.Error("Error fetching song by title from youtube URL: %v", err)
-				allErrors = append(allErrors, fmt.Errorf("%v", err))
-			}
-		case "youtube_url":
-			slog.Info("Youtube URL: ", songOrigin)
-			songs, err = youtube.FetchManyByManyURLs([]string{songOrigin})
-			if err != nil {
-				slog.Error("Error fetching song by URL from youtube URL: %v", err)
-				allErrors = append(allErrors, fmt.Errorf("%v", err))
-			}
-		case "stream_url":
-			slog.Info("Stream URL: ", songOrigin)
-			songs, err = stream.FetchManyByManyURLs([]string{songOrigin})
-			if err != nil {
-				slog.Error("Error fetching stream from URL: %v", err)
-				allErrors = append(allErrors, fmt.Errorf("%v", err))
-			}
+	var allowed []*media.Song
+	for _, song := range songs {
+		if guildSettings.IsSourceAllowed(settingsSources[song.Source]) {
+			allowed = append(allowed, song)
 		}
-
-		songsList = append(songsList, songs...)
 	}
-
-	if len(songsList) == 0 {
-		return nil, fmt.Errorf("%v", allErrors)
+	if len(allowed) == 0 {
+		return nil, "", fmt.Errorf("Tracks from this source are not allowed in this guild.\nAllowed sources: `%v`", strings.Join(guildSettings.AllowedSources, ", "))
+	}
+	if skipped := len(songs) - len(allowed); skipped > 0 {
+		notices = append(notices, fmt.Sprintf("%v track(s) skipped: source not allowed in this guild.", skipped))
 	}
 
-	slog.Info("Up-to-date songs list now is:")
-	for _, song := range songsList {
-		slog.Info(" - ", song.Title, song.Source)
+	if guildSettings.MaxQueueLength > 0 {
+		free := guildSettings.MaxQueueLength - len(d.Player.GetSongQueue())
+		if free <= 0 {
+			return nil, "", fmt.Errorf("The queue is full (limit is %v tracks).", guildSettings.MaxQueueLength)
+		}
+		if len(allowed) > free {
+			notices = append(notices, fmt.Sprintf("%v track(s) skipped: queue is limited to %v tracks.", len(allowed)-free, guildSettings.MaxQueueLength))
+			allowed = allowed[:free]
+		}
 	}
 
-	return songsList, nil
+	return allowed, strings.Join(notices, "\n"), nil
+}
+
+// settingsSources maps every song source to its allowed_sources name. Radio,
+// podcast, Subsonic and manifest songs are all streams, so allowing stream
+// allows them all, and library songs are local files.
+var settingsSources = map[media.SongSource]string{
+	media.SourceYouTube:   settings.SourceYouTube,
+	media.SourceStream:    settings.SourceStream,
+	media.SourceLocalFile: settings.SourceLocalFile,
 }
 
 func playOrEnqueue(d *Discord, playlist []*media.Song, s *discordgo.Session, m *discordgo.MessageCreate, enqueueOnly bool, prevMessageID string) (err error) {
@@ -397,72 +339,3 @@ func showStatusMessage(d *Discord, s *discordgo.Session, channelID, prevMessageI
 	embedMsg.SetDescription(content)
 	s.ChannelMessageEditEmbed(channelID, prevMessageID, embedMsg.MessageEmbed)
 }
-
-func splitParamsToOriginsAndType(param string) (string, []string) {
-	param = strings.TrimSpace(param)
-
-	if len(param) == 0 {
-		return "", []string{}
-	}
-
-	originType := ""
-
-	// If has youtube keywords (assuming it's a link)
-	if strings.Contains(param, "youtube") || strings.Contains(param, "youtu.be") {
-		slog.Info("Possbile youtube urls: ", param)
-		urlsSlice := strings.Fields(param)
-		ytUrls := []string{}
-		for _, url := range urlsSlice {
-			if utils.IsYouTubeURL(url) {
-				ytUrls = append(ytUrls, url)
-				originType = "youtube_url"
-			}
-		}
-		return originType, ytUrls
-	} else {
-		// Any non youtube link
-		if strings.HasPrefix(param, "https") || strings.HasPrefix(param, "http") {
-			slog.Info("Possible stream urls: ", param)
-			urlsSlice := strings.Fields(param)
-			streamUrls := []string{}
-			for _, url := range urlsSlice {
-				if utils.IsValidHttpURL(url) {
-					streamUrls = append(streamUrls, url)
-					originType = "stream_url"
-				}
-			}
-			return originType, streamUrls
-		} else {
-			if strings.Contains(param, ".mp3") {
-				slog.Info("Possible files: ", param)
-				// Check if the parameter contains numeric IDs
-				filesSlice := strings.Fields(param)
-				files := []string{}
-				for _, file := range filesSlice {
-					if utils.IsAudioFile(file) {
-						files = append(files, file)
-						originType = "local_file"
-					}
-				}
-				return originType, files
-			} else {
-				slog.Info("Possible history ids: ", param)
-				allValidIDs := true
-				for _, id := range strings.Fields(param) {
-					if _, err := strconv.Atoi(id); err != nil {
-						allValidIDs = false
-						break
-					}
-				}
-				if allValidIDs {
-					return "history_id", strings.Fields(param)
-				}
-
-				// Threat as normal text (song title on youtube)
-				// If none of the above conditions are met, treat it as a YouTube title
-				encodedTitle := url.QueryEscape(param)
-				return "youtube_title", []string{encodedTitle}
-			}
-		}
-	}
-}
@@ -0,0 +1,18 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/keshon/melodix-player/internal/settings"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+func TestSettingsSources(t *testing.T) {
+	all := &settings.GuildSettings{AllowedSources: []string{settings.SourceYouTube, settings.SourceStream, settings.SourceLocalFile}}
+
+	for _, source := range media.Sources {
+		if name := settingsSources[source]; !all.IsSourceAllowed(name) {
+			t.Errorf("source %v has no allowed_sources name, got %q", source, name)
+		}
+	}
+}
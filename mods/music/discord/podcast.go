@@ -0,0 +1,137 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/keshon/melodix-player/mods/music/podcast"
+	"github.com/keshon/melodix-player/mods/music/sources"
+	"github.com/keshon/melodix-player/mods/music/utils"
+)
+
+// maxListedEpisodes keeps episode lists within the embed size limit.
+const maxListedEpisodes = 10
+
+func (d *Discord) handlePodcastCommand(param string) {
+	action, arg, _ := strings.Cut(param, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch {
+	case param == "":
+		d.listPodcasts()
+	case action == "check":
+		d.checkPodcasts()
+	case action == "remove":
+		d.removePodcast(arg)
+	default:
+		d.showPodcast(param)
+	}
+}
+
+func (d *Discord) listPodcasts() {
+	feeds, err := d.repos.Podcasts.GetAll(d.GuildID)
+	if err != nil {
+		d.sendMessageEmbed(err.Error())
+		return
+	}
+
+	if len(feeds) == 0 {
+		d.sendMessageEmbed(fmt.Sprintf("🎙️ No podcasts yet\n\nUse `%vpodcast [feed url]` to list the episodes of a feed", d.prefix))
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("🎙️ Podcasts\n\n")
+	for i, feed := range feeds {
+		fmt.Fprintf(&b, "%d. [%v](%v)\n", i+1, feed.Title, feed.URL)
+	}
+	fmt.Fprintf(&b, "\nUse `%vpodcast check` to see new episodes", d.prefix)
+
+	d.sendMessageEmbed(b.String())
+}
+
+func (d *Discord) showPodcast(feedURL string) {
+	msg := d.sendMessageEmbed("⏳ Fetching podcast feed...")
+
+	feed, err := sources.FetchPodcast(context.Background(), d.GuildID, d.repos, feedURL)
+	if err != nil {
+		d.editMessageEmbed(fmt.Sprintf("Error fetching podcast feed\n\n*details:*\n`%v`", err), msg.ID)
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🎙️ %v\n\n", feed.Title)
+	writeEpisodes(&b, feed.Episodes)
+	fmt.Fprintf(&b, "\nUse `%vplay %v [number]` or `%vplay %v latest` to play an episode", d.prefix, feedURL, d.prefix, feedURL)
+
+	d.editMessageEmbed(b.String(), msg.ID)
+}
+
+func (d *Discord) checkPodcasts() {
+	msg := d.sendMessageEmbed("⏳ Checking podcasts for new episodes...")
+
+	updates, err := sources.CheckPodcasts(context.Background(), d.GuildID, d.repos)
+	if err != nil {
+		d.editMessageEmbed(err.Error(), msg.ID)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("🎙️ New episodes\n")
+	var found bool
+	for _, update := range updates {
+		switch {
+		case update.Err != nil:
+			fmt.Fprintf(&b, "\n**%v**\n`%v`\n", update.Feed.Title, update.Err)
+		case len(update.Episodes) > 0:
+			found = true
+			fmt.Fprintf(&b, "\n**%v**\n", update.Feed.Title)
+			writeEpisodes(&b, update.Episodes)
+		}
+	}
+
+	if !found {
+		b.WriteString("\nNo new episodes since the last check\n")
+	}
+
+	d.editMessageEmbed(b.String(), msg.ID)
+}
+
+func (d *Discord) removePodcast(feedURL string) {
+	if !d.hasDJPermission() {
+		d.sendMessageEmbed("Only members with the DJ role can remove podcasts.")
+		return
+	}
+
+	if _, err := d.repos.Podcasts.Get(d.GuildID, feedURL); err != nil {
+		d.sendMessageEmbed(fmt.Sprintf("Podcast `%v` is not in the list", feedURL))
+		return
+	}
+
+	if err := d.repos.Podcasts.Delete(d.GuildID, feedURL); err != nil {
+		d.sendMessageEmbed(err.Error())
+		return
+	}
+
+	d.sendMessageEmbed(fmt.Sprintf("🎙️ Podcast `%v` is removed", feedURL))
+}
+
+// writeEpisodes lists episodes numbered the way `play <feed> [number]` expects them.
+func writeEpisodes(b *strings.Builder, episodes []podcast.Episode) {
+	for i, episode := range episodes {
+		if i == maxListedEpisodes {
+			fmt.Fprintf(b, "...and %d more\n", len(episodes)-i)
+			break
+		}
+
+		fmt.Fprintf(b, "%d. %v", i+1, episode.Title)
+		if episode.Duration > 0 {
+			fmt.Fprintf(b, " `%v`", utils.FormatDurationHHMMSS(episode.Duration.Seconds()))
+		}
+		if !episode.Published.IsZero() {
+			fmt.Fprintf(b, " — %v", episode.Published.Format("2006-01-02"))
+		}
+		b.WriteString("\n")
+	}
+}
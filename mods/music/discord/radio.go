@@ -0,0 +1,111 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/mods/music/radio"
+	"github.com/keshon/melodix-player/mods/music/sources"
+)
+
+// maxListedStations keeps search results within the embed size limit.
+const maxListedStations = 10
+
+func (d *Discord) handleRadioCommand(param string) {
+	action, arg, _ := strings.Cut(param, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch action {
+	case "search":
+		d.searchRadio(arg)
+	case "play":
+		d.playRadio(arg, false)
+	case "add":
+		d.playRadio(arg, true)
+	default:
+		d.sendMessageEmbed(fmt.Sprintf("📻 Use `%vradio search [name|tag|country]` to find stations and `%vradio play [number]` to play one", d.prefix, d.prefix))
+	}
+}
+
+func (d *Discord) searchRadio(term string) {
+	if term == "" {
+		d.sendMessageEmbed(fmt.Sprintf("Usage: `%vradio search [name|tag|country]`", d.prefix))
+		return
+	}
+
+	conf, err := config.NewConfig()
+	if err != nil {
+		d.sendMessageEmbed(fmt.Sprintf("Error loading config: %v", err))
+		return
+	}
+
+	msg := d.sendMessageEmbed("⏳ Searching radio stations...")
+
+	stations, err := radio.NewClient(conf.RadioDirectoryURL, conf.DcaUserAgent).Search(context.Background(), term, maxListedStations)
+	if err != nil {
+		d.editMessageEmbed(fmt.Sprintf("Error searching radio stations\n\n*details:*\n`%v`", err), msg.ID)
+		return
+	}
+	if len(stations) == 0 {
+		d.editMessageEmbed(fmt.Sprintf("📻 No stations found for `%v`", term), msg.ID)
+		return
+	}
+
+	d.radioStations = stations
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📻 Stations for `%v`\n\n", term)
+	for i, station := range stations {
+		fmt.Fprintf(&b, "%d. %v", i+1, station.Name)
+
+		var details []string
+		if station.Country != "" {
+			details = append(details, station.Country)
+		}
+		if station.Codec != "" && station.Bitrate > 0 {
+			details = append(details, fmt.Sprintf("%v %vkbps", station.Codec, station.Bitrate))
+		}
+		if len(details) > 0 {
+			fmt.Fprintf(&b, " `%v`", strings.Join(details, ", "))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\nUse `%vradio play [number]` to play a station", d.prefix)
+
+	d.editMessageEmbed(b.String(), msg.ID)
+}
+
+func (d *Discord) playRadio(arg string, enqueueOnly bool) {
+	if !d.hasDJPermission() {
+		d.sendMessageEmbed("Only members with the DJ role can control playback.")
+		return
+	}
+
+	if len(d.radioStations) == 0 {
+		d.sendMessageEmbed(fmt.Sprintf("Search for stations first with `%vradio search [name|tag|country]`", d.prefix))
+		return
+	}
+
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(d.radioStations) {
+		d.sendMessageEmbed(fmt.Sprintf("Pick a station number between 1 and %v", len(d.radioStations)))
+		return
+	}
+	station := d.radioStations[n-1]
+
+	msg := d.sendMessageEmbed(fmt.Sprintf("⏳ Tuning in to %v...", station.Name))
+
+	songs, err := sources.NewStream().FetchNamed(station.StreamURL(), station.Name)
+	if err != nil {
+		d.editMessageEmbed(fmt.Sprintf("Error playing %v\n\n*details:*\n`%v`", station.Name, err), msg.ID)
+		return
+	}
+	for _, song := range songs {
+		song.Thumbnail.URL = station.Favicon
+	}
+
+	d.queueSongs(songs, enqueueOnly, msg.ID)
+}
@@ -1,11 +1,12 @@
 package discord
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/gookit/slog"
 	"github.com/keshon/melodix-player/internal/config"
-	"github.com/keshon/melodix-player/mods/music/cache"
+	"github.com/keshon/melodix-player/mods/music/jobs"
 )
 
 func (d *Discord) handleUploadListCommand(param string) {
@@ -19,9 +20,7 @@ func (d *Discord) handleUploadListCommand(param string) {
 		return
 	}
 
-	guildID := d.GuildID
-
-	c := cache.NewCache("./upload", "./cache", guildID)
+	c := d.newCache()
 
 	if param == "" {
 
@@ -42,22 +41,23 @@ func (d *Discord) handleUploadListCommand(param string) {
 	}
 
 	if param == "extract" {
-		msg := d.sendMessageEmbed("⏳ Starting to extract audio...")
-		stats, err := c.ExtractAudioFromVideo()
-		if err != nil {
-			d.editMessageEmbed(err.Error(), msg.ID)
-		} else {
+		prefix := d.prefix
+		run := func(ctx context.Context, report jobs.Report) (string, error) {
+			stats, err := c.ExtractAudioFromVideo(ctx, report)
+			if err != nil {
+				return "", err
+			}
 			if stats == nil {
-				d.editMessageEmbed("❗️ Nothing to extract", msg.ID)
-				return
+				return "❗️ Nothing to extract", nil
 			}
-			statsStr := "💽 Extracted audio added to cache\n\nUse `" + d.prefix + "cached` command to see available files\n\n"
+			statsStr := "💽 Extracted audio added to cache\n\nUse `" + prefix + "cached` command to see available files\n\n"
 			slog.Info(stats)
 			for key, stat := range stats {
 				statsStr += fmt.Sprintf("` %v ` %s\n", key+1, stat)
 			}
-			d.editMessageEmbed(statsStr, msg.ID)
+			return statsStr, nil
 		}
+		d.submitJob(jobs.KindExtract, d.uploadsFolder, run)
 
 	} else {
 		d.sendMessageEmbed("Invalid parameter. Usage: /uploaded extract")
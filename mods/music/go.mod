@@ -39,6 +39,7 @@ require (
 require (
 	github.com/PuerkitoBio/goquery v1.9.2
 	github.com/bwmarrin/discordgo v0.27.1
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/gonum/stat v0.0.0-20181125101827-41a0da705a5b
 	github.com/gorilla/websocket v1.5.1 // indirect
 	github.com/kkdai/youtube/v2 v2.10.1
@@ -22,7 +22,10 @@ type Thumbnail struct {
 	Height uint
 }
 
-type History struct{}
+type History struct {
+	tracks    db.TrackRepository
+	histories db.HistoryRepository
+}
 
 type HistoryTrackInfo struct {
 	History db.History
@@ -38,14 +41,17 @@ type IHistory interface {
 	GetTrackFromHistory(guildID string, trackID uint) (db.Track, error)
 }
 
-func NewHistory() IHistory {
-	return &History{}
+func NewHistory(repos *db.Repositories) IHistory {
+	return &History{
+		tracks:    repos.Tracks,
+		histories: repos.Histories,
+	}
 }
 
 func (h *History) AddTrackToHistory(guildID string, song *Song) error {
 	var track *db.Track
 
-	existingTrack, err := db.GetTrackBySongID(song.SongID)
+	existingTrack, err := h.tracks.GetBySongID(song.SongID)
 	if err != nil {
 		newTrack := &db.Track{
 			SongID:   song.SongID,
@@ -55,11 +61,11 @@ func (h *History) AddTrackToHistory(guildID string, song *Song) error {
 			Filepath: song.Filepath,
 		}
 
-		if err := db.CreateTrack(newTrack); err != nil {
+		if err := h.tracks.Create(newTrack); err != nil {
 			return err
 		}
 
-		existingTrack, _ = db.GetTrackBySongID(song.SongID)
+		existingTrack, _ = h.tracks.GetBySongID(song.SongID)
 	}
 
 	if existingTrack == nil {
@@ -75,7 +81,7 @@ func (h *History) AddTrackToHistory(guildID string, song *Song) error {
 		track = existingTrack
 	}
 
-	exists, err := db.DoesHistoryExistForGuild(track.ID, guildID)
+	exists, err := h.histories.ExistsForGuild(track.ID, guildID)
 	if err != nil {
 		return err
 	}
@@ -85,7 +91,7 @@ func (h *History) AddTrackToHistory(guildID string, song *Song) error {
 			GuildID: guildID,
 			TrackID: track.ID,
 		}
-		return db.CreateHistory(&history)
+		return h.histories.Create(&history)
 	}
 
 	return nil
@@ -94,12 +100,12 @@ func (h *History) AddTrackToHistory(guildID string, song *Song) error {
 // AddPlaybackStats updates all playback statistics (duration and count) for a track.
 func (h *History) AddPlaybackAllStats(guildID, songID string, duration float64) error {
 
-	existingTrackRecord, err := db.GetTrackBySongID(songID)
+	existingTrackRecord, err := h.tracks.GetBySongID(songID)
 	if err != nil {
 		return err
 	}
 
-	existingHistoryRecord, err := db.GetHistoryByTrackIDAndGuildID(existingTrackRecord.ID, guildID)
+	existingHistoryRecord, err := h.histories.GetByTrackIDAndGuildID(existingTrackRecord.ID, guildID)
 	if err != nil {
 		return err
 	}
@@ -107,18 +113,18 @@ func (h *History) AddPlaybackAllStats(guildID, songID string, duration float64)
 	newPlayCount := existingHistoryRecord.PlayCount + 1
 	newDuration := existingHistoryRecord.Duration + duration
 
-	return db.UpdateTrackStatsForGuild(existingTrackRecord.ID, guildID, newPlayCount, newDuration)
+	return h.histories.UpdateStatsForGuild(existingTrackRecord.ID, guildID, newPlayCount, newDuration)
 }
 
 // AddPlaybackCountStats updates playback count statistics for a track.
 func (h *History) AddPlaybackCountStats(guildID, songID string) error {
 
-	existingTrackRecord, err := db.GetTrackBySongID(songID)
+	existingTrackRecord, err := h.tracks.GetBySongID(songID)
 	if err != nil {
 		return err
 	}
 
-	existingHistoryRecord, err := db.GetHistoryByTrackIDAndGuildID(existingTrackRecord.ID, guildID)
+	existingHistoryRecord, err := h.histories.GetByTrackIDAndGuildID(existingTrackRecord.ID, guildID)
 	if err != nil {
 		return err
 	}
@@ -126,18 +132,18 @@ func (h *History) AddPlaybackCountStats(guildID, songID string) error {
 	newPlayCount := existingHistoryRecord.PlayCount + 1
 	newDuration := existingHistoryRecord.Duration
 
-	return db.UpdateTrackStatsForGuild(existingTrackRecord.ID, guildID, newPlayCount, newDuration)
+	return h.histories.UpdateStatsForGuild(existingTrackRecord.ID, guildID, newPlayCount, newDuration)
 }
 
 // AddPlaybackDurationStats updates playback duration statistics for a track.
 func (h *History) AddPlaybackDurationStats(guildID, songID string, duration float64) error {
 
-	existingTrackRecord, err := db.GetTrackBySongID(songID)
+	existingTrackRecord, err := h.tracks.GetBySongID(songID)
 	if err != nil {
 		return err
 	}
 
-	existingHistoryRecord, err := db.GetHistoryByTrackIDAndGuildID(existingTrackRecord.ID, guildID)
+	existingHistoryRecord, err := h.histories.GetByTrackIDAndGuildID(existingTrackRecord.ID, guildID)
 	if err != nil {
 		return err
 	}
@@ -145,7 +151,7 @@ func (h *History) AddPlaybackDurationStats(guildID, songID string, duration floa
 	newPlayCount := existingHistoryRecord.PlayCount
 	newDuration := existingHistoryRecord.Duration + duration
 
-	return db.UpdateTrackStatsForGuild(existingTrackRecord.ID, guildID, newPlayCount, newDuration)
+	return h.histories.UpdateStatsForGuild(existingTrackRecord.ID, guildID, newPlayCount, newDuration)
 }
 
 // GetHistory retrieves the play history for a guild, sorted by the specified criteria.
@@ -154,12 +160,12 @@ func (h *History) GetHistory(guildID string, sortBy string) ([]HistoryTrackInfo,
 	var err error
 
 	if guildID == "" {
-		historyEntries, err = db.GetAllHistorySortedBy(sortBy)
+		historyEntries, err = h.histories.GetAllSortedBy(sortBy)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		historyEntries, err = db.GetGuildHistorySortedBy(guildID, sortBy)
+		historyEntries, err = h.histories.GetGuildSortedBy(guildID, sortBy)
 		if err != nil {
 			return nil, err
 		}
@@ -169,7 +175,7 @@ func (h *History) GetHistory(guildID string, sortBy string) ([]HistoryTrackInfo,
 
 	for _, historyEntry := range historyEntries {
 
-		track, err := db.GetTrackByID(historyEntry.TrackID)
+		track, err := h.tracks.GetByID(historyEntry.TrackID)
 		if err != nil {
 			return nil, err
 		}
@@ -187,13 +193,13 @@ func (h *History) GetHistory(guildID string, sortBy string) ([]HistoryTrackInfo,
 
 // GetTrackFromHistory retrieves a track from the play history based on its ID and guild.
 func (h *History) GetTrackFromHistory(guildID string, trackID uint) (db.Track, error) {
-	exists, err := db.DoesHistoryExistForGuild(trackID, guildID)
+	exists, err := h.histories.ExistsForGuild(trackID, guildID)
 	if err != nil {
 		return db.Track{}, err
 	}
 
 	if exists {
-		track, err := db.GetTrackByID(trackID)
+		track, err := h.tracks.GetByID(trackID)
 		if err != nil {
 			return db.Track{}, err
 		}
@@ -0,0 +1,84 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/keshon/melodix-player/internal/db"
+)
+
+func TestAddTrackToHistory(t *testing.T) {
+	repos := db.NewMemoryRepositories()
+	h := NewHistory(repos)
+
+	song := &Song{Title: "Song", URL: "https://www.youtube.com/watch?v=abc", SongID: "abc", Source: "YouTube"}
+
+	if err := h.AddTrackToHistory("guild", song); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddTrackToHistory("guild", song); err != nil {
+		t.Fatal(err)
+	}
+
+	tracks, _ := repos.Tracks.GetAll()
+	if len(tracks) != 1 {
+		t.Fatalf("expected 1 track, got %d", len(tracks))
+	}
+
+	entries, err := h.GetHistory("guild", "last_played")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Track.SongID != "abc" {
+		t.Fatalf("unexpected history entries: %+v", entries)
+	}
+}
+
+func TestAddPlaybackStats(t *testing.T) {
+	repos := db.NewMemoryRepositories()
+	h := NewHistory(repos)
+
+	if err := h.AddTrackToHistory("guild", &Song{Title: "Song", SongID: "abc", Source: "YouTube"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.AddPlaybackCountStats("guild", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddPlaybackDurationStats("guild", "abc", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddPlaybackAllStats("guild", "abc", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := h.GetHistory("guild", "play_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].History.PlayCount != 2 || entries[0].History.Duration != 5 {
+		t.Errorf("unexpected stats: %+v", entries[0].History)
+	}
+
+	if err := h.AddPlaybackCountStats("guild", "missing"); err == nil {
+		t.Error("expected error for unknown song")
+	}
+}
+
+func TestGetTrackFromHistory(t *testing.T) {
+	repos := db.NewMemoryRepositories()
+	h := NewHistory(repos)
+
+	if err := h.AddTrackToHistory("guild", &Song{Title: "Song", SongID: "abc", Source: "YouTube"}); err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := h.GetTrackFromHistory("guild", 1)
+	if err != nil || track.SongID != "abc" {
+		t.Fatalf("unexpected track %+v (err %v)", track, err)
+	}
+
+	track, _ = h.GetTrackFromHistory("other", 1)
+	if track.ID != 0 {
+		t.Errorf("expected no track for another guild, got %+v", track)
+	}
+}
@@ -0,0 +1,144 @@
+// Package icy reads ICY (Shoutcast/Icecast) in-band metadata from radio streams.
+package icy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrNoMetadata is returned when the server does not send in-band metadata.
+var ErrNoMetadata = errors.New("stream does not provide ICY metadata")
+
+// Metadata is a single decoded metadata block.
+type Metadata struct {
+	StreamTitle string
+	StreamURL   string
+}
+
+// Client connects to a stream and reports title changes.
+type Client struct {
+	HTTPClient *http.Client
+	UserAgent  string
+}
+
+func NewClient(userAgent string) *Client {
+	return &Client{HTTPClient: http.DefaultClient, UserAgent: userAgent}
+}
+
+// Watch requests the stream with `Icy-MetaData: 1` and calls onTitle every time
+// StreamTitle changes. It blocks until ctx is cancelled or the stream ends.
+func (c *Client) Watch(ctx context.Context, streamURL string, onTitle func(title string)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Icy-MetaData", "1")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error connecting to stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error connecting to stream: HTTP status %v", resp.StatusCode)
+	}
+
+	metaint, err := strconv.Atoi(resp.Header.Get("Icy-Metaint"))
+	if err != nil || metaint <= 0 {
+		return ErrNoMetadata
+	}
+
+	reader := NewReader(resp.Body, metaint)
+	last := ""
+	for {
+		meta, err := reader.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if meta.StreamTitle != "" && meta.StreamTitle != last {
+			last = meta.StreamTitle
+			onTitle(meta.StreamTitle)
+		}
+	}
+}
+
+// Reader extracts metadata blocks from a stream body interleaved every metaint bytes.
+type Reader struct {
+	r       *bufio.Reader
+	metaint int
+}
+
+func NewReader(r io.Reader, metaint int) *Reader {
+	return &Reader{r: bufio.NewReader(r), metaint: metaint}
+}
+
+// Next skips the audio data and returns the next non-empty metadata block.
+func (r *Reader) Next() (Metadata, error) {
+	for {
+		if _, err := r.r.Discard(r.metaint); err != nil {
+			return Metadata{}, err
+		}
+
+		length, err := r.r.ReadByte()
+		if err != nil {
+			return Metadata{}, err
+		}
+		if length == 0 {
+			continue
+		}
+
+		block := make([]byte, int(length)*16)
+		if _, err := io.ReadFull(r.r, block); err != nil {
+			return Metadata{}, err
+		}
+
+		return Parse(string(block)), nil
+	}
+}
+
+// Parse decodes a metadata block such as `StreamTitle='Artist - Song';`.
+func Parse(block string) Metadata {
+	block = strings.TrimRight(block, "\x00")
+
+	var meta Metadata
+	for block != "" {
+		key, rest, found := strings.Cut(block, "='")
+		if !found {
+			break
+		}
+
+		// Titles may contain quotes, a value only ends at `';` or at the end of the block
+		value, next, found := strings.Cut(rest, "';")
+		if !found {
+			value, next = strings.TrimSuffix(rest, "'"), ""
+		}
+
+		switch strings.TrimSpace(key) {
+		case "StreamTitle":
+			meta.StreamTitle = strings.TrimSpace(value)
+		case "StreamUrl":
+			meta.StreamURL = strings.TrimSpace(value)
+		}
+
+		block = next
+	}
+
+	return meta
+}
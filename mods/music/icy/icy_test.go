@@ -0,0 +1,82 @@
+package icy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func metadataBlock(meta string) []byte {
+	length := (len(meta) + 15) / 16
+	block := make([]byte, 1+length*16)
+	block[0] = byte(length)
+	copy(block[1:], meta)
+	return block
+}
+
+// fakeIcecast serves audio chunks of metaint bytes followed by the given metadata.
+func fakeIcecast(t *testing.T, metaint int, metas []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Icy-MetaData") != "1" {
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Write(bytes.Repeat([]byte{0xff}, metaint))
+			return
+		}
+
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("Icy-Metaint", strconv.Itoa(metaint))
+		for _, meta := range metas {
+			w.Write(bytes.Repeat([]byte{0xff}, metaint))
+			if meta == "" {
+				w.Write([]byte{0})
+				continue
+			}
+			w.Write(metadataBlock(meta))
+		}
+	}))
+}
+
+func TestWatch(t *testing.T) {
+	server := fakeIcecast(t, 16, []string{
+		"StreamTitle='Artist - First';StreamUrl='';",
+		"",
+		"StreamTitle='Artist - First';",
+		"StreamTitle='It's Second';StreamUrl='http://example.com';",
+	})
+	defer server.Close()
+
+	var titles []string
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := NewClient("test").Watch(ctx, server.URL, func(title string) { titles = append(titles, title) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(titles) != 2 || titles[0] != "Artist - First" || titles[1] != "It's Second" {
+		t.Errorf("unexpected titles: %q", titles)
+	}
+}
+
+func TestWatchWithoutMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	if err := NewClient("test").Watch(context.Background(), server.URL, func(string) {}); err != ErrNoMetadata {
+		t.Errorf("expected ErrNoMetadata, got %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	meta := Parse("StreamTitle='A - B';StreamUrl='http://x';\x00\x00")
+	if meta.StreamTitle != "A - B" || meta.StreamURL != "http://x" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+}
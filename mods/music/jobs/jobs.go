@@ -0,0 +1,358 @@
+// Package jobs runs long downloads and audio extractions on a bounded pool of
+// background workers, keeping their records and progress in the database.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/db"
+)
+
+const (
+	KindCurl    = "curl"
+	KindExtract = "extract"
+	KindVerify  = "verify"
+)
+
+const (
+	StatusQueued   = "queued"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// maxQueued bounds the jobs waiting for a worker.
+const maxQueued = 100
+
+// ProgressInterval throttles progress notifications and database writes.
+var ProgressInterval = 2 * time.Second
+
+var (
+	ErrQueueFull = errors.New("too many jobs are waiting, try again later")
+	ErrNotFound  = errors.New("job not found")
+	ErrNotActive = errors.New("job has already finished")
+)
+
+const interruptedError = "interrupted by restart"
+
+// Progress is reported by a running job. Zero values are unknown.
+type Progress struct {
+	Stage      string
+	BytesDone  int64
+	BytesTotal int64
+	Processed  time.Duration // media time processed by ffmpeg
+	Duration   time.Duration
+}
+
+type Report func(Progress)
+
+// RunFunc does the work of a job and returns a summary of its result.
+type RunFunc func(ctx context.Context, report Report) (string, error)
+
+// NotifyFunc receives a copy of the job whenever its status or progress changes.
+type NotifyFunc func(job db.Job)
+
+type IQueue interface {
+	Submit(guildID, kind, input string, run RunFunc, notify NotifyFunc) (*db.Job, error)
+	Cancel(guildID string, id uint) error
+	Get(guildID string, id uint) (*db.Job, error)
+	List(guildID string, limit int) ([]db.Job, error)
+}
+
+type Queue struct {
+	jobs   db.JobRepository
+	tasks  chan *task
+	mu     sync.Mutex
+	active map[uint]*task
+}
+
+type task struct {
+	mu       sync.Mutex
+	job      db.Job
+	run      RunFunc
+	notify   NotifyFunc
+	ctx      context.Context
+	cancel   context.CancelFunc
+	notified time.Time
+}
+
+var (
+	shared     IQueue
+	sharedOnce sync.Once
+)
+
+// Shared returns the queue used by the whole application, started with the
+// configured number of workers on first use.
+func Shared(repos *db.Repositories) IQueue {
+	sharedOnce.Do(func() {
+		workers := 2
+		if conf, err := config.NewConfig(); err != nil {
+			slog.Errorf("Error loading config, using %v job workers: %v", workers, err)
+		} else {
+			workers = conf.JobsWorkers
+		}
+		shared = NewQueue(repos.Jobs, workers)
+	})
+	return shared
+}
+
+// NewQueue starts workers running submitted jobs. Jobs left queued or running by
+// a previous process are marked as failed.
+func NewQueue(jobs db.JobRepository, workers int) IQueue {
+	q := &Queue{
+		jobs:   jobs,
+		tasks:  make(chan *task, maxQueued),
+		active: make(map[uint]*task),
+	}
+
+	q.failInterrupted()
+
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+
+	return q
+}
+
+func (q *Queue) failInterrupted() {
+	stale, err := q.jobs.GetByStatus(StatusQueued, StatusRunning)
+	if err != nil {
+		slog.Errorf("Error getting unfinished jobs: %v", err)
+		return
+	}
+
+	for _, job := range stale {
+		job.Status = StatusFailed
+		job.Error = interruptedError
+		if err := q.jobs.Update(&job); err != nil {
+			slog.Errorf("Error updating job %v: %v", job.ID, err)
+		}
+	}
+}
+
+// Submit records a new job and queues it for the next free worker.
+func (q *Queue) Submit(guildID, kind, input string, run RunFunc, notify NotifyFunc) (*db.Job, error) {
+	t := &task{
+		job: db.Job{
+			GuildID: guildID,
+			Kind:    kind,
+			Input:   input,
+			Status:  StatusQueued,
+		},
+		run:    run,
+		notify: notify,
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+
+	if err := q.jobs.Create(&t.job); err != nil {
+		t.cancel()
+		return nil, fmt.Errorf("error creating job: %v", err)
+	}
+
+	q.mu.Lock()
+	q.active[t.job.ID] = t
+	q.mu.Unlock()
+
+	select {
+	case q.tasks <- t:
+	default:
+		q.finish(t, "", ErrQueueFull)
+		return nil, ErrQueueFull
+	}
+
+	t.mu.Lock()
+	job := t.job
+	t.mu.Unlock()
+	return &job, nil
+}
+
+// Cancel stops a queued or running job of the guild.
+func (q *Queue) Cancel(guildID string, id uint) error {
+	q.mu.Lock()
+	t, ok := q.active[id]
+	q.mu.Unlock()
+
+	if !ok || t.job.GuildID != guildID {
+		if _, err := q.Get(guildID, id); err != nil {
+			return err
+		}
+		return ErrNotActive
+	}
+
+	t.cancel()
+	return nil
+}
+
+// Get returns a job of the guild with its latest progress, which is saved to
+// the database at most every ProgressInterval.
+func (q *Queue) Get(guildID string, id uint) (*db.Job, error) {
+	job, err := q.jobs.Get(id)
+	if err != nil || job.GuildID != guildID {
+		return nil, ErrNotFound
+	}
+	q.refresh(job)
+	return job, nil
+}
+
+// List returns the most recent jobs of the guild, newest first.
+func (q *Queue) List(guildID string, limit int) ([]db.Job, error) {
+	list, err := q.jobs.GetAll(guildID, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		q.refresh(&list[i])
+	}
+	return list, nil
+}
+
+// refresh replaces the stored job with the in-memory one while it is active.
+func (q *Queue) refresh(job *db.Job) {
+	q.mu.Lock()
+	t, ok := q.active[job.ID]
+	q.mu.Unlock()
+
+	if ok {
+		t.mu.Lock()
+		*job = t.job
+		t.mu.Unlock()
+	}
+}
+
+func (q *Queue) work() {
+	for t := range q.tasks {
+		if t.ctx.Err() != nil {
+			q.finish(t, "", t.ctx.Err())
+			continue
+		}
+
+		t.mu.Lock()
+		t.job.Status = StatusRunning
+		t.mu.Unlock()
+		q.save(t)
+
+		result, err := t.run(t.ctx, func(p Progress) { q.progress(t, p) })
+		if err == nil && t.ctx.Err() != nil {
+			err = t.ctx.Err()
+		}
+		q.finish(t, result, err)
+	}
+}
+
+func (q *Queue) progress(t *task, p Progress) {
+	t.mu.Lock()
+	if p.Stage != "" {
+		t.job.Stage = p.Stage
+	}
+	t.job.BytesDone = p.BytesDone
+	t.job.BytesTotal = p.BytesTotal
+	t.job.Processed = p.Processed.Seconds()
+	t.job.Duration = p.Duration.Seconds()
+	due := time.Since(t.notified) >= ProgressInterval
+	t.mu.Unlock()
+
+	if due {
+		q.save(t)
+	}
+}
+
+func (q *Queue) finish(t *task, result string, err error) {
+	t.mu.Lock()
+	switch {
+	case errors.Is(err, context.Canceled):
+		t.job.Status = StatusCanceled
+	case err != nil:
+		t.job.Status = StatusFailed
+		t.job.Error = err.Error()
+	default:
+		t.job.Status = StatusDone
+		t.job.Result = result
+	}
+	t.mu.Unlock()
+
+	q.save(t)
+
+	q.mu.Lock()
+	delete(q.active, t.job.ID)
+	q.mu.Unlock()
+	t.cancel()
+}
+
+func (q *Queue) save(t *task) {
+	t.mu.Lock()
+	t.notified = time.Now()
+	if err := q.jobs.Update(&t.job); err != nil {
+		slog.Errorf("Error updating job %v: %v", t.job.ID, err)
+	}
+	job := t.job
+	t.mu.Unlock()
+
+	if t.notify != nil {
+		t.notify(job)
+	}
+}
+
+// Describe formats the job status and progress for chat messages.
+func Describe(job db.Job) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "` #%d ` %v %v `%v`", job.ID, statusIcon(job.Status), job.Kind, job.Status)
+
+	if job.Stage != "" && (job.Status == StatusRunning || job.Status == StatusQueued) {
+		fmt.Fprintf(&b, " %v", job.Stage)
+	}
+
+	if job.Status == StatusRunning {
+		if job.Duration > 0 {
+			fmt.Fprintf(&b, " %v / %v (%.0f%%)", formatSeconds(job.Processed), formatSeconds(job.Duration), 100*job.Processed/job.Duration)
+		} else if job.Processed > 0 {
+			fmt.Fprintf(&b, " %v", formatSeconds(job.Processed))
+		} else if job.BytesTotal > 0 {
+			fmt.Fprintf(&b, " %v / %v (%.0f%%)", formatBytes(job.BytesDone), formatBytes(job.BytesTotal), 100*float64(job.BytesDone)/float64(job.BytesTotal))
+		} else if job.BytesDone > 0 {
+			fmt.Fprintf(&b, " %v", formatBytes(job.BytesDone))
+		}
+	}
+
+	if job.Error != "" {
+		fmt.Fprintf(&b, "\n`%v`", job.Error)
+	}
+
+	return b.String()
+}
+
+func statusIcon(status string) string {
+	switch status {
+	case StatusQueued:
+		return "🕒"
+	case StatusRunning:
+		return "⏳"
+	case StatusDone:
+		return "✅"
+	case StatusCanceled:
+		return "⛔"
+	default:
+		return "❗️"
+	}
+}
+
+func formatSeconds(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func formatBytes(size int64) string {
+	const mb = 1 << 20
+	if size < mb {
+		return fmt.Sprintf("%.0f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/mb)
+}
@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/db"
+)
+
+func TestQueue(t *testing.T) {
+	repo := db.NewMemoryJobRepository()
+	repo.Create(&db.Job{GuildID: "guild", Kind: KindCurl, Status: StatusRunning})
+
+	q := NewQueue(repo, 1)
+
+	stale, _ := q.Get("guild", 1)
+	if stale.Status != StatusFailed || stale.Error != interruptedError {
+		t.Errorf("unfinished job is not failed on start: %+v", stale)
+	}
+
+	finished := make(chan db.Job, 10)
+	notify := func(job db.Job) {
+		if job.Status != StatusQueued && job.Status != StatusRunning {
+			finished <- job
+		}
+	}
+
+	started := make(chan struct{})
+	blocking := func(ctx context.Context, report Report) (string, error) {
+		report(Progress{Stage: "downloading", BytesDone: 10, BytesTotal: 100})
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	first, err := q.Submit("guild", KindCurl, "url", blocking, notify)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The only worker is busy, so the second job waits in the queue
+	second, err := q.Submit("guild", KindExtract, "", func(ctx context.Context, report Report) (string, error) {
+		return "extracted", nil
+	}, notify)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-started
+	if job, _ := q.Get("guild", first.ID); job.Status != StatusRunning || job.BytesTotal != 100 {
+		t.Errorf("unexpected running job: %+v", job)
+	}
+
+	if err := q.Cancel("other", first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("job of another guild is canceled: %v", err)
+	}
+	if err := q.Cancel("guild", first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct {
+		id     uint
+		status string
+	}{{first.ID, StatusCanceled}, {second.ID, StatusDone}} {
+		select {
+		case job := <-finished:
+			if job.ID != want.id || job.Status != want.status {
+				t.Errorf("expected job %v %v, got %+v", want.id, want.status, job)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("job did not finish")
+		}
+	}
+
+	if err := q.Cancel("guild", second.ID); !errors.Is(err, ErrNotActive) {
+		t.Errorf("finished job is canceled: %v", err)
+	}
+
+	list, err := q.List("guild", 2)
+	if err != nil || len(list) != 2 || list[0].ID != second.ID || list[0].Result != "extracted" {
+		t.Errorf("unexpected jobs: %+v, %v", list, err)
+	}
+}
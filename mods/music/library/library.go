@@ -0,0 +1,229 @@
+// Package library indexes a local music directory and searches it by tags.
+package library
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/db"
+)
+
+// Extensions lists the audio formats picked up by a scan.
+var Extensions = []string{".mp3", ".flac", ".ogg", ".opus", ".m4a", ".wav"}
+
+// ErrNoMatch is returned by Find when nothing in the library matches the query.
+var ErrNoMatch = errors.New("no matching tracks in the library")
+
+type ScanResult struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Removed   int
+	Failed    int
+}
+
+type ILibrary interface {
+	Scan(ctx context.Context) (*ScanResult, error)
+	Find(query string) ([]db.LibraryTrack, error)
+	Count() (int64, error)
+}
+
+type Library struct {
+	dir      string
+	coverDir string
+	repo     db.LibraryRepository
+	prober   Prober
+}
+
+// NewLibrary indexes dir into repo, storing extracted covers in coverDir.
+func NewLibrary(dir, coverDir string, repo db.LibraryRepository, prober Prober) ILibrary {
+	return &Library{dir: dir, coverDir: coverDir, repo: repo, prober: prober}
+}
+
+// IsAudioFile reports whether name has one of the supported extensions.
+func IsAudioFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// SongID is the stable id of a library file, derived from its path.
+func SongID(path string) string {
+	sum := sha1.Sum([]byte(filepath.ToSlash(path)))
+	return "library-" + hex.EncodeToString(sum[:])
+}
+
+// Scan walks the library directory, probing new and modified files and
+// dropping index entries of files that are gone.
+func (l *Library) Scan(ctx context.Context) (*ScanResult, error) {
+	if l.dir == "" {
+		return nil, errors.New("library directory is not configured")
+	}
+	if info, err := os.Stat(l.dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("library directory %v is not accessible", l.dir)
+	}
+
+	existing, err := l.repo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading library index: %v", err)
+	}
+	known := make(map[string]db.LibraryTrack, len(existing))
+	for _, track := range existing {
+		known[track.SongID] = track
+	}
+
+	result := &ScanResult{}
+	seen := make(map[string]bool)
+
+	err = filepath.WalkDir(l.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			slog.Warnf("Skipping %v: %v", path, err)
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if entry.IsDir() || !IsAudioFile(path) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			slog.Warnf("Skipping %v: %v", path, err)
+			return nil
+		}
+
+		songID := SongID(path)
+		seen[songID] = true
+
+		previous, isKnown := known[songID]
+		if isKnown && previous.Size == info.Size() && previous.ModTime == info.ModTime().Unix() {
+			result.Unchanged++
+			return nil
+		}
+
+		track, err := l.index(ctx, path, songID, info)
+		if err != nil {
+			slog.Warnf("Error indexing %v: %v", path, err)
+			result.Failed++
+			return nil
+		}
+
+		if err := l.repo.Save(track); err != nil {
+			return fmt.Errorf("error saving %v to library index: %v", path, err)
+		}
+
+		if isKnown {
+			result.Updated++
+		} else {
+			result.Added++
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for songID, track := range known {
+		if seen[songID] {
+			continue
+		}
+		if err := l.repo.Delete(track.ID); err != nil {
+			return result, fmt.Errorf("error removing %v from library index: %v", track.Path, err)
+		}
+		if track.CoverPath != "" {
+			os.Remove(track.CoverPath)
+		}
+		result.Removed++
+	}
+
+	slog.Infof("Library scan of %v done: %+v", l.dir, *result)
+
+	return result, nil
+}
+
+func (l *Library) index(ctx context.Context, path, songID string, info fs.FileInfo) (*db.LibraryTrack, error) {
+	tags, err := l.prober.Probe(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &db.LibraryTrack{
+		SongID:      songID,
+		Path:        path,
+		Artist:      tags.Artist,
+		Album:       tags.Album,
+		Title:       tags.Title,
+		TrackNumber: tags.TrackNumber,
+		Duration:    tags.Duration,
+		Size:        info.Size(),
+		ModTime:     info.ModTime().Unix(),
+	}
+	if track.Title == "" {
+		track.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	if tags.HasCover && l.coverDir != "" {
+		if err := os.MkdirAll(l.coverDir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating cover directory: %v", err)
+		}
+
+		coverPath := filepath.Join(l.coverDir, songID+".jpg")
+		if err := l.prober.ExtractCover(ctx, path, coverPath); err != nil {
+			slog.Warnf("Error extracting cover of %v: %v", path, err)
+		} else {
+			track.CoverPath = coverPath
+		}
+	}
+
+	return track, nil
+}
+
+// Find resolves `artist:<name>`, `album:<name>` or a fuzzy title search.
+// Title searches return the best matches first.
+func (l *Library) Find(query string) ([]db.LibraryTrack, error) {
+	query = strings.TrimSpace(query)
+	lower := strings.ToLower(query)
+
+	var tracks []db.LibraryTrack
+	var err error
+
+	switch {
+	case strings.HasPrefix(lower, "artist:"):
+		tracks, err = l.repo.FindByArtist(strings.TrimSpace(query[len("artist:"):]))
+	case strings.HasPrefix(lower, "album:"):
+		tracks, err = l.repo.FindByAlbum(strings.TrimSpace(query[len("album:"):]))
+	default:
+		var all []db.LibraryTrack
+		all, err = l.repo.GetAll()
+		if err == nil {
+			tracks = Search(all, query)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error searching library: %v", err)
+	}
+
+	if len(tracks) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	return tracks, nil
+}
+
+func (l *Library) Count() (int64, error) {
+	return l.repo.Count()
+}
@@ -0,0 +1,128 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/db"
+)
+
+// fakeProber derives tags from file names like "Artist - Album - 01 - Title.mp3".
+type fakeProber struct {
+	probed int
+}
+
+func (f *fakeProber) Probe(ctx context.Context, path string) (*Tags, error) {
+	f.probed++
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	parts := strings.Split(name, " - ")
+	if len(parts) != 4 {
+		return nil, errors.New("unreadable file")
+	}
+
+	return &Tags{Artist: parts[0], Album: parts[1], Title: parts[3], TrackNumber: int(parts[2][1] - '0'), Duration: 180}, nil
+}
+
+func (f *fakeProber) ExtractCover(ctx context.Context, path, dest string) error {
+	return nil
+}
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanAndFind(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"Daft Punk/Discovery/Daft Punk - Discovery - 01 - One More Time.mp3",
+		"Daft Punk/Discovery/Daft Punk - Discovery - 02 - Aerodynamic.flac",
+		"Queen/Queen - A Night at the Opera - 01 - Bohemian Rhapsody.opus",
+		"Queen/cover.jpg",
+		"broken.ogg",
+	)
+
+	prober := &fakeProber{}
+	lib := NewLibrary(dir, "", db.NewMemoryLibraryRepository(), prober)
+
+	result, err := lib.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 3 || result.Failed != 1 {
+		t.Errorf("unexpected first scan result: %+v", result)
+	}
+
+	tracks, err := lib.Find("artist:daft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 || tracks[0].Title != "One More Time" || tracks[1].Title != "Aerodynamic" {
+		t.Errorf("unexpected artist tracks: %+v", tracks)
+	}
+
+	tracks, err = lib.Find("album: night at the opera")
+	if err != nil || len(tracks) != 1 || tracks[0].Title != "Bohemian Rhapsody" {
+		t.Errorf("unexpected album tracks: %+v, %v", tracks, err)
+	}
+
+	tracks, err = lib.Find("bohemain rapsody")
+	if err != nil || tracks[0].Title != "Bohemian Rhapsody" {
+		t.Errorf("unexpected fuzzy search result: %+v, %v", tracks, err)
+	}
+
+	if _, err := lib.Find("never gonna give you up"); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("expected ErrNoMatch, got %v", err)
+	}
+
+	// Unchanged files are not probed again, deleted ones leave the index
+	os.Remove(filepath.Join(dir, "Queen", "Queen - A Night at the Opera - 01 - Bohemian Rhapsody.opus"))
+	changed := filepath.Join(dir, "Daft Punk", "Discovery", "Daft Punk - Discovery - 02 - Aerodynamic.flac")
+	os.Chtimes(changed, time.Now(), time.Now().Add(time.Hour))
+
+	prober.probed = 0
+	result, err = lib.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unchanged != 1 || result.Updated != 1 || result.Removed != 1 || prober.probed != 2 {
+		t.Errorf("unexpected rescan result: %+v, probed %d", result, prober.probed)
+	}
+	if count, _ := lib.Count(); count != 2 {
+		t.Errorf("expected 2 indexed tracks, got %d", count)
+	}
+}
+
+func TestParseFFprobe(t *testing.T) {
+	data := []byte(`{
+		"streams": [
+			{"index": 0, "codec_name": "mp3", "codec_type": "audio", "tags": {"TITLE": "Stream Title", "ARTIST": "Band"}},
+			{"index": 1, "codec_name": "mjpeg", "codec_type": "video", "disposition": {"attached_pic": 1}, "tags": {"comment": "Cover (front)"}}
+		],
+		"format": {"duration": "215.480000", "tags": {"title": "Song", "album": "Album", "track": "3/12"}}
+	}`)
+
+	tags, err := parseFFprobe(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Tags{Artist: "Band", Album: "Album", Title: "Song", TrackNumber: 3, Duration: 215.48, HasCover: true, CoverStream: 1, Codec: "mp3"}
+	if *tags != want {
+		t.Errorf("got %+v, want %+v", *tags, want)
+	}
+}
@@ -0,0 +1,136 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Tags are the metadata read from an audio file.
+type Tags struct {
+	Artist      string
+	Album       string
+	Title       string
+	TrackNumber int
+	Duration    float64 // seconds
+	HasCover    bool
+	CoverStream int    // index of the cover art stream when HasCover is set
+	Codec       string // codec of the first audio stream
+}
+
+// Prober reads tags from an audio file and extracts its cover art.
+type Prober interface {
+	Probe(ctx context.Context, path string) (*Tags, error)
+	ExtractCover(ctx context.Context, path, dest string) error
+}
+
+// FFprobe reads tags with ffprobe and extracts covers with ffmpeg.
+type FFprobe struct {
+	FfprobePath string
+	FfmpegPath  string
+}
+
+// NewFFprobe uses the binaries from ffmpegBinaryPath (like dca does) or from PATH.
+func NewFFprobe(ffmpegBinaryPath string) Prober {
+	if _, err := os.Stat(ffmpegBinaryPath); errors.Is(err, os.ErrNotExist) {
+		ffmpegBinaryPath = ""
+	}
+	return &FFprobe{FfprobePath: ffmpegBinaryPath + "ffprobe", FfmpegPath: ffmpegBinaryPath + "ffmpeg"}
+}
+
+type ffprobeOutput struct {
+	Format struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+	Streams []struct {
+		Index       int               `json:"index"`
+		CodecName   string            `json:"codec_name"`
+		CodecType   string            `json:"codec_type"`
+		Tags        map[string]string `json:"tags"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+}
+
+func (f *FFprobe) Probe(ctx context.Context, path string) (*Tags, error) {
+	cmd := exec.CommandContext(ctx, f.FfprobePath, "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", path)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error running ffprobe: %v", err)
+	}
+
+	return parseFFprobe(out.Bytes())
+}
+
+func parseFFprobe(data []byte) (*Tags, error) {
+	var output ffprobeOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("error parsing ffprobe output: %v", err)
+	}
+
+	// Container tags win, Ogg/Opus files keep theirs on the audio stream
+	tags := make(map[string]string)
+	for _, stream := range output.Streams {
+		if stream.CodecType == "video" && stream.Disposition.AttachedPic == 1 {
+			continue
+		}
+		for k, v := range stream.Tags {
+			tags[strings.ToLower(k)] = v
+		}
+	}
+	for k, v := range output.Format.Tags {
+		tags[strings.ToLower(k)] = v
+	}
+
+	result := &Tags{
+		Artist: strings.TrimSpace(firstTag(tags, "artist", "album_artist")),
+		Album:  strings.TrimSpace(tags["album"]),
+		Title:  strings.TrimSpace(tags["title"]),
+	}
+
+	// Track numbers come as "3" or "3/12"
+	if n, err := strconv.Atoi(strings.TrimSpace(strings.SplitN(firstTag(tags, "track", "tracknumber"), "/", 2)[0])); err == nil {
+		result.TrackNumber = n
+	}
+	if d, err := strconv.ParseFloat(output.Format.Duration, 64); err == nil {
+		result.Duration = d
+	}
+	for _, stream := range output.Streams {
+		if stream.CodecType == "video" && stream.Disposition.AttachedPic == 1 && !result.HasCover {
+			result.HasCover = true
+			result.CoverStream = stream.Index
+		}
+		if stream.CodecType == "audio" && result.Codec == "" {
+			result.Codec = stream.CodecName
+		}
+	}
+
+	return result, nil
+}
+
+func firstTag(tags map[string]string, names ...string) string {
+	for _, name := range names {
+		if v := tags[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (f *FFprobe) ExtractCover(ctx context.Context, path, dest string) error {
+	cmd := exec.CommandContext(ctx, f.FfmpegPath, "-v", "quiet", "-y", "-i", path, "-an", "-frames:v", "1", dest)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error extracting cover art: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package library
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/keshon/melodix-player/internal/db"
+)
+
+// minScore is the lowest average token similarity considered a match.
+const minScore = 0.75
+
+// Search ranks tracks by how well their title, artist and album match query,
+// tolerating typos. Tracks below minScore are left out.
+func Search(tracks []db.LibraryTrack, query string) []db.LibraryTrack {
+	tokens := words(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	type scored struct {
+		track db.LibraryTrack
+		score float64
+	}
+
+	var matches []scored
+	for _, track := range tracks {
+		candidate := words(track.Title + " " + track.Artist + " " + track.Album)
+
+		var total float64
+		for _, token := range tokens {
+			total += bestSimilarity(token, candidate)
+		}
+
+		if score := total / float64(len(tokens)); score >= minScore {
+			matches = append(matches, scored{track, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	result := make([]db.LibraryTrack, len(matches))
+	for i, m := range matches {
+		result[i] = m.track
+	}
+	return result
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func bestSimilarity(token string, candidate []string) float64 {
+	var best float64
+	for _, word := range candidate {
+		var s float64
+		switch {
+		case word == token:
+			s = 1
+		case strings.HasPrefix(word, token):
+			s = 0.9
+		default:
+			s = similarity(token, word)
+		}
+		if s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// similarity is 1 minus the Levenshtein distance normalized by the longer word.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
@@ -0,0 +1,121 @@
+package manifest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type mpd struct {
+	Type     string   `xml:"type,attr"`
+	Duration string   `xml:"mediaPresentationDuration,attr"`
+	Titles   []string `xml:"ProgramInformation>Title"`
+	Periods  []struct {
+		AdaptationSets []struct {
+			MimeType        string `xml:"mimeType,attr"`
+			ContentType     string `xml:"contentType,attr"`
+			Codecs          string `xml:"codecs,attr"`
+			Representations []struct {
+				ID        string `xml:"id,attr"`
+				Bandwidth int    `xml:"bandwidth,attr"`
+				MimeType  string `xml:"mimeType,attr"`
+				Codecs    string `xml:"codecs,attr"`
+			} `xml:"Representation"`
+		} `xml:"AdaptationSet"`
+	} `xml:"Period"`
+}
+
+// selectDASH picks the highest bandwidth audio representation of the first period.
+// ffmpeg exposes DASH representations as separate streams, so the choice is passed
+// as an audio stream index to -map rather than as a URL.
+func selectDASH(data []byte, base *url.URL) (*Stream, error) {
+	var manifest mpd
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error reading DASH manifest: %v", err)
+	}
+	if len(manifest.Periods) == 0 {
+		return nil, fmt.Errorf("DASH manifest has no periods")
+	}
+
+	index, chosen, bandwidth := 0, -1, 0
+	for _, set := range manifest.Periods[0].AdaptationSets {
+		for _, rep := range set.Representations {
+			mimeType := firstNonEmpty(rep.MimeType, set.MimeType)
+			codecs := firstNonEmpty(rep.Codecs, set.Codecs)
+			if !strings.HasPrefix(mimeType, "audio/") && set.ContentType != "audio" && !isAudioCodec(codecs) {
+				continue
+			}
+
+			if chosen < 0 || rep.Bandwidth > bandwidth {
+				chosen, bandwidth = index, rep.Bandwidth
+			}
+			index++
+		}
+	}
+
+	if chosen < 0 {
+		return nil, fmt.Errorf("DASH manifest has no audio representation")
+	}
+
+	stream := &Stream{
+		Kind:         KindDASH,
+		URL:          base.String(),
+		Bandwidth:    bandwidth,
+		AudioOnly:    true,
+		Map:          fmt.Sprintf("0:a:%d", chosen),
+		InputOptions: segmentedInputOptions(),
+	}
+	if manifest.Type != "dynamic" {
+		stream.Duration = parseISODuration(manifest.Duration)
+	}
+	if len(manifest.Titles) > 0 {
+		stream.Title = strings.TrimSpace(manifest.Titles[0])
+	}
+
+	return stream, nil
+}
+
+func isAudioCodec(codecs string) bool {
+	codecs = strings.ToLower(codecs)
+	if codecs == "" {
+		return false
+	}
+	for _, video := range videoCodecs {
+		if strings.Contains(codecs, video) {
+			return false
+		}
+	}
+	return true
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+var reISODuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:([\d.]+)S)?)?$`)
+
+// parseISODuration reads an xs:duration such as `PT1H2M3.5S`, returning 0 when invalid.
+func parseISODuration(value string) time.Duration {
+	matches := reISODuration.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil {
+		return 0
+	}
+
+	var total float64
+	for i, unit := range []float64{24 * 3600, 3600, 60, 1} {
+		if n, err := strconv.ParseFloat(matches[i+1], 64); err == nil {
+			total += n * unit
+		}
+	}
+
+	return time.Duration(total * float64(time.Second))
+}
@@ -0,0 +1,221 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// segmentedInputOptions turns off reconnecting at EOF, which makes ffmpeg refetch finished segments forever.
+func segmentedInputOptions() []string {
+	return []string{"-reconnect_at_eof", "0"}
+}
+
+var videoCodecs = []string{"avc1", "avc3", "hvc1", "hev1", "vp08", "vp09", "av01", "mp4v"}
+
+type hlsVariant struct {
+	uri        string
+	bandwidth  int
+	resolution string
+	codecs     string
+}
+
+func (v hlsVariant) audioOnly() bool {
+	if v.resolution != "" {
+		return false
+	}
+	for _, codec := range strings.Split(strings.ToLower(v.codecs), ",") {
+		for _, video := range videoCodecs {
+			if strings.HasPrefix(strings.TrimSpace(codec), video) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type hlsRendition struct {
+	uri       string
+	isDefault bool
+}
+
+func selectHLS(data []byte, base *url.URL) (*Stream, error) {
+	var variants []hlsVariant
+	var renditions []hlsRendition
+	var title string
+	var pending *hlsVariant
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bandwidth, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			pending = &hlsVariant{bandwidth: bandwidth, resolution: attrs["RESOLUTION"], codecs: attrs["CODECS"]}
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-MEDIA:"))
+			if attrs["TYPE"] == "AUDIO" && attrs["URI"] != "" {
+				renditions = append(renditions, hlsRendition{uri: attrs["URI"], isDefault: attrs["DEFAULT"] == "YES"})
+			}
+		case strings.HasPrefix(line, "#EXT-X-SESSION-DATA:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-SESSION-DATA:"))
+			if attrs["DATA-ID"] == "com.apple.hls.title" {
+				title = attrs["VALUE"]
+			}
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			if pending != nil {
+				pending.uri = line
+				variants = append(variants, *pending)
+				pending = nil
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading HLS manifest: %v", err)
+	}
+
+	stream := &Stream{Kind: KindHLS, Title: title, InputOptions: segmentedInputOptions()}
+
+	// A media playlist is played as is
+	if len(variants) == 0 && len(renditions) == 0 {
+		media := ParseMedia(data)
+		stream.URL = base.String()
+		stream.Duration = media.Duration
+		if stream.Title == "" {
+			stream.Title = media.Title
+		}
+		return stream, nil
+	}
+
+	if len(renditions) > 0 {
+		chosen := renditions[0]
+		for _, r := range renditions {
+			if r.isDefault {
+				chosen = r
+				break
+			}
+		}
+		return resolved(stream, base, chosen.uri, 0, true)
+	}
+
+	var best *hlsVariant
+	for i, v := range variants {
+		if v.audioOnly() && (best == nil || v.bandwidth > best.bandwidth) {
+			best = &variants[i]
+		}
+	}
+	if best != nil {
+		return resolved(stream, base, best.uri, best.bandwidth, true)
+	}
+
+	lowest := &variants[0]
+	for i, v := range variants {
+		if v.bandwidth < lowest.bandwidth {
+			lowest = &variants[i]
+		}
+	}
+	return resolved(stream, base, lowest.uri, lowest.bandwidth, false)
+}
+
+func resolved(stream *Stream, base *url.URL, uri string, bandwidth int, audioOnly bool) (*Stream, error) {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid variant URI %q: %v", uri, err)
+	}
+
+	stream.URL = base.ResolveReference(ref).String()
+	stream.Bandwidth = bandwidth
+	stream.AudioOnly = audioOnly
+	return stream, nil
+}
+
+// Media describes an HLS media playlist.
+type Media struct {
+	Title    string        // Title of the most recent segment
+	Duration time.Duration // Total duration, 0 for live playlists without #EXT-X-ENDLIST
+}
+
+// ParseMedia reads an HLS media playlist. Segment titles are taken from
+// `#EXTINF:<duration>,<title>` or `#EXTINF:<duration>,title="..",artist=".."`.
+func ParseMedia(data []byte) Media {
+	var media Media
+	var total float64
+	var ended bool
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "#EXT-X-ENDLIST" {
+			ended = true
+			continue
+		}
+		if !strings.HasPrefix(line, "#EXTINF:") {
+			continue
+		}
+
+		duration, info, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+		if seconds, err := strconv.ParseFloat(strings.TrimSpace(duration), 64); err == nil && seconds > 0 {
+			total += seconds
+		}
+
+		info = strings.TrimSpace(info)
+		if info == "" {
+			continue
+		}
+		if !strings.Contains(info, "=\"") {
+			media.Title = info
+			continue
+		}
+
+		attrs := parseAttributes(info)
+		switch {
+		case attrs["artist"] != "" && attrs["title"] != "":
+			media.Title = attrs["artist"] + " - " + attrs["title"]
+		case attrs["title"] != "":
+			media.Title = attrs["title"]
+		}
+	}
+
+	if ended {
+		media.Duration = time.Duration(total * float64(time.Second))
+	}
+
+	return media
+}
+
+// parseAttributes reads an HLS attribute list such as `BANDWIDTH=1,CODECS="a,b"`.
+func parseAttributes(list string) map[string]string {
+	attrs := make(map[string]string)
+
+	for list != "" {
+		key, rest, found := strings.Cut(list, "=")
+		if !found {
+			break
+		}
+		key = strings.TrimSpace(key)
+
+		var value string
+		if strings.HasPrefix(rest, "\"") {
+			value, rest, _ = strings.Cut(rest[1:], "\"")
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		attrs[key] = strings.TrimSpace(value)
+		list = rest
+	}
+
+	return attrs
+}
@@ -0,0 +1,102 @@
+// Package manifest picks a playable audio rendition from HLS and DASH manifests.
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindHLS
+	KindDASH
+)
+
+func (k Kind) String() string {
+	kinds := map[Kind]string{
+		KindUnknown: "unknown",
+		KindHLS:     "HLS",
+		KindDASH:    "DASH",
+	}
+
+	return kinds[k]
+}
+
+// Stream is the rendition selected from a manifest and how ffmpeg should read it.
+type Stream struct {
+	Kind         Kind
+	URL          string        // URL to hand to ffmpeg (variant playlist for HLS, the manifest for DASH)
+	Title        string        // Title taken from the manifest tags, if any
+	Bandwidth    int           // Bandwidth of the selected rendition in bits/s, 0 when unknown
+	Duration     time.Duration // Duration of on-demand content, 0 for live streams
+	AudioOnly    bool          // Whether the selected rendition carries no video
+	Map          string        // ffmpeg -map selector for the selected rendition
+	InputOptions []string      // ffmpeg options to put before the input
+}
+
+var contentTypes = map[string]Kind{
+	"application/vnd.apple.mpegurl": KindHLS,
+	"application/x-mpegurl":         KindHLS,
+	"audio/mpegurl":                 KindHLS,
+	"audio/x-mpegurl":               KindHLS,
+	"application/dash+xml":          KindDASH,
+}
+
+// IsManifestFile reports whether name (a file name or URL path) looks like a DASH manifest.
+// HLS shares its extension with plain M3U playlists and is told apart by content.
+func IsManifestFile(name string) bool {
+	return strings.ToLower(path.Ext(name)) == ".mpd"
+}
+
+// IsManifestContentType reports whether contentType may denote an HLS or DASH manifest.
+func IsManifestContentType(contentType string) bool {
+	return kindFromContentType(contentType) != KindUnknown
+}
+
+// DetectKind tells HLS and DASH manifests apart, preferring the content itself
+// since HLS and plain M3U playlists share content types and extensions.
+func DetectKind(name, contentType string, data []byte) Kind {
+	head := strings.TrimSpace(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	switch {
+	case strings.HasPrefix(head, "#EXTM3U") && strings.Contains(head, "#EXT-X-"):
+		return KindHLS
+	case strings.Contains(head, "<MPD"):
+		return KindDASH
+	case len(data) > 0:
+		return KindUnknown
+	}
+
+	if kindFromContentType(contentType) == KindDASH || IsManifestFile(name) {
+		return KindDASH
+	}
+
+	return KindUnknown
+}
+
+func kindFromContentType(contentType string) Kind {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return KindUnknown
+	}
+	return contentTypes[strings.ToLower(mediaType)]
+}
+
+// Select parses a manifest fetched from base and picks the rendition to play:
+// an audio-only one when available, otherwise the lowest bandwidth video variant.
+func Select(kind Kind, data []byte, base *url.URL) (*Stream, error) {
+	switch kind {
+	case KindHLS:
+		return selectHLS(data, base)
+	case KindDASH:
+		return selectDASH(data, base)
+	}
+
+	return nil, fmt.Errorf("unsupported manifest format")
+}
@@ -0,0 +1,127 @@
+package manifest
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestSelectHLSAudioOnlyVariant(t *testing.T) {
+	data := []byte(`#EXTM3U
+#EXT-X-SESSION-DATA:DATA-ID="com.apple.hls.title",VALUE="Radio One"
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+video/360.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=64000,CODECS="mp4a.40.5"
+audio/64.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=128000,CODECS="mp4a.40.2"
+audio/128.m3u8
+`)
+
+	kind := DetectKind("master.m3u8", "application/vnd.apple.mpegurl", data)
+	if kind != KindHLS {
+		t.Fatalf("DetectKind = %v, want HLS", kind)
+	}
+
+	stream, err := Select(kind, data, mustParse(t, "http://radio.example.com/live/master.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stream.URL != "http://radio.example.com/live/audio/128.m3u8" || !stream.AudioOnly || stream.Bandwidth != 128000 || stream.Title != "Radio One" {
+		t.Errorf("unexpected stream: %+v", stream)
+	}
+}
+
+func TestSelectHLSLowestVideoVariant(t *testing.T) {
+	data := []byte(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720
+hd.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=500000,RESOLUTION=426x240
+low.m3u8
+`)
+
+	stream, err := Select(KindHLS, data, mustParse(t, "https://tv.example.com/master.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stream.URL != "https://tv.example.com/low.m3u8" || stream.AudioOnly || len(stream.InputOptions) == 0 {
+		t.Errorf("unexpected stream: %+v", stream)
+	}
+}
+
+func TestSelectHLSAudioRendition(t *testing.T) {
+	data := []byte(`#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Commentary",URI="commentary.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Main",DEFAULT=YES,URI="main.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=900000,RESOLUTION=640x360,AUDIO="aud"
+video.m3u8
+`)
+
+	stream, err := Select(KindHLS, data, mustParse(t, "https://tv.example.com/master.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stream.URL != "https://tv.example.com/main.m3u8" || !stream.AudioOnly {
+		t.Errorf("unexpected stream: %+v", stream)
+	}
+}
+
+func TestParseMedia(t *testing.T) {
+	live := ParseMedia([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,title=\"First\",artist=\"Band\"\na.aac\n#EXTINF:10,title=\"Second, Part 2\",artist=\"Band\"\nb.aac\n"))
+	if live.Title != "Band - Second, Part 2" || live.Duration != 0 {
+		t.Errorf("unexpected live media: %+v", live)
+	}
+
+	vod := ParseMedia([]byte("#EXTM3U\n#EXTINF:10.5,\na.ts\n#EXTINF:9.5,Episode\nb.ts\n#EXT-X-ENDLIST\n"))
+	if vod.Title != "Episode" || vod.Duration != 20*time.Second {
+		t.Errorf("unexpected VOD media: %+v", vod)
+	}
+}
+
+func TestSelectDASH(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT1H2M3.5S">
+  <ProgramInformation><Title>Concert</Title></ProgramInformation>
+  <Period>
+    <AdaptationSet mimeType="video/mp4" codecs="avc1.4d401f">
+      <Representation id="v1" bandwidth="1000000"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4">
+      <Representation id="a1" bandwidth="48000" codecs="mp4a.40.5"/>
+      <Representation id="a2" bandwidth="128000" codecs="mp4a.40.2"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`)
+
+	kind := DetectKind("stream.mpd", "", data)
+	if kind != KindDASH {
+		t.Fatalf("DetectKind = %v, want DASH", kind)
+	}
+
+	stream, err := Select(kind, data, mustParse(t, "https://cdn.example.com/stream.mpd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.Hour + 2*time.Minute + 3500*time.Millisecond
+	if stream.URL != "https://cdn.example.com/stream.mpd" || stream.Map != "0:a:1" || stream.Title != "Concert" || stream.Duration != want {
+		t.Errorf("unexpected stream: %+v", stream)
+	}
+}
+
+func TestDetectKindPlainM3U(t *testing.T) {
+	if kind := DetectKind("list.m3u8", "audio/x-mpegurl", []byte("#EXTM3U\n#EXTINF:1,Song\na.mp3\n")); kind != KindUnknown {
+		t.Errorf("plain M3U detected as %v", kind)
+	}
+}
@@ -10,6 +10,12 @@ type Song struct {
 	Duration  time.Duration // Duration of the song
 	SongID    string        // Unique ID for the song
 	Source    SongSource    // Source type of the song
+	Live      bool          // Live stream without an end, restarted whenever it stops
+
+	InputOptions []string // Extra ffmpeg input options (e.g. for HLS/DASH streams)
+	StreamMap    string   // ffmpeg stream selector, empty for all audio streams
+
+	StartAt time.Duration // Playback start position (e.g. from a YouTube t= parameter)
 }
 
 type Thumbnail struct {
@@ -26,6 +32,9 @@ const (
 	SourceLocalFile
 )
 
+// Sources lists every song source.
+var Sources = []SongSource{SourceYouTube, SourceStream, SourceLocalFile}
+
 func (source SongSource) String() string {
 	sources := map[SongSource]string{
 		SourceYouTube:   "YouTube",
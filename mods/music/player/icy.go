@@ -0,0 +1,60 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/mods/music/history"
+	"github.com/keshon/melodix-player/mods/music/icy"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+// watchStreamTitle follows the ICY metadata of a radio stream until ctx is done,
+// keeping the live title up to date, announcing and recording each new title.
+func (p *Player) watchStreamTitle(ctx context.Context, song *media.Song, announceChannelID string) {
+	userAgent := ""
+	if conf, err := config.NewConfig(); err == nil {
+		userAgent = conf.DcaUserAgent
+	}
+
+	err := icy.NewClient(userAgent).Watch(ctx, song.Filepath, func(title string) {
+		if p.GetLiveTitle() == title {
+			return // stream was restarted
+		}
+
+		slog.Infof("Stream %v is now playing: %v", song.Title, title)
+		p.SetLiveTitle(title)
+
+		historySong := &history.Song{
+			Title:    title,
+			URL:      song.URL,
+			Filepath: song.Filepath,
+			SongID:   fmt.Sprintf("%d", crc32.ChecksumIEEE([]byte(song.Filepath+"\n"+title))),
+			Source:   song.Source.String(),
+		}
+		if err := p.GetHistory().AddTrackToHistory(p.GetGuildID(), historySong); err != nil {
+			slog.Errorf("error adding stream title to history: %v", err)
+		} else if err := p.GetHistory().AddPlaybackCountStats(p.GetGuildID(), historySong.SongID); err != nil {
+			slog.Errorf("error adding playback count stats to history: %v", err)
+		}
+
+		if announceChannelID != "" {
+			live := *song
+			live.Title = title
+			p.announce(announceChannelID, &live)
+		}
+	})
+
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+	case errors.Is(err, icy.ErrNoMetadata):
+		slog.Infof("Stream %v does not provide ICY metadata", song.Title)
+	default:
+		slog.Warnf("Error reading ICY metadata of %v: %v", song.Title, err)
+	}
+}
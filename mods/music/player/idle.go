@@ -0,0 +1,41 @@
+package player
+
+import (
+	"time"
+
+	"github.com/gookit/slog"
+)
+
+// startIdleTimer keeps the voice connection open for timeout after the queue has ended.
+func (p *Player) startIdleTimer(timeout time.Duration) {
+	p.stopIdleTimer()
+
+	slog.Infof("Queue is empty, leaving voice channel in %v unless playback resumes", timeout)
+
+	p.Lock()
+	defer p.Unlock()
+	p.idleTimer = time.AfterFunc(timeout, func() {
+		if p.GetCurrentStatus() == StatusPlaying || p.GetCurrentStatus() == StatusPaused {
+			return
+		}
+
+		if p.GetVoiceConnection() != nil {
+			slog.Info("Idle timeout reached, leaving voice channel")
+			p.GetVoiceConnection().Disconnect()
+		}
+	})
+}
+
+// stopIdleTimer cancels a pending idle disconnect and reports whether one was pending.
+func (p *Player) stopIdleTimer() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.idleTimer == nil {
+		return false
+	}
+
+	pending := p.idleTimer.Stop()
+	p.idleTimer = nil
+	return pending
+}
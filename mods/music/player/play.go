@@ -1,20 +1,27 @@
 package player
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"os/exec"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
+	embed "github.com/Clinet/discordgo-embed"
 	"github.com/bwmarrin/discordgo"
 	"github.com/gookit/slog"
 	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/settings"
 	"github.com/keshon/melodix-player/mods/music/history"
 	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/precode"
 	"github.com/keshon/melodix-player/mods/music/sources"
+	"github.com/keshon/melodix-player/mods/music/storage"
 	"github.com/keshon/melodix-player/mods/music/third_party/dca"
 	"github.com/keshon/melodix-player/mods/music/utils"
 )
@@ -41,6 +48,49 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 	}
 
 	p.SetCurrentSong(currentSong)
+	p.stopIdleTimer()
+
+	// Songs queued from a timestamped URL start at that position
+	if startAt == 0 && currentSong.StartAt > 0 {
+		startAt = int(currentSong.StartAt.Seconds())
+	}
+	if song == nil {
+		p.SetLiveTitle("")
+	}
+
+	// Per-guild settings layered over the global config
+	guildSettings, err := p.GetSettings().Get(p.GetGuildID())
+	if err != nil {
+		return fmt.Errorf("failed to get guild settings: %w", err)
+	}
+
+	// Often played YouTube songs may have been cached locally
+	if p.autoCache != nil {
+		if cached := p.autoCache.Lookup(p.GetCurrentSong()); cached != nil {
+			p.SetCurrentSong(cached)
+		}
+	}
+
+	// Queued YouTube songs may lack a media URL or hold an expired one
+	if sources.NeedsRefresh(p.GetCurrentSong()) {
+		registry := sources.NewRegistry(sources.ProviderOptions{GuildID: p.GetGuildID()})
+		refreshed, err := registry.Refresh(p.GetCurrentSong())
+		if err != nil {
+			return p.skipUnresolved(err, guildSettings)
+		}
+		p.SetCurrentSong(refreshed)
+	}
+
+	// Signed media URLs are handed to the encoder only, never kept in the song
+	input := p.GetCurrentSong()
+	if sources.NeedsSignedURL(input) {
+		registry := sources.NewRegistry(sources.ProviderOptions{GuildID: p.GetGuildID()})
+		signed, err := registry.Refresh(input)
+		if err != nil {
+			return p.skipUnresolved(err, guildSettings)
+		}
+		input = signed
+	}
 
 	// Setup and start encoding
 	options, err := func(startAt int) (*dca.EncodeOptions, error) {
@@ -49,9 +99,9 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 			return nil, fmt.Errorf("error loading config: %w", err)
 		}
 		options := &dca.EncodeOptions{
-			Volume:                  1.0,
+			Volume:                  float32(guildSettings.Volume) / 100,
 			FrameDuration:           config.DcaFrameDuration,
-			Bitrate:                 config.DcaBitrate,
+			Bitrate:                 guildSettings.Bitrate,
 			PacketLoss:              config.DcaPacketLoss,
 			RawOutput:               config.DcaRawOutput,
 			Application:             config.DcaApplication,
@@ -67,6 +117,8 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 			FfmpegBinaryPath:        config.DcaFfmpegBinaryPath,
 			EncodingLineLog:         config.DcaEncodingLineLog,
 			UserAgent:               config.DcaUserAgent,
+			InputOptions:            p.GetCurrentSong().InputOptions,
+			StreamMap:               p.GetCurrentSong().StreamMap,
 		}
 
 		return options, nil
@@ -75,13 +127,23 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 		return fmt.Errorf("failed to create encode options: %w", err)
 	}
 
-	encoding, err := dca.EncodeFile(p.GetCurrentSong().Filepath, options)
-	if err != nil {
-		return fmt.Errorf("failed to encode file: %w", err)
-	}
+	// Cached files pre-encoded at the guild bitrate are streamed without ffmpeg
+	var source dca.OpusReader
+	if precoded := p.openPrecoded(startAt, guildSettings.Volume, guildSettings.Bitrate); precoded != nil {
+		slog.Info("Playing pre-encoded file", p.GetCurrentSong().Filepath)
+		defer precoded.Close()
+		p.SetEncodingSession(nil)
+		source = precoded
+	} else {
+		encoding, err := dca.EncodeFile(mediaInput(input), options)
+		if err != nil {
+			return fmt.Errorf("failed to encode file: %w", err)
+		}
 
-	p.SetEncodingSession(encoding)
-	defer p.GetEncodingSession().Cleanup()
+		p.SetEncodingSession(encoding)
+		defer p.GetEncodingSession().Cleanup()
+		source = encoding
+	}
 
 	// Set up voice connection for sending audio
 	voiceConnection, err := p.setupVoiceConnection()
@@ -94,7 +156,7 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 
 	// Send encoding stream to voice connection
 	done := make(chan error, 1)
-	stream := dca.NewStream(p.GetEncodingSession(), p.GetVoiceConnection(), done)
+	stream := dca.NewStream(source, p.GetVoiceConnection(), done)
 	p.SetStreamingSession(stream)
 	p.SetCurrentStatus(StatusPlaying)
 
@@ -112,6 +174,20 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 
 	if err := p.GetHistory().AddPlaybackCountStats(p.GetVoiceConnection().GuildID, p.GetCurrentSong().SongID); err != nil {
 		slog.Errorf("error adding playback count stats to history: %v", err)
+	} else if p.autoCache != nil {
+		p.autoCache.Played(p.GetCurrentSong())
+	}
+
+	// Announce tracks started from the queue (restarts pass the song explicitly)
+	if song == nil && guildSettings.AnnounceChannelID != "" {
+		p.announce(guildSettings.AnnounceChannelID, p.GetCurrentSong())
+	}
+
+	// Follow live titles of radio streams
+	if p.GetCurrentSong().Live {
+		metadataCtx, stopMetadata := context.WithCancel(context.Background())
+		defer stopMetadata()
+		go p.watchStreamTitle(metadataCtx, p.GetCurrentSong(), guildSettings.AnnounceChannelID)
 	}
 
 	// Set up periodic playback duration stats update to history
@@ -193,14 +269,14 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 					slog.Warnf("Unexpected interruption confirmed, restarting song: \"%v\" from %vs", p.GetCurrentSong().Title, int(startAt))
 
 					go func() {
-						yt := sources.NewYoutube()
-						song, err = yt.FetchOneByURL(p.GetCurrentSong().URL)
+						registry := sources.NewRegistry(sources.ProviderOptions{GuildID: p.GetGuildID()})
+						song, err = registry.Refresh(p.GetCurrentSong())
 						if err != nil {
-							slog.Errorf("error fetching new song: %w", err)
+							slog.Errorf("error fetching new song: %v", err)
+						} else {
+							p.SetCurrentSong(song)
 						}
 
-						p.SetCurrentSong(song)
-
 						err := p.Play(int(startAt), p.GetCurrentSong())
 						if err != nil {
 							slog.Errorf("error restarting song: %w", err)
@@ -210,8 +286,8 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 					return nil
 				}
 				// fallthrough
-			case p.GetCurrentSong().Source == media.SourceStream:
-				slog.Info("Source is a stream, should always restart (unless manually interrupted)")
+			case p.GetCurrentSong().Live:
+				slog.Info("Source is a live stream, should always restart (unless manually interrupted)")
 				p.GetVoiceConnection().Speaking(false)
 				slog.Infof("Restarting stream %v", p.GetCurrentSong().Title)
 
@@ -224,6 +300,23 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 
 				return nil
 
+			case p.GetCurrentSong().Source == media.SourceLocalFile && p.GetEncodingSession() == nil:
+				slog.Info("Source is a pre-encoded file, restarting unless it reached its end")
+				if errDone != nil && errDone != io.EOF && p.GetStreamingSession() != nil {
+					startAt := startAt + int(p.GetStreamingSession().PlaybackPosition().Seconds())
+					p.GetVoiceConnection().Speaking(false)
+					slog.Warnf("Unexpected interruption confirmed, restarting song: \"%v\" from %vs", p.GetCurrentSong().Title, startAt)
+
+					go func() {
+						err := p.Play(startAt, p.GetCurrentSong())
+						if err != nil {
+							slog.Errorf("error restarting song: %w", err)
+						}
+					}()
+
+					return nil
+				}
+				// fallthrough
 			case p.GetCurrentSong().Source == media.SourceLocalFile:
 				slog.Info("Source is a local file, checking for song metrics if unexpected interruption")
 				songDuration, songPosition, err := p.calculateSongMetrics(p.GetEncodingSession(), p.GetStreamingSession(), p.GetCurrentSong())
@@ -263,7 +356,11 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 
 			if p.GetVoiceConnection() != nil {
 				p.GetVoiceConnection().Speaking(false)
-				p.GetVoiceConnection().Disconnect()
+				if guildSettings.IdleTimeout > 0 {
+					p.startIdleTimer(guildSettings.IdleTimeout)
+				} else {
+					p.GetVoiceConnection().Disconnect()
+				}
 			}
 
 			p.SetStreamingSession(nil)
@@ -271,6 +368,7 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 			p.SetCurrentStatus(StatusResting)
 			p.SetSongQueue(make([]*media.Song, 0))
 			p.SetCurrentSong(nil)
+			p.SetLiveTitle("")
 			p.SkipInterrupt = make(chan bool, 1)
 			p.StopInterrupt = make(chan bool, 1)
 			p.SwitchChannelInterrupt = make(chan bool, 1)
@@ -315,6 +413,7 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 		p.SetCurrentStatus(StatusResting)
 		p.SetSongQueue(make([]*media.Song, 0))
 		p.SetCurrentSong(nil)
+		p.SetLiveTitle("")
 		p.SkipInterrupt = make(chan bool, 1)
 		p.StopInterrupt = make(chan bool, 1)
 		p.SwitchChannelInterrupt = make(chan bool, 1)
@@ -342,6 +441,99 @@ func (p *Player) Play(startAt int, song *media.Song) error {
 
 }
 
+// openPrecoded returns the pre-encoded local file of the current song, or nil when
+// it has to be encoded by ffmpeg. Such files are at full volume, cached files
+// missing one are encoded in the background for the next play.
+func (p *Player) openPrecoded(startAt int, volume int, bitrate int) *precode.Reader {
+	song := p.GetCurrentSong()
+	if song.Source != media.SourceLocalFile || volume != 100 || len(song.InputOptions) > 0 || song.StreamMap != "" {
+		return nil
+	}
+
+	precoder := precode.NewPrecoder(sources.CacheFolder())
+	reader, err := precoder.Open(song.Filepath, bitrate, time.Duration(startAt)*time.Second)
+	switch {
+	case err == nil:
+		return reader
+	case errors.Is(err, precode.ErrNotPrecoded):
+		precoder.EncodeAsync(song.Filepath)
+	case !errors.Is(err, precode.ErrBitrate):
+		slog.Warnf("Error opening pre-encoded file: %v", err)
+	}
+	return nil
+}
+
+// mediaInput returns what ffmpeg reads the song from, cached files kept in a
+// bucket are read from a presigned URL.
+func mediaInput(song *media.Song) string {
+	if song.Source != media.SourceLocalFile {
+		return song.Filepath
+	}
+
+	store := storage.NewStorage(sources.CacheFolder())
+	if store.IsLocal() {
+		return song.Filepath
+	}
+
+	// Library files are outside of the cache folder
+	location, err := store.Location(context.Background(), song.Filepath)
+	if err != nil {
+		return song.Filepath
+	}
+	return location
+}
+
+func (p *Player) announce(channelID string, song *media.Song) {
+	if song == nil || p.GetDiscordSession() == nil {
+		return
+	}
+
+	description := fmt.Sprintf("▶️ Now playing\n\n**`%v`**\n%v", strings.ToLower(song.Source.String()), song.Title)
+	if song.URL != "" {
+		description = fmt.Sprintf("▶️ Now playing\n\n**`%v`**\n[%v](%v)", strings.ToLower(song.Source.String()), song.Title, song.URL)
+	}
+
+	embedMsg := embed.NewEmbed().
+		SetDescription(description).
+		SetThumbnail(song.Thumbnail.URL).
+		SetColor(0x9f00d4).MessageEmbed
+
+	if _, err := p.GetDiscordSession().ChannelMessageSendEmbed(channelID, embedMsg); err != nil {
+		slog.Errorf("Error sending announcement to channel %v: %v", channelID, err)
+	}
+}
+
+// skipUnresolved moves on to the next queued song when the media URL of the
+// current one can't be resolved, e.g. for removed or region blocked videos.
+func (p *Player) skipUnresolved(err error, guildSettings *settings.GuildSettings) error {
+	song := p.GetCurrentSong()
+	slog.Errorf("Skipping %q, failed to resolve media URL: %v", song.Title, err)
+
+	if guildSettings.AnnounceChannelID != "" && p.GetDiscordSession() != nil {
+		embedMsg := embed.NewEmbed().
+			SetDescription(fmt.Sprintf("⏭ Skipped\n\n%v\nIt could not be resolved, the video may be removed or blocked.", song.Title)).
+			SetColor(0x9f00d4).MessageEmbed
+		if _, err := p.GetDiscordSession().ChannelMessageSendEmbed(guildSettings.AnnounceChannelID, embedMsg); err != nil {
+			slog.Errorf("Error sending announcement to channel %v: %v", guildSettings.AnnounceChannelID, err)
+		}
+	}
+
+	if len(p.GetSongQueue()) > 0 {
+		return p.Play(0, nil)
+	}
+
+	p.SetCurrentSong(nil)
+	p.SetCurrentStatus(StatusResting)
+	if p.GetVoiceConnection() != nil {
+		if guildSettings.IdleTimeout > 0 {
+			p.startIdleTimer(guildSettings.IdleTimeout)
+		} else {
+			p.GetVoiceConnection().Disconnect()
+		}
+	}
+	return nil
+}
+
 func (p *Player) setupVoiceConnection() (*discordgo.VoiceConnection, error) {
 	// Helpful: https://github.com/bwmarrin/discordgo/issues/1357
 	session := p.GetDiscordSession()
@@ -397,7 +589,7 @@ func (p *Player) calculateSongMetrics(encodingSession *dca.EncodeSession, stream
 			return 0, 0, fmt.Errorf("failed to parse duration: %v", err)
 		}
 	case media.SourceLocalFile:
-		dur, err = getMP3Duration(song.Filepath)
+		dur, err = getMP3Duration(mediaInput(song))
 		if err != nil {
 			return 0, 0, fmt.Errorf("failed to parse duration: %v", err)
 		}
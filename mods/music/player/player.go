@@ -3,9 +3,12 @@ package player
 
 import (
 	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 
+	"github.com/keshon/melodix-player/internal/settings"
+	"github.com/keshon/melodix-player/mods/music/cache"
 	"github.com/keshon/melodix-player/mods/music/history"
 	"github.com/keshon/melodix-player/mods/music/media"
 	"github.com/keshon/melodix-player/mods/music/third_party/dca"
@@ -32,6 +35,8 @@ type IPlayer interface {
 	GetStreamingSession() *dca.StreamingSession
 	GetCurrentSong() *media.Song
 	SetCurrentSong(song *media.Song)
+	GetLiveTitle() string
+	SetLiveTitle(title string)
 	GetChannelID() string
 	SetChannelID(channelID string)
 	GetDiscordSession() *discordgo.Session
@@ -46,12 +51,16 @@ type Player struct {
 	stream                 *dca.StreamingSession
 	encoding               *dca.EncodeSession
 	song                   *media.Song
+	liveTitle              string
 	queue                  []*media.Song
 	status                 PlaybackStatus
 	channelID              string
 	guildID                string
 	session                *discordgo.Session
 	history                history.IHistory
+	settings               settings.ISettings
+	autoCache              cache.IAutoCache
+	idleTimer              *time.Timer
 	SkipInterrupt          chan bool
 	StopInterrupt          chan bool
 	SwitchChannelInterrupt chan bool
@@ -87,7 +96,7 @@ func (status PlaybackStatus) StringEmoji() string {
 	return statuses[status]
 }
 
-func NewPlayer(guildID string, session *discordgo.Session) IPlayer {
+func NewPlayer(guildID string, session *discordgo.Session, history history.IHistory, settings settings.ISettings, autoCache cache.IAutoCache) IPlayer {
 	return &Player{
 		vc:                     nil,
 		stream:                 nil,
@@ -97,7 +106,9 @@ func NewPlayer(guildID string, session *discordgo.Session) IPlayer {
 		status:                 StatusResting,
 		guildID:                guildID,
 		session:                session,
-		history:                history.NewHistory(),
+		history:                history,
+		settings:               settings,
+		autoCache:              autoCache,
 		SkipInterrupt:          make(chan bool, 1),
 		StopInterrupt:          make(chan bool, 1),
 		SwitchChannelInterrupt: make(chan bool, 1),
@@ -150,6 +161,19 @@ func (p *Player) SetCurrentSong(song *media.Song) {
 	p.song = song
 }
 
+// GetLiveTitle returns the title currently announced by a radio stream via ICY metadata.
+func (p *Player) GetLiveTitle() string {
+	p.Lock()
+	defer p.Unlock()
+	return p.liveTitle
+}
+
+func (p *Player) SetLiveTitle(title string) {
+	p.Lock()
+	defer p.Unlock()
+	p.liveTitle = title
+}
+
 func (p *Player) GetEncodingSession() *dca.EncodeSession {
 	return p.encoding
 }
@@ -197,3 +221,7 @@ func (p *Player) SetGuildID(guildID string) {
 func (p *Player) GetHistory() history.IHistory {
 	return p.history
 }
+
+func (p *Player) GetSettings() settings.ISettings {
+	return p.settings
+}
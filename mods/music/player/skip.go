@@ -5,8 +5,6 @@ import (
 	"time"
 
 	"github.com/gookit/slog"
-
-	"github.com/keshon/melodix-player/mods/music/history"
 )
 
 func (p *Player) Skip() error {
@@ -20,7 +18,7 @@ func (p *Player) Skip() error {
 		return errors.New("current song is missing")
 	}
 
-	h := history.NewHistory()
+	h := p.GetHistory()
 
 	if len(p.GetSongQueue()) == 0 {
 		slog.Warn("is actually stopping...")
@@ -13,6 +13,12 @@ func (p *Player) Stop() error {
 		return fmt.Errorf("voice connection is not initialized")
 	}
 
+	// Nothing is streaming while waiting out the idle timeout, so leave right away
+	if p.stopIdleTimer() {
+		p.GetVoiceConnection().Disconnect()
+		return nil
+	}
+
 	// if p.GetCurrentSong() == nil {
 	// 	return fmt.Errorf("current song is missing")
 	// }
@@ -0,0 +1,305 @@
+// Package playlist parses M3U/M3U8, PLS and XSPF playlists into entries.
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatM3U
+	FormatPLS
+	FormatXSPF
+)
+
+func (f Format) String() string {
+	formats := map[Format]string{
+		FormatUnknown: "unknown",
+		FormatM3U:     "M3U",
+		FormatPLS:     "PLS",
+		FormatXSPF:    "XSPF",
+	}
+
+	return formats[f]
+}
+
+// Entry is a single item of a playlist. Duration is -1 when unknown or live,
+// Live is only set for entries marked as live streams (#EXTINF:-1, Length=-1).
+type Entry struct {
+	Location string
+	Title    string
+	Duration time.Duration
+	Live     bool
+}
+
+// ErrHLS is returned for M3U8 files describing an HLS stream rather than a list of tracks.
+var ErrHLS = errors.New("playlist is an HLS stream")
+
+var extensions = map[string]Format{
+	".m3u":  FormatM3U,
+	".m3u8": FormatM3U,
+	".pls":  FormatPLS,
+	".xspf": FormatXSPF,
+}
+
+var contentTypes = map[string]Format{
+	"audio/x-mpegurl":               FormatM3U,
+	"audio/mpegurl":                 FormatM3U,
+	"application/x-mpegurl":         FormatM3U,
+	"application/vnd.apple.mpegurl": FormatM3U,
+	"audio/x-scpls":                 FormatPLS,
+	"application/pls+xml":           FormatPLS,
+	"application/xspf+xml":          FormatXSPF,
+}
+
+// IsPlaylistFile reports whether name (a file name or URL path) has a playlist extension.
+func IsPlaylistFile(name string) bool {
+	_, ok := extensions[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// IsPlaylistContentType reports whether contentType denotes a playlist.
+func IsPlaylistContentType(contentType string) bool {
+	return formatFromContentType(contentType) != FormatUnknown
+}
+
+// DetectFormat guesses the playlist format from the content type, the file
+// name and finally the content itself.
+func DetectFormat(name, contentType string, data []byte) Format {
+	if f := formatFromContentType(contentType); f != FormatUnknown {
+		return f
+	}
+	if f, ok := extensions[strings.ToLower(path.Ext(name))]; ok {
+		return f
+	}
+
+	head := strings.ToLower(strings.TrimSpace(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))))
+	switch {
+	case strings.HasPrefix(head, "#extm3u"):
+		return FormatM3U
+	case strings.HasPrefix(head, "[playlist]"):
+		return FormatPLS
+	case strings.Contains(head, "xspf.org/ns/0"):
+		return FormatXSPF
+	}
+
+	return FormatUnknown
+}
+
+func formatFromContentType(contentType string) Format {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return FormatUnknown
+	}
+	return contentTypes[strings.ToLower(mediaType)]
+}
+
+// Parse decodes a playlist of the given format.
+func Parse(data []byte, format Format) ([]Entry, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var entries []Entry
+	var err error
+
+	switch format {
+	case FormatM3U:
+		entries, err = parseM3U(data)
+	case FormatPLS:
+		entries, err = parsePLS(data)
+	case FormatXSPF:
+		entries, err = parseXSPF(data)
+	default:
+		return nil, fmt.Errorf("unsupported playlist format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%v playlist is empty", format)
+	}
+
+	return entries, nil
+}
+
+func parseM3U(data []byte) ([]Entry, error) {
+	var entries []Entry
+	pending := Entry{Duration: -1}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-"):
+			return nil, ErrHLS
+		case strings.HasPrefix(line, "#EXTINF:"):
+			pending = parseExtInf(strings.TrimPrefix(line, "#EXTINF:"))
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			pending.Location = line
+			entries = append(entries, pending)
+			pending = Entry{Duration: -1}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading M3U playlist: %v", err)
+	}
+
+	return entries, nil
+}
+
+// parseExtInf reads `<duration> [attributes],<title>` of an #EXTINF line.
+func parseExtInf(info string) Entry {
+	entry := Entry{Duration: -1}
+
+	meta, title, _ := strings.Cut(info, ",")
+	entry.Title = strings.TrimSpace(title)
+
+	fields := strings.Fields(meta)
+	if len(fields) > 0 {
+		entry.Duration = seconds(fields[0])
+		entry.Live = isLive(fields[0])
+	}
+
+	return entry
+}
+
+func parsePLS(data []byte) ([]Entry, error) {
+	byIndex := make(map[int]*Entry)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		var name string
+		for _, prefix := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, prefix) {
+				name = prefix
+				break
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		index, err := strconv.Atoi(strings.TrimPrefix(key, name))
+		if err != nil {
+			continue
+		}
+
+		entry, ok := byIndex[index]
+		if !ok {
+			entry = &Entry{Duration: -1}
+			byIndex[index] = entry
+		}
+
+		switch name {
+		case "file":
+			entry.Location = value
+		case "title":
+			entry.Title = value
+		case "length":
+			entry.Duration = seconds(value)
+			entry.Live = isLive(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading PLS playlist: %v", err)
+	}
+
+	indexes := make([]int, 0, len(byIndex))
+	for index := range byIndex {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var entries []Entry
+	for _, index := range indexes {
+		if byIndex[index].Location != "" {
+			entries = append(entries, *byIndex[index])
+		}
+	}
+
+	return entries, nil
+}
+
+type xspfPlaylist struct {
+	Tracks []struct {
+		Location []string `xml:"location"`
+		Title    string   `xml:"title"`
+		Creator  string   `xml:"creator"`
+		Duration string   `xml:"duration"` // milliseconds
+	} `xml:"trackList>track"`
+}
+
+func parseXSPF(data []byte) ([]Entry, error) {
+	var playlist xspfPlaylist
+	if err := xml.Unmarshal(data, &playlist); err != nil {
+		return nil, fmt.Errorf("error reading XSPF playlist: %v", err)
+	}
+
+	var entries []Entry
+	for _, track := range playlist.Tracks {
+		if len(track.Location) == 0 || strings.TrimSpace(track.Location[0]) == "" {
+			continue
+		}
+
+		entry := Entry{
+			Location: strings.TrimSpace(track.Location[0]),
+			Title:    strings.TrimSpace(track.Title),
+			Duration: -1,
+		}
+		if creator := strings.TrimSpace(track.Creator); creator != "" && entry.Title != "" {
+			entry.Title = creator + " - " + entry.Title
+		}
+		if ms, err := strconv.ParseInt(strings.TrimSpace(track.Duration), 10, 64); err == nil && ms > 0 {
+			entry.Duration = time.Duration(ms) * time.Millisecond
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// isLive tells whether a playlist duration marks a live stream, which negative
+// values do.
+func isLive(value string) bool {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return err == nil && f < 0
+}
+
+// seconds parses a playlist duration in seconds, returning -1 for live streams
+// and unknown values.
+func seconds(value string) time.Duration {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || f <= 0 {
+		return -1
+	}
+	return time.Duration(f * float64(time.Second))
+}
@@ -0,0 +1,107 @@
+package playlist
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseM3U(t *testing.T) {
+	data := []byte("\xef\xbb\xbf#EXTM3U\n" +
+		"#EXTINF:123 tvg-id=\"x\",Artist - Song\n" +
+		"http://example.com/song.mp3\n" +
+		"\n" +
+		"# comment\n" +
+		"#EXTINF:-1,Radio\n" +
+		"http://example.com/live\n" +
+		"relative.ogg\n")
+
+	entries, err := Parse(data, DetectFormat("list.m3u8", "", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Entry{
+		{Location: "http://example.com/song.mp3", Title: "Artist - Song", Duration: 123 * time.Second},
+		{Location: "http://example.com/live", Title: "Radio", Duration: -1, Live: true},
+		{Location: "relative.ogg", Duration: -1},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+}
+
+func TestParseM3UHLS(t *testing.T) {
+	data := []byte("#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nsegment0.ts\n")
+
+	if _, err := Parse(data, FormatM3U); !errors.Is(err, ErrHLS) {
+		t.Errorf("expected ErrHLS, got %v", err)
+	}
+}
+
+func TestParsePLS(t *testing.T) {
+	data := []byte("[playlist]\n" +
+		"NumberOfEntries=2\n" +
+		"File2=http://example.com/b\n" +
+		"Title2=Second\n" +
+		"File1=http://example.com/a\n" +
+		"Title1=First\n" +
+		"Length1=60\n" +
+		"Length2=-1\n" +
+		"Version=2\n")
+
+	entries, err := Parse(data, DetectFormat("", "audio/x-scpls; charset=utf-8", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 ||
+		entries[0] != (Entry{Location: "http://example.com/a", Title: "First", Duration: time.Minute}) ||
+		entries[1] != (Entry{Location: "http://example.com/b", Title: "Second", Duration: -1, Live: true}) {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestParseXSPF(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <trackList>
+    <track>
+      <location>http://example.com/a.mp3</location>
+      <title>Song</title>
+      <creator>Artist</creator>
+      <duration>215000</duration>
+    </track>
+    <track>
+      <title>No location</title>
+    </track>
+  </trackList>
+</playlist>`)
+
+	if f := DetectFormat("", "", data); f != FormatXSPF {
+		t.Fatalf("DetectFormat = %v, want XSPF", f)
+	}
+
+	entries, err := Parse(data, FormatXSPF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0] != (Entry{Location: "http://example.com/a.mp3", Title: "Artist - Song", Duration: 215 * time.Second}) {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	if _, err := Parse([]byte("#EXTM3U\n"), FormatM3U); err == nil {
+		t.Error("expected error for empty playlist")
+	}
+	if _, err := Parse([]byte("whatever"), FormatUnknown); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
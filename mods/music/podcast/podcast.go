@@ -0,0 +1,287 @@
+// Package podcast reads podcast RSS and Atom feeds.
+package podcast
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxFeedSize = 10 << 20
+
+// ErrNotFeed is returned for documents that are neither RSS nor Atom.
+var ErrNotFeed = errors.New("not a podcast feed")
+
+type Feed struct {
+	Title    string
+	ImageURL string
+	Episodes []Episode // newest first
+}
+
+type Episode struct {
+	GUID      string
+	Title     string
+	URL       string // enclosure
+	Duration  time.Duration
+	ImageURL  string
+	Published time.Time
+}
+
+// Client fetches feeds over HTTP.
+type Client struct {
+	HTTPClient *http.Client
+	UserAgent  string
+}
+
+func NewClient(userAgent string) *Client {
+	return &Client{HTTPClient: http.DefaultClient, UserAgent: userAgent}
+}
+
+func (c *Client) Fetch(ctx context.Context, feedURL string) (*Feed, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching feed: HTTP status %v", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading feed: %v", err)
+	}
+
+	return Parse(data)
+}
+
+// IsFeed tells RSS and Atom documents apart by content type or by their root element.
+func IsFeed(contentType string, head []byte) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.Contains(contentType, "rss+xml") || strings.Contains(contentType, "atom+xml") {
+		return true
+	}
+	if strings.HasPrefix(contentType, "audio/") || strings.HasPrefix(contentType, "video/") {
+		return false
+	}
+
+	head = bytes.ToLower(head)
+	return bytes.Contains(head, []byte("<rss")) || bytes.Contains(head, []byte("<feed"))
+}
+
+// rssImage is either a channel <image><url>..</url></image> or an <itunes:image href=".."/>.
+type rssImage struct {
+	URL  string `xml:"url"`
+	Href string `xml:"href,attr"`
+}
+
+func imageURL(images []rssImage) string {
+	for _, image := range images {
+		if url := firstNonEmpty(image.Href, image.URL); url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+type rssDocument struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel struct {
+		Title  string     `xml:"title"`
+		Images []rssImage `xml:"image"`
+		Items  []struct {
+			Title     string `xml:"title"`
+			GUID      string `xml:"guid"`
+			PubDate   string `xml:"pubDate"`
+			Enclosure struct {
+				URL  string `xml:"url,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"enclosure"`
+			Duration string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+			Images   []rssImage `xml:"image"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomDocument struct {
+	XMLName xml.Name   `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string     `xml:"title"`
+	Logo    string     `xml:"logo"`
+	Icon    string     `xml:"icon"`
+	Images  []rssImage `xml:"image"`
+	Entries []struct {
+		ID        string     `xml:"id"`
+		Title     string     `xml:"title"`
+		Published string     `xml:"published"`
+		Updated   string     `xml:"updated"`
+		Links     []atomLink `xml:"link"`
+		Duration  string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+		Images    []rssImage `xml:"image"`
+	} `xml:"entry"`
+}
+
+// Parse reads an RSS 2.0 or Atom feed. Entries without an audio enclosure are left out.
+func Parse(data []byte) (*Feed, error) {
+	var rss rssDocument
+	if err := xml.Unmarshal(data, &rss); err == nil {
+		return parseRSS(&rss), nil
+	}
+
+	var atom atomDocument
+	if err := xml.Unmarshal(data, &atom); err == nil {
+		return parseAtom(&atom), nil
+	}
+
+	return nil, ErrNotFeed
+}
+
+func parseRSS(doc *rssDocument) *Feed {
+	feed := &Feed{
+		Title:    strings.TrimSpace(doc.Channel.Title),
+		ImageURL: imageURL(doc.Channel.Images),
+	}
+
+	for _, item := range doc.Channel.Items {
+		if item.Enclosure.URL == "" {
+			continue
+		}
+
+		feed.Episodes = append(feed.Episodes, Episode{
+			GUID:      firstNonEmpty(strings.TrimSpace(item.GUID), item.Enclosure.URL),
+			Title:     strings.TrimSpace(item.Title),
+			URL:       strings.TrimSpace(item.Enclosure.URL),
+			Duration:  ParseDuration(item.Duration),
+			ImageURL:  firstNonEmpty(imageURL(item.Images), feed.ImageURL),
+			Published: parseDate(item.PubDate),
+		})
+	}
+
+	sortEpisodes(feed.Episodes)
+	return feed
+}
+
+func parseAtom(doc *atomDocument) *Feed {
+	feed := &Feed{
+		Title:    strings.TrimSpace(doc.Title),
+		ImageURL: firstNonEmpty(imageURL(doc.Images), doc.Logo, doc.Icon),
+	}
+
+	for _, entry := range doc.Entries {
+		var enclosure string
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" {
+				enclosure = strings.TrimSpace(link.Href)
+				break
+			}
+		}
+		if enclosure == "" {
+			continue
+		}
+
+		feed.Episodes = append(feed.Episodes, Episode{
+			GUID:      firstNonEmpty(strings.TrimSpace(entry.ID), enclosure),
+			Title:     strings.TrimSpace(entry.Title),
+			URL:       enclosure,
+			Duration:  ParseDuration(entry.Duration),
+			ImageURL:  firstNonEmpty(imageURL(entry.Images), feed.ImageURL),
+			Published: parseDate(firstNonEmpty(entry.Published, entry.Updated)),
+		})
+	}
+
+	sortEpisodes(feed.Episodes)
+	return feed
+}
+
+// sortEpisodes puts the newest episodes first, keeping feed order for equal dates.
+func sortEpisodes(episodes []Episode) {
+	sort.SliceStable(episodes, func(i, j int) bool {
+		return episodes[i].Published.After(episodes[j].Published)
+	})
+}
+
+// ParseDuration reads itunes:duration values given as seconds, `mm:ss` or `hh:mm:ss`.
+func ParseDuration(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	var total float64
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		total = total*60 + n
+	}
+
+	return time.Duration(total * float64(time.Second))
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// NewEpisodes returns the episodes published after the one identified by
+// latestGUID, or after latestPublished when that episode left the feed.
+func NewEpisodes(feed *Feed, latestGUID string, latestPublished time.Time) []Episode {
+	for i, episode := range feed.Episodes {
+		if episode.GUID == latestGUID {
+			return feed.Episodes[:i]
+		}
+	}
+
+	var episodes []Episode
+	for _, episode := range feed.Episodes {
+		if episode.Published.After(latestPublished) {
+			episodes = append(episodes, episode)
+		}
+	}
+	return episodes
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package podcast
+
+import (
+	"testing"
+	"time"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title>Go Time</title>
+    <itunes:image href="https://example.com/cover.jpg"/>
+    <item>
+      <title>Episode 1</title>
+      <guid>ep-1</guid>
+      <pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate>
+      <enclosure url="https://example.com/1.mp3" type="audio/mpeg" length="1"/>
+      <itunes:duration>45:30</itunes:duration>
+    </item>
+    <item>
+      <title>Episode 2</title>
+      <guid>ep-2</guid>
+      <pubDate>Mon, 09 Jan 2006 15:04:05 +0000</pubDate>
+      <enclosure url="https://example.com/2.mp3" type="audio/mpeg" length="1"/>
+      <itunes:duration>3723</itunes:duration>
+      <itunes:image href="https://example.com/2.jpg"/>
+    </item>
+    <item>
+      <title>Show notes only</title>
+    </item>
+  </channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom Cast</title>
+  <logo>https://example.com/logo.png</logo>
+  <entry>
+    <id>urn:1</id>
+    <title>First</title>
+    <published>2024-03-01T10:00:00Z</published>
+    <link rel="alternate" href="https://example.com/first"/>
+    <link rel="enclosure" href="https://example.com/first.ogg" type="audio/ogg"/>
+  </entry>
+</feed>`
+
+func TestParseRSS(t *testing.T) {
+	feed, err := Parse([]byte(rssFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.Title != "Go Time" || feed.ImageURL != "https://example.com/cover.jpg" || len(feed.Episodes) != 2 {
+		t.Fatalf("unexpected feed: %+v", feed)
+	}
+
+	latest := feed.Episodes[0]
+	if latest.GUID != "ep-2" || latest.URL != "https://example.com/2.mp3" || latest.Duration != time.Hour+2*time.Minute+3*time.Second || latest.ImageURL != "https://example.com/2.jpg" {
+		t.Errorf("unexpected latest episode: %+v", latest)
+	}
+	if older := feed.Episodes[1]; older.Duration != 45*time.Minute+30*time.Second || older.ImageURL != feed.ImageURL {
+		t.Errorf("unexpected older episode: %+v", older)
+	}
+
+	if got := NewEpisodes(feed, "ep-1", time.Time{}); len(got) != 1 || got[0].GUID != "ep-2" {
+		t.Errorf("unexpected new episodes: %+v", got)
+	}
+	if got := NewEpisodes(feed, "gone", feed.Episodes[1].Published); len(got) != 1 || got[0].GUID != "ep-2" {
+		t.Errorf("unexpected new episodes by date: %+v", got)
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	feed, err := Parse([]byte(atomFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.Title != "Atom Cast" || len(feed.Episodes) != 1 {
+		t.Fatalf("unexpected feed: %+v", feed)
+	}
+	if episode := feed.Episodes[0]; episode.URL != "https://example.com/first.ogg" || episode.ImageURL != "https://example.com/logo.png" || episode.Published.Year() != 2024 {
+		t.Errorf("unexpected episode: %+v", episode)
+	}
+
+	if _, err := Parse([]byte("<html></html>")); err != ErrNotFeed {
+		t.Errorf("expected ErrNotFeed, got %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+// Package precode keeps cached tracks transcoded to DCA files, so playing them
+// streams stored opus frames instead of running ffmpeg every time.
+package precode
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/mods/music/storage"
+	"github.com/keshon/melodix-player/mods/music/third_party/dca"
+)
+
+var (
+	// ErrNotPrecoded is returned by Open when there is no up to date DCA file to play.
+	ErrNotPrecoded = errors.New("track is not pre-encoded")
+	// ErrBitrate is returned by Open for bitrates other than the configured one.
+	ErrBitrate = errors.New("tracks are pre-encoded at another bitrate")
+)
+
+var encoding sync.Map // sources being encoded
+
+type IPrecoder interface {
+	Open(source string, bitrate int, startAt time.Duration) (*Reader, error)
+	Encode(source string) error
+	EncodeAsync(source string)
+	Remove(source string) error
+	Eligible(source string) bool
+}
+
+// Precoder transcodes files of the cache folder with the configured bitrate and
+// frame duration, at full volume.
+type Precoder struct {
+	enabled     bool
+	dir         string
+	cacheFolder string
+	options     dca.EncodeOptions
+}
+
+func NewPrecoder(cacheFolder string) IPrecoder {
+	conf, err := config.NewConfig()
+	if err != nil {
+		slog.Errorf("Error loading config, pre-encoding is disabled: %v", err)
+		return &Precoder{}
+	}
+
+	options := *dca.StdEncodeOptions
+	options.FrameDuration = conf.DcaFrameDuration
+	options.Bitrate = conf.DcaBitrate
+	options.PacketLoss = conf.DcaPacketLoss
+	options.Application = conf.DcaApplication
+	options.CompressionLevel = conf.DcaCompressionLevel
+	options.BufferedFrames = conf.DcaBufferedFrames
+	options.VBR = conf.DcaVBR
+	options.FfmpegBinaryPath = conf.DcaFfmpegBinaryPath
+
+	// Files kept in a bucket are not on the local disk to be encoded
+	enabled := conf.PrecodeEnabled && conf.StorageBackend == storage.BackendLocal
+
+	return newPrecoder(enabled, conf.PrecodeDir, cacheFolder, options)
+}
+
+func newPrecoder(enabled bool, dir, cacheFolder string, options dca.EncodeOptions) *Precoder {
+	options.Volume = 1.0
+	options.RawOutput = false
+	options.StartTime = 0
+
+	return &Precoder{
+		enabled:     enabled,
+		dir:         dir,
+		cacheFolder: cacheFolder,
+		options:     options,
+	}
+}
+
+// Eligible tells whether the source is a file of the cache folder worth pre-encoding.
+func (p *Precoder) Eligible(source string) bool {
+	if !p.enabled || source == "" {
+		return false
+	}
+
+	cacheFolder, err := filepath.Abs(p.cacheFolder)
+	if err != nil {
+		return false
+	}
+	path, err := filepath.Abs(source)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(cacheFolder, path)
+	return err == nil && rel != "." && !strings.HasPrefix(rel, "..")
+}
+
+// Open returns a reader of the pre-encoded source positioned at startAt. The file
+// must be encoded with the given bitrate and be newer than the source.
+func (p *Precoder) Open(source string, bitrate int, startAt time.Duration) (*Reader, error) {
+	if !p.Eligible(source) {
+		return nil, ErrNotPrecoded
+	}
+	if bitrate != p.options.Bitrate {
+		return nil, ErrBitrate
+	}
+
+	path := p.path(source)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, ErrNotPrecoded
+	}
+	sourceInfo, err := os.Stat(source)
+	if err != nil || sourceInfo.ModTime().After(info.ModTime()) {
+		return nil, ErrNotPrecoded
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening pre-encoded file: %v", err)
+	}
+
+	reader := NewReader(file, time.Duration(p.options.FrameDuration)*time.Millisecond)
+	if startAt > 0 {
+		if err := reader.Skip(int(startAt / reader.FrameDuration())); err != nil && !errors.Is(err, io.EOF) {
+			reader.Close()
+			return nil, fmt.Errorf("error seeking pre-encoded file: %v", err)
+		}
+	}
+
+	return reader, nil
+}
+
+// Encode transcodes the source unless an up to date DCA file already exists.
+func (p *Precoder) Encode(source string) error {
+	if !p.Eligible(source) {
+		return ErrNotPrecoded
+	}
+
+	if _, loading := encoding.LoadOrStore(source, true); loading {
+		return nil
+	}
+	defer encoding.Delete(source)
+
+	path := p.path(source)
+	if reader, err := p.Open(source, p.options.Bitrate, 0); err == nil {
+		reader.Close()
+		return nil
+	}
+
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return fmt.Errorf("error creating pre-encoded folder: %v", err)
+	}
+
+	options := p.options
+	session, err := dca.EncodeFile(source, &options)
+	if err != nil {
+		return fmt.Errorf("error starting encoder: %v", err)
+	}
+	defer session.Cleanup()
+
+	partPath := path + ".part"
+	file, err := os.Create(partPath)
+	if err != nil {
+		return fmt.Errorf("error creating pre-encoded file: %v", err)
+	}
+
+	written, err := io.Copy(file, session)
+	file.Close()
+	if err == nil {
+		err = session.Error()
+	}
+	if err == nil && written == 0 {
+		err = errors.New("encoder produced no audio")
+	}
+	if err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("error encoding %v: %v", source, err)
+	}
+
+	if err := os.Rename(partPath, path); err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("error renaming pre-encoded file: %v", err)
+	}
+
+	slog.Infof("Pre-encoded %v to %v", source, path)
+	return nil
+}
+
+// EncodeAsync encodes eligible sources in the background.
+func (p *Precoder) EncodeAsync(source string) {
+	if !p.Eligible(source) {
+		return
+	}
+
+	go func() {
+		if err := p.Encode(source); err != nil {
+			slog.Errorf("Error pre-encoding: %v", err)
+		}
+	}()
+}
+
+// Remove deletes the DCA files of the source for every bitrate and frame duration.
+func (p *Precoder) Remove(source string) error {
+	matches, err := filepath.Glob(filepath.Join(p.dir, sourceKey(source)+"-*.dca"))
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		if err := os.Remove(match); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing pre-encoded file: %v", err)
+		}
+	}
+	return nil
+}
+
+func (p *Precoder) path(source string) string {
+	return filepath.Join(p.dir, fmt.Sprintf("%v-%dk-%dms.dca", sourceKey(source), p.options.Bitrate, p.options.FrameDuration))
+}
+
+// sourceKey names the DCA files of a source after its absolute path.
+func sourceKey(source string) string {
+	if abs, err := filepath.Abs(source); err == nil {
+		source = abs
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(source)))[:16]
+}
+
+// Reader plays a DCA file, it implements dca.OpusReader.
+type Reader struct {
+	file          *os.File
+	decoder       *dca.Decoder
+	frameDuration time.Duration
+}
+
+// NewReader reads the DCA frames of file. The frame duration is not taken from
+// the metadata header, whose frame size differs between encoders.
+func NewReader(file *os.File, frameDuration time.Duration) *Reader {
+	return &Reader{
+		file:          file,
+		decoder:       dca.NewDecoder(file),
+		frameDuration: frameDuration,
+	}
+}
+
+func (r *Reader) OpusFrame() ([]byte, error) {
+	return r.decoder.OpusFrame()
+}
+
+func (r *Reader) FrameDuration() time.Duration {
+	return r.frameDuration
+}
+
+// Skip seeks forward by n frames.
+func (r *Reader) Skip(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := r.decoder.OpusFrame(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
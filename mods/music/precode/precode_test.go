@@ -0,0 +1,96 @@
+package precode
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/mods/music/third_party/dca"
+)
+
+func countFrames(t *testing.T, reader *Reader) int {
+	t.Helper()
+	defer reader.Close()
+
+	frames := 0
+	for {
+		if _, err := reader.OpusFrame(); err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			return frames
+		}
+		frames++
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	cacheFolder := filepath.Join(dir, "cache")
+	source := filepath.Join(cacheFolder, "guild", "song.mp3")
+	os.MkdirAll(filepath.Dir(source), 0755)
+	os.WriteFile(source, []byte("mp3"), 0644)
+
+	options := *dca.StdEncodeOptions
+	options.Bitrate = 96
+	p := newPrecoder(true, filepath.Join(dir, "precoded"), cacheFolder, options)
+
+	if _, err := p.Open(source, 96, 0); !errors.Is(err, ErrNotPrecoded) {
+		t.Fatalf("expected ErrNotPrecoded before encoding, got %v", err)
+	}
+
+	// Stand in for an ffmpeg encoded file, it holds 755 frames of 20ms
+	data, err := os.ReadFile("../third_party/dca/testaudio.dca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(p.dir, 0755)
+	if err := os.WriteFile(p.path(source), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := p.Open(source, 96, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.FrameDuration() != 20*time.Millisecond {
+		t.Errorf("unexpected frame duration %v", reader.FrameDuration())
+	}
+	if frames := countFrames(t, reader); frames != 755 {
+		t.Errorf("expected 755 frames, got %d", frames)
+	}
+
+	reader, err = p.Open(source, 96, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames := countFrames(t, reader); frames != 505 {
+		t.Errorf("expected 505 frames after seeking 5s, got %d", frames)
+	}
+
+	if _, err := p.Open(source, 64, 0); !errors.Is(err, ErrBitrate) {
+		t.Errorf("expected ErrBitrate, got %v", err)
+	}
+
+	outside := filepath.Join(dir, "library", "song.mp3")
+	if p.Eligible(outside) {
+		t.Error("files outside the cache folder should not be pre-encoded")
+	}
+
+	// A replaced source makes the pre-encoded file stale
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(source, future, future)
+	if _, err := p.Open(source, 96, 0); !errors.Is(err, ErrNotPrecoded) {
+		t.Errorf("expected ErrNotPrecoded for a stale file, got %v", err)
+	}
+
+	if err := p.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p.path(source)); !os.IsNotExist(err) {
+		t.Error("expected pre-encoded file to be removed")
+	}
+}
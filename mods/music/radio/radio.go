@@ -0,0 +1,107 @@
+// Package radio searches a radio-browser.info compatible internet radio directory.
+package radio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Station struct {
+	UUID        string `json:"stationuuid"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	URLResolved string `json:"url_resolved"`
+	Homepage    string `json:"homepage"`
+	Favicon     string `json:"favicon"`
+	Tags        string `json:"tags"`
+	Country     string `json:"country"`
+	Codec       string `json:"codec"`
+	Bitrate     int    `json:"bitrate"`
+}
+
+// StreamURL prefers the URL the directory resolved from playlist files.
+func (s Station) StreamURL() string {
+	if s.URLResolved != "" {
+		return s.URLResolved
+	}
+	return s.URL
+}
+
+type Client struct {
+	BaseURL    string
+	UserAgent  string
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL, userAgent string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		UserAgent:  userAgent,
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Search looks the term up as a station name, then as a tag and then as a
+// country, returning the most popular working stations of the first match.
+func (c *Client) Search(ctx context.Context, term string, limit int) ([]Station, error) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return nil, fmt.Errorf("search term is empty")
+	}
+
+	for _, field := range []string{"name", "tag", "country"} {
+		stations, err := c.search(ctx, field, term, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(stations) > 0 {
+			return stations, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (c *Client) search(ctx context.Context, field, term string, limit int) ([]Station, error) {
+	params := url.Values{
+		field:        {term},
+		"limit":      {strconv.Itoa(limit)},
+		"hidebroken": {"true"},
+		"order":      {"clickcount"},
+		"reverse":    {"true"},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/json/stations/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error searching stations: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error searching stations: HTTP status %v", resp.StatusCode)
+	}
+
+	var stations []Station
+	if err := json.NewDecoder(resp.Body).Decode(&stations); err != nil {
+		return nil, fmt.Errorf("error decoding stations: %v", err)
+	}
+
+	for i := range stations {
+		stations[i].Name = strings.TrimSpace(stations[i].Name)
+	}
+	return stations, nil
+}
@@ -0,0 +1,45 @@
+package radio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json/stations/search" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		queries = append(queries, r.URL.RawQuery)
+
+		if q.Get("tag") == "jazz" && q.Get("hidebroken") == "true" && q.Get("limit") == "5" {
+			w.Write([]byte(`[{"stationuuid":"1","name":" Jazz FM ","url":"http://jazz.example.com/listen.pls","url_resolved":"http://jazz.example.com/live","country":"Germany","codec":"MP3","bitrate":128},
+				{"stationuuid":"2","name":"Smooth Jazz","url":"http://smooth.example.com/live"}]`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	stations, err := NewClient(srv.URL+"/", "melodix-test").Search(context.Background(), "jazz", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queries) != 2 {
+		t.Errorf("expected a name and then a tag search, got %v", queries)
+	}
+	if len(stations) != 2 || stations[0].Name != "Jazz FM" || stations[0].StreamURL() != "http://jazz.example.com/live" || stations[1].StreamURL() != "http://smooth.example.com/live" {
+		t.Errorf("unexpected stations: %+v", stations)
+	}
+
+	stations, err = NewClient(srv.URL, "").Search(context.Background(), "nowhere", 5)
+	if err != nil || len(stations) != 0 {
+		t.Errorf("expected no stations, got %v, %v", stations, err)
+	}
+}
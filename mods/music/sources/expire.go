@@ -0,0 +1,47 @@
+package sources
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+// ExpiryMargin is how long a media URL must stay valid on top of the song
+// duration to be used without a refresh.
+var ExpiryMargin = 5 * time.Minute
+
+// NeedsRefresh reports whether a YouTube song has no media URL yet, or one
+// whose signed `expire` timestamp passes before the song could finish playing.
+func NeedsRefresh(song *media.Song) bool {
+	if song == nil || song.Source != media.SourceYouTube {
+		return false
+	}
+
+	if song.Filepath == "" {
+		return true
+	}
+
+	expiresAt, ok := MediaURLExpiry(song.Filepath)
+	if !ok {
+		return false
+	}
+
+	return time.Now().Add(song.Duration + ExpiryMargin).After(expiresAt)
+}
+
+// MediaURLExpiry returns the time set by the `expire` query parameter of a signed media URL.
+func MediaURLExpiry(mediaURL string) (time.Time, bool) {
+	parsedURL, err := url.Parse(mediaURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	expire, err := strconv.ParseInt(parsedURL.Query().Get("expire"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(expire, 0), true
+}
@@ -0,0 +1,34 @@
+package sources
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+func TestNeedsRefresh(t *testing.T) {
+	mediaURL := func(expire time.Time) string {
+		return fmt.Sprintf("https://rr1.googlevideo.com/videoplayback?expire=%d&itag=251&dur=212.061", expire.Unix())
+	}
+
+	tests := []struct {
+		name string
+		song *media.Song
+		want bool
+	}{
+		{"unresolved", &media.Song{Source: media.SourceYouTube, URL: "https://www.youtube.com/watch?v=abc"}, true},
+		{"valid", &media.Song{Source: media.SourceYouTube, Filepath: mediaURL(time.Now().Add(6 * time.Hour)), Duration: 4 * time.Minute}, false},
+		{"expired", &media.Song{Source: media.SourceYouTube, Filepath: mediaURL(time.Now().Add(-time.Minute))}, true},
+		{"expires while playing", &media.Song{Source: media.SourceYouTube, Filepath: mediaURL(time.Now().Add(30 * time.Minute)), Duration: time.Hour}, true},
+		{"no expire parameter", &media.Song{Source: media.SourceYouTube, Filepath: "https://example.com/audio.webm"}, false},
+		{"other source", &media.Song{Source: media.SourceStream, Filepath: ""}, false},
+	}
+
+	for _, tt := range tests {
+		if got := NeedsRefresh(tt.song); got != tt.want {
+			t.Errorf("%v: NeedsRefresh() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
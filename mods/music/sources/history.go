@@ -0,0 +1,86 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/history"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+// HistoryProvider resolves track IDs shown by the history command.
+type HistoryProvider struct {
+	guildID string
+	repos   *db.Repositories
+	youtube IYoutube
+	stream  IStream
+}
+
+func NewHistoryProvider(guildID string, repos *db.Repositories) Provider {
+	return &HistoryProvider{
+		guildID: guildID,
+		repos:   repos,
+		youtube: NewYoutube(),
+		stream:  NewStream(),
+	}
+}
+
+func (p *HistoryProvider) Name() string {
+	return "history"
+}
+
+func (p *HistoryProvider) Match(query string) bool {
+	_, err := strconv.ParseUint(query, 10, 64)
+	return err == nil
+}
+
+func (p *HistoryProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	if p.repos == nil {
+		return nil, fmt.Errorf("history is not available")
+	}
+
+	id, err := strconv.ParseUint(query, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert string id to int id: %v", err)
+	}
+
+	track, err := history.NewHistory(p.repos).GetTrackFromHistory(p.guildID, uint(id))
+	if err != nil {
+		return nil, fmt.Errorf("error getting track from history with ID %v: %v", id, err)
+	}
+	if track.ID == 0 {
+		return nil, fmt.Errorf("no track with ID %v in history", id)
+	}
+
+	if strings.HasPrefix(track.SongID, subsonicSongIDPrefix) {
+		return NewSubsonicProvider(NewSubsonicClient()).Resolve(ctx, subsonicPrefix+"song:"+strings.TrimPrefix(track.SongID, subsonicSongIDPrefix))
+	}
+
+	switch track.Source {
+	case media.SourceYouTube.String():
+		return p.youtube.FetchManyByURL(track.URL)
+	case media.SourceStream.String():
+		if u, err := url.Parse(track.URL); err == nil && track.Title != u.Host {
+			return p.stream.FetchNamed(track.URL, track.Title)
+		}
+		return p.stream.FetchManyByManyURLs([]string{track.URL})
+	case media.SourceLocalFile.String():
+		return []*media.Song{{
+			SongID:   track.SongID,
+			Title:    track.Title,
+			URL:      track.URL,
+			Filepath: track.Filepath,
+			Source:   media.SourceLocalFile,
+		}}, nil
+	}
+
+	return nil, fmt.Errorf("track with ID %v has unknown source %q", id, track.Source)
+}
+
+func (p *HistoryProvider) Refresh(song *media.Song) (*media.Song, error) {
+	return nil, ErrUnsupported
+}
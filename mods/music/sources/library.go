@@ -0,0 +1,120 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/library"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+// LibraryCoverFolder is the folder of the cache folder holding cover art
+// extracted while scanning the library.
+const LibraryCoverFolder = "covers"
+
+// LibraryProvider resolves `artist:..`, `album:..` and `lib:..` title searches
+// against the local music library index. Plain titles are searched in the
+// library only when YouTube search finds nothing.
+type LibraryProvider struct {
+	library library.ILibrary
+}
+
+// NewLibrary returns the library configured by library.dir, or nil when it is not set.
+func NewLibrary(repos *db.Repositories) library.ILibrary {
+	conf, err := config.NewConfig()
+	if err != nil || conf.LibraryDir == "" || repos == nil || repos.Library == nil {
+		return nil
+	}
+
+	return library.NewLibrary(conf.LibraryDir, filepath.Join(conf.StorageCacheDir, LibraryCoverFolder), repos.Library, library.NewFFprobe(conf.DcaFfmpegBinaryPath))
+}
+
+func NewLibraryProvider(lib library.ILibrary) Provider {
+	return &LibraryProvider{library: lib}
+}
+
+func (p *LibraryProvider) Name() string {
+	return "library"
+}
+
+func (p *LibraryProvider) FreeText() bool {
+	return true
+}
+
+func (p *LibraryProvider) Match(query string) bool {
+	return p.library != nil && (isTagQuery(query) || isTitleQuery(query))
+}
+
+func (p *LibraryProvider) Fallback() bool {
+	return p.library != nil
+}
+
+func (p *LibraryProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	if p.library == nil {
+		return nil, fmt.Errorf("library is not configured")
+	}
+
+	if isTitleQuery(query) {
+		query = strings.TrimSpace(query)[len(libraryPrefix):]
+	}
+
+	tracks, err := p.library.Find(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// A title search plays the best match only
+	if !isTagQuery(query) {
+		tracks = tracks[:1]
+	}
+
+	songs := make([]*media.Song, 0, len(tracks))
+	for _, track := range tracks {
+		songs = append(songs, songFromLibraryTrack(track))
+	}
+
+	return songs, nil
+}
+
+func (p *LibraryProvider) Refresh(song *media.Song) (*media.Song, error) {
+	if song.Source != media.SourceLocalFile || !strings.HasPrefix(song.SongID, "library-") {
+		return nil, ErrUnsupported
+	}
+
+	if _, err := os.Stat(song.Filepath); err != nil {
+		return nil, fmt.Errorf("library file is gone: %v", err)
+	}
+	return song, nil
+}
+
+const libraryPrefix = "lib:"
+
+func isTitleQuery(query string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(query)), libraryPrefix)
+}
+
+func isTagQuery(query string) bool {
+	lower := strings.ToLower(strings.TrimSpace(query))
+	return strings.HasPrefix(lower, "artist:") || strings.HasPrefix(lower, "album:")
+}
+
+func songFromLibraryTrack(track db.LibraryTrack) *media.Song {
+	title := track.Title
+	if track.Artist != "" {
+		title = track.Artist + " - " + track.Title
+	}
+
+	return &media.Song{
+		Title:    title,
+		Filepath: track.Path,
+		Duration: time.Duration(track.Duration * float64(time.Second)),
+		SongID:   track.SongID,
+		Source:   media.SourceLocalFile,
+	}
+}
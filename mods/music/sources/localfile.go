@@ -0,0 +1,80 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/storage"
+	"github.com/keshon/melodix-player/mods/music/utils"
+)
+
+// LocalFileProvider resolves audio files from the guild's cache directory.
+type LocalFileProvider struct {
+	guildID string
+	repos   *db.Repositories
+}
+
+func NewLocalFileProvider(guildID string, repos *db.Repositories) Provider {
+	return &LocalFileProvider{guildID: guildID, repos: repos}
+}
+
+func (p *LocalFileProvider) Name() string {
+	return "localfile"
+}
+
+func (p *LocalFileProvider) Match(query string) bool {
+	return utils.IsAudioFile(query)
+}
+
+func (p *LocalFileProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	cacheFolder := CacheFolder()
+	songPath := filepath.Join(cacheFolder, p.guildID, query)
+
+	if _, err := storage.NewStorage(cacheFolder).Stat(ctx, songPath); err != nil {
+		return nil, fmt.Errorf("no such file or directory: %v", err)
+	}
+
+	song := &media.Song{
+		Title:    query,
+		Filepath: songPath,
+	}
+
+	if p.repos != nil {
+		if track, err := p.repos.Tracks.GetByFilepath(songPath); err == nil {
+			song = &media.Song{
+				SongID:   track.SongID,
+				Title:    track.Title,
+				URL:      track.URL,
+				Filepath: track.Filepath,
+			}
+		}
+	}
+
+	song.Source = media.SourceLocalFile
+
+	return []*media.Song{song}, nil
+}
+
+func (p *LocalFileProvider) Refresh(song *media.Song) (*media.Song, error) {
+	if song.Source != media.SourceLocalFile {
+		return nil, ErrUnsupported
+	}
+
+	if _, err := storage.NewStorage(CacheFolder()).Stat(context.Background(), song.Filepath); err != nil {
+		return nil, fmt.Errorf("local file is gone: %v", err)
+	}
+	return song, nil
+}
+
+// CacheFolder returns the configured cache folder.
+func CacheFolder() string {
+	conf, err := config.NewConfig()
+	if err != nil {
+		return config.Default().StorageCacheDir
+	}
+	return conf.StorageCacheDir
+}
@@ -0,0 +1,68 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/mods/music/manifest"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+// fetchManifestSong turns an HLS or DASH manifest into a stream song playing
+// the audio-only (or lowest bandwidth video) rendition.
+func fetchManifestSong(ctx context.Context, manifestURL string) (*media.Song, error) {
+	data, base, contentType, err := fetchRemoteFile(ctx, manifestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := manifest.DetectKind(base.Path, contentType, data)
+	if kind == manifest.KindUnknown {
+		return nil, fmt.Errorf("not an HLS or DASH manifest: %v", manifestURL)
+	}
+
+	stream, err := manifest.Select(kind, data, base)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Infof("Selected %v rendition %v (bandwidth %v, audio only %v)", kind, stream.URL, stream.Bandwidth, stream.AudioOnly)
+
+	// Segment titles and the total duration live in the variant playlist
+	if kind == manifest.KindHLS && stream.URL != base.String() {
+		if variant, _, _, err := fetchRemoteFile(ctx, stream.URL); err == nil {
+			info := manifest.ParseMedia(variant)
+			stream.Duration = info.Duration
+			if stream.Title == "" {
+				stream.Title = info.Title
+			}
+		} else {
+			slog.Warnf("Error reading HLS variant playlist: %v", err)
+		}
+	}
+
+	title := stream.Title
+	if title == "" {
+		title = base.Host
+	}
+
+	duration := stream.Duration
+	if duration <= 0 {
+		duration = -1
+	}
+
+	return &media.Song{
+		Title:        title,
+		URL:          manifestURL,
+		Filepath:     stream.URL,
+		Duration:     duration,
+		SongID:       fmt.Sprintf("%d", crc32.ChecksumIEEE([]byte(manifestURL))),
+		Source:       media.SourceStream,
+		Live:         duration < 0,
+		InputOptions: stream.InputOptions,
+		StreamMap:    stream.Map,
+	}, nil
+}
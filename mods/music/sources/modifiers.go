@@ -0,0 +1,69 @@
+package sources
+
+import (
+	"math/rand"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+// Modifiers narrow down and reorder the songs of the URL they follow,
+// e.g. `play <playlist-url> 5-20 reverse`.
+type Modifiers struct {
+	From    int // 1-based first song, 0 for the start of the list
+	To      int // 1-based last song, 0 for the end of the list
+	Reverse bool
+	Shuffle bool
+}
+
+var rangeRe = regexp.MustCompile(`^(\d+)-(\d*)$`)
+
+// parseModifier applies token to m, reporting whether it is a modifier at all.
+func (m *Modifiers) parseModifier(token string) bool {
+	switch strings.ToLower(token) {
+	case "reverse":
+		m.Reverse = true
+		return true
+	case "shuffle":
+		m.Shuffle = true
+		return true
+	}
+
+	match := rangeRe.FindStringSubmatch(token)
+	if match == nil {
+		return false
+	}
+
+	m.From, _ = strconv.Atoi(match[1])
+	m.To = 0
+	if match[2] != "" {
+		m.To, _ = strconv.Atoi(match[2])
+	}
+	return true
+}
+
+// Apply returns the songs selected by the range, reversed or shuffled.
+func (m Modifiers) Apply(songs []*media.Song) []*media.Song {
+	from, to := max(m.From, 1), len(songs)
+	if m.To > 0 {
+		to = min(m.To, len(songs))
+	}
+
+	if from > to {
+		return nil
+	}
+
+	selected := slices.Clone(songs[from-1 : to])
+
+	if m.Reverse {
+		slices.Reverse(selected)
+	}
+	if m.Shuffle {
+		rand.Shuffle(len(selected), func(i, j int) { selected[i], selected[j] = selected[j], selected[i] })
+	}
+
+	return selected
+}
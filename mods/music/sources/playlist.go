@@ -0,0 +1,274 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/playlist"
+	"github.com/keshon/melodix-player/mods/music/storage"
+	"github.com/keshon/melodix-player/mods/music/utils"
+)
+
+const maxPlaylistSize = 5 << 20
+
+// PlaylistProvider expands M3U, PLS and XSPF playlists given as URL or as a
+// file in the guild's cache directory.
+type PlaylistProvider struct {
+	guildID string
+	repos   *db.Repositories
+}
+
+func NewPlaylistProvider(guildID string, repos *db.Repositories) Provider {
+	return &PlaylistProvider{guildID: guildID, repos: repos}
+}
+
+func (p *PlaylistProvider) Name() string {
+	return "playlist"
+}
+
+func (p *PlaylistProvider) Match(query string) bool {
+	if u, err := url.Parse(query); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return playlist.IsPlaylistFile(u.Path)
+	}
+	return !strings.Contains(query, "://") && playlist.IsPlaylistFile(query)
+}
+
+func (p *PlaylistProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	if u, err := url.Parse(query); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		songs, err := fetchRemotePlaylist(ctx, query)
+		if errors.Is(err, playlist.ErrHLS) {
+			song, err := fetchManifestSong(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+			return []*media.Song{song}, nil
+		}
+		return songs, err
+	}
+
+	cacheFolder := CacheFolder()
+	return readLocalPlaylist(filepath.Join(cacheFolder, p.guildID), query, p.repos, storage.NewStorage(cacheFolder))
+}
+
+func (p *PlaylistProvider) Refresh(song *media.Song) (*media.Song, error) {
+	return nil, ErrUnsupported
+}
+
+// fetchRemotePlaylist downloads and expands a playlist, entries may only point to http(s) URLs.
+func fetchRemotePlaylist(ctx context.Context, playlistURL string) ([]*media.Song, error) {
+	data, base, contentType, err := fetchRemoteFile(ctx, playlistURL)
+	if err != nil {
+		return nil, err
+	}
+
+	format := playlist.DetectFormat(base.Path, contentType, data)
+	entries, err := playlist.Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var songs []*media.Song
+	for _, entry := range entries {
+		ref, err := url.Parse(entry.Location)
+		if err != nil {
+			slog.Warnf("Skipping playlist entry %q: %v", entry.Location, err)
+			continue
+		}
+
+		location := base.ResolveReference(ref)
+		if location.Scheme != "http" && location.Scheme != "https" {
+			slog.Warnf("Skipping playlist entry %q: only http(s) entries are allowed in remote playlists", entry.Location)
+			continue
+		}
+
+		entrySongs, err := songsFromURLEntry(location.String(), entry)
+		if err != nil {
+			slog.Warnf("Skipping playlist entry %q: %v", entry.Location, err)
+			continue
+		}
+		songs = append(songs, entrySongs...)
+	}
+
+	if len(songs) == 0 {
+		return nil, fmt.Errorf("no playable entries in %v playlist", format)
+	}
+
+	return songs, nil
+}
+
+// fetchRemoteFile downloads a small text file such as a playlist or a manifest,
+// returning its content, the URL after redirects and the content type.
+func fetchRemoteFile(ctx context.Context, fileURL string) ([]byte, *url.URL, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("error creating request: %v", err)
+	}
+
+	if conf, err := config.NewConfig(); err == nil {
+		req.Header.Set("User-Agent", conf.DcaUserAgent)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("error fetching %v: %v", fileURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, "", fmt.Errorf("error fetching %v: HTTP status %v", fileURL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPlaylistSize))
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("error reading %v: %v", fileURL, err)
+	}
+
+	return data, resp.Request.URL, resp.Header.Get("Content-Type"), nil
+}
+
+// readLocalPlaylist expands a playlist stored in dir. Local entries must stay inside dir.
+func readLocalPlaylist(dir, name string, repos *db.Repositories, store storage.IStorage) ([]*media.Song, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	playlistPath, err := containedPath(root, root, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(playlistPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading playlist: %v", err)
+	}
+
+	format := playlist.DetectFormat(playlistPath, "", data)
+	entries, err := playlist.Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var songs []*media.Song
+	for _, entry := range entries {
+		if u, err := url.Parse(entry.Location); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			entrySongs, err := songsFromURLEntry(entry.Location, entry)
+			if err != nil {
+				slog.Warnf("Skipping playlist entry %q: %v", entry.Location, err)
+				continue
+			}
+			songs = append(songs, entrySongs...)
+			continue
+		}
+
+		location := strings.TrimPrefix(entry.Location, "file://")
+		songPath, err := containedPath(root, filepath.Dir(playlistPath), location)
+		if err != nil {
+			slog.Warnf("Skipping playlist entry %q: %v", entry.Location, err)
+			continue
+		}
+		if _, err := store.Stat(context.Background(), songPath); err != nil {
+			slog.Warnf("Skipping playlist entry %q: %v", entry.Location, err)
+			continue
+		}
+
+		songs = append(songs, songFromFileEntry(songPath, entry, repos))
+	}
+
+	if len(songs) == 0 {
+		return nil, fmt.Errorf("no playable entries in %v playlist", format)
+	}
+
+	return songs, nil
+}
+
+// containedPath resolves name relative to dir and makes sure it stays within root.
+func containedPath(root, dir, name string) (string, error) {
+	p := name
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, filepath.FromSlash(name))
+	}
+
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+
+	if p != root && !strings.HasPrefix(p, root+string(filepath.Separator)) {
+		return "", errors.New("path is outside of the cache directory")
+	}
+
+	return p, nil
+}
+
+func songsFromURLEntry(location string, entry playlist.Entry) ([]*media.Song, error) {
+	if utils.IsYouTubeURL(location) {
+		return NewYoutube().FetchManyByURL(location)
+	}
+
+	title := entry.Title
+	if title == "" {
+		u, _ := url.Parse(location)
+		title = path.Base(u.Path)
+		if title == "/" || title == "." {
+			title = u.Host
+		}
+	}
+
+	return []*media.Song{{
+		Title:    title,
+		URL:      location,
+		Filepath: location,
+		Duration: entry.Duration,
+		SongID:   fmt.Sprintf("%d", crc32.ChecksumIEEE([]byte(location))),
+		Source:   media.SourceStream,
+		Live:     entry.Live,
+	}}, nil
+}
+
+func songFromFileEntry(songPath string, entry playlist.Entry, repos *db.Repositories) *media.Song {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, songPath); err == nil && !strings.HasPrefix(rel, "..") {
+			songPath = rel
+		}
+	}
+
+	if repos != nil {
+		if track, err := repos.Tracks.GetByFilepath(songPath); err == nil {
+			return &media.Song{
+				SongID:   track.SongID,
+				Title:    track.Title,
+				URL:      track.URL,
+				Filepath: track.Filepath,
+				Duration: entry.Duration,
+				Source:   media.SourceLocalFile,
+			}
+		}
+	}
+
+	title := entry.Title
+	if title == "" {
+		title = filepath.Base(songPath)
+	}
+
+	return &media.Song{
+		Title:    title,
+		Filepath: songPath,
+		Duration: entry.Duration,
+		Source:   media.SourceLocalFile,
+	}
+}
@@ -0,0 +1,121 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/storage"
+)
+
+func TestFetchRemotePlaylist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/x-mpegurl")
+		w.Write([]byte("#EXTM3U\n#EXTINF:90,Artist - Song\nsongs/a.mp3\n#EXTINF:-1,Radio\nhttp://radio.example.com/live\nsongs/b.mp3\nfile:///etc/passwd\n"))
+	}))
+	defer server.Close()
+
+	provider := NewPlaylistProvider("guild", nil)
+	if !provider.Match(server.URL + "/list.m3u") {
+		t.Fatal("expected playlist URL to match")
+	}
+
+	songs, err := provider.Resolve(context.Background(), server.URL+"/list.m3u")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(songs) != 3 {
+		t.Fatalf("expected 3 songs, got %d: %+v", len(songs), songs)
+	}
+	if songs[0].URL != server.URL+"/songs/a.mp3" || songs[0].Title != "Artist - Song" || songs[0].Duration != 90*time.Second || songs[0].Source != media.SourceStream || songs[0].Live {
+		t.Errorf("unexpected first song: %+v", songs[0])
+	}
+	if songs[1].Title != "Radio" || songs[1].Duration != -1 || !songs[1].Live {
+		t.Errorf("unexpected second song: %+v", songs[1])
+	}
+
+	// Files without a duration are played once, not restarted as live streams
+	if songs[2].URL != server.URL+"/songs/b.mp3" || songs[2].Duration != -1 || songs[2].Live {
+		t.Errorf("unexpected third song: %+v", songs[2])
+	}
+}
+
+func TestReadLocalPlaylist(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache", "guild")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(cacheDir, "a.mp3"), []byte("audio"), 0644)
+	os.WriteFile(filepath.Join(dir, "secret.mp3"), []byte("audio"), 0644)
+	os.WriteFile(filepath.Join(cacheDir, "list.pls"), []byte("[playlist]\nFile1=a.mp3\nTitle1=Local\nFile2=../../secret.mp3\nFile3=missing.mp3\n"), 0644)
+
+	songs, err := readLocalPlaylist(cacheDir, "list.pls", nil, storage.NewLocal(cacheDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(songs) != 1 || songs[0].Title != "Local" || songs[0].Source != media.SourceLocalFile {
+		t.Errorf("unexpected songs: %+v", songs)
+	}
+
+	if _, err := readLocalPlaylist(cacheDir, "../../list.pls", nil, storage.NewLocal(cacheDir)); err == nil {
+		t.Error("expected error for playlist outside of the cache directory")
+	}
+}
+
+func TestResolveHLSManifest(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/live/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=96000,CODECS=\"mp4a.40.2\"\naudio.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=900000,RESOLUTION=640x360\nvideo.m3u8\n"))
+	})
+	mux.HandleFunc("/live/audio.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,title=\"Song\",artist=\"Artist\"\nseg1.aac\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	songs, err := NewPlaylistProvider("guild", nil).Resolve(context.Background(), server.URL+"/live/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(songs) != 1 {
+		t.Fatalf("expected 1 song, got %d: %+v", len(songs), songs)
+	}
+	song := songs[0]
+	if song.Filepath != server.URL+"/live/audio.m3u8" || song.URL != server.URL+"/live/master.m3u8" || song.Title != "Artist - Song" || song.Duration != -1 || len(song.InputOptions) == 0 {
+		t.Errorf("unexpected song: %+v", song)
+	}
+}
+
+func TestResolveDASHManifest(t *testing.T) {
+	t.Setenv("DISCORD_BOT_TOKEN", "token") // stream probing loads the config for the User-Agent
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/dash+xml")
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write([]byte(`<MPD type="dynamic"><Period><AdaptationSet contentType="audio"><Representation bandwidth="64000"/></AdaptationSet></Period></MPD>`))
+	}))
+	defer server.Close()
+
+	songs, err := NewStream().FetchManyByManyURLs([]string{server.URL + "/radio"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(songs) != 1 || songs[0].StreamMap != "0:a:0" || songs[0].Duration != -1 || songs[0].Source != media.SourceStream {
+		t.Errorf("unexpected songs: %+v", songs)
+	}
+}
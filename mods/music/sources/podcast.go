@@ -0,0 +1,218 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/podcast"
+)
+
+// PodcastSniffTimeout bounds the request telling feeds apart from other URLs.
+var PodcastSniffTimeout = 5 * time.Second
+
+// PodcastProvider plays episodes of podcast RSS/Atom feeds: `<feed>` or
+// `<feed> latest` for the newest one, `<feed> 3` for the third newest.
+type PodcastProvider struct {
+	guildID string
+	repos   *db.Repositories
+}
+
+func NewPodcastProvider(guildID string, repos *db.Repositories) Provider {
+	return &PodcastProvider{guildID: guildID, repos: repos}
+}
+
+func (p *PodcastProvider) Name() string {
+	return "podcast"
+}
+
+func (p *PodcastProvider) Match(query string) bool {
+	u, err := url.Parse(query)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+
+	if p.repos != nil && p.repos.Podcasts != nil {
+		if _, err := p.repos.Podcasts.Get(p.guildID, query); err == nil {
+			return true
+		}
+	}
+
+	return sniffFeed(query)
+}
+
+func (p *PodcastProvider) Argument(token string) bool {
+	return parseEpisodeNumber(token) > 0
+}
+
+func (p *PodcastProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	feedURL, arg, _ := strings.Cut(query, " ")
+
+	number := 1
+	if arg != "" {
+		number = parseEpisodeNumber(arg)
+	}
+
+	feed, err := FetchPodcast(ctx, p.guildID, p.repos, feedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if number < 1 || number > len(feed.Episodes) {
+		return nil, fmt.Errorf("feed %q has %d episodes, there is no episode %d", feed.Title, len(feed.Episodes), number)
+	}
+
+	return []*media.Song{SongFromEpisode(feed.Episodes[number-1])}, nil
+}
+
+func (p *PodcastProvider) Refresh(song *media.Song) (*media.Song, error) {
+	return nil, ErrUnsupported
+}
+
+// parseEpisodeNumber reads `latest` or a 1-based episode number, returning 0 otherwise.
+func parseEpisodeNumber(token string) int {
+	if strings.EqualFold(token, "latest") {
+		return 1
+	}
+	n, err := strconv.Atoi(token)
+	if err != nil || n < 1 {
+		return 0
+	}
+	return n
+}
+
+// SongFromEpisode plays the episode enclosure as a stream that ends, its
+// duration being 0 when the feed doesn't tell it.
+func SongFromEpisode(episode podcast.Episode) *media.Song {
+	return &media.Song{
+		Title:     episode.Title,
+		URL:       episode.URL,
+		Filepath:  episode.URL,
+		Duration:  episode.Duration,
+		Thumbnail: media.Thumbnail{URL: episode.ImageURL},
+		SongID:    fmt.Sprintf("%d", crc32.ChecksumIEEE([]byte(episode.URL))),
+		Source:    media.SourceStream,
+		Live:      false,
+	}
+}
+
+func newPodcastClient() *podcast.Client {
+	var userAgent string
+	if conf, err := config.NewConfig(); err == nil {
+		userAgent = conf.DcaUserAgent
+	}
+	return podcast.NewClient(userAgent)
+}
+
+// FetchPodcast fetches a feed and remembers it for the guild. A newly remembered
+// feed starts with all of its current episodes marked as seen.
+func FetchPodcast(ctx context.Context, guildID string, repos *db.Repositories, feedURL string) (*podcast.Feed, error) {
+	feed, err := newPodcastClient().Fetch(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if repos == nil || repos.Podcasts == nil {
+		return feed, nil
+	}
+
+	if _, err := repos.Podcasts.Get(guildID, feedURL); err != nil {
+		record := &db.PodcastFeed{GuildID: guildID, URL: feedURL, Title: feed.Title}
+		markLatest(record, feed)
+		if err := repos.Podcasts.Save(record); err != nil {
+			return nil, fmt.Errorf("error saving podcast feed: %v", err)
+		}
+	}
+
+	return feed, nil
+}
+
+// PodcastUpdate lists the episodes published since the previous check of a feed.
+type PodcastUpdate struct {
+	Feed     db.PodcastFeed
+	Episodes []podcast.Episode
+	Err      error
+}
+
+// CheckPodcasts fetches every feed remembered by the guild and returns their new
+// episodes, marking them as seen.
+func CheckPodcasts(ctx context.Context, guildID string, repos *db.Repositories) ([]PodcastUpdate, error) {
+	if repos == nil || repos.Podcasts == nil {
+		return nil, fmt.Errorf("podcasts are not available")
+	}
+
+	records, err := repos.Podcasts.GetAll(guildID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting podcast feeds: %v", err)
+	}
+
+	client := newPodcastClient()
+
+	updates := make([]PodcastUpdate, 0, len(records))
+	for _, record := range records {
+		feed, err := client.Fetch(ctx, record.URL)
+		if err != nil {
+			updates = append(updates, PodcastUpdate{Feed: record, Err: err})
+			continue
+		}
+
+		update := PodcastUpdate{
+			Feed:     record,
+			Episodes: podcast.NewEpisodes(feed, record.LatestGUID, time.Unix(record.LatestPublished, 0)),
+		}
+
+		record.Title = feed.Title
+		markLatest(&record, feed)
+		if err := repos.Podcasts.Save(&record); err != nil {
+			update.Err = fmt.Errorf("error saving podcast feed: %v", err)
+		}
+
+		updates = append(updates, update)
+	}
+
+	return updates, nil
+}
+
+func markLatest(record *db.PodcastFeed, feed *podcast.Feed) {
+	if len(feed.Episodes) == 0 {
+		return
+	}
+	record.LatestGUID = feed.Episodes[0].GUID
+	record.LatestPublished = feed.Episodes[0].Published.Unix()
+}
+
+// sniffFeed peeks at the start of the document to tell feeds from streams and pages.
+func sniffFeed(feedURL string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), PodcastSniffTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return false
+	}
+	if conf, err := config.NewConfig(); err == nil {
+		req.Header.Set("User-Agent", conf.DcaUserAgent)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return podcast.IsFeed(resp.Header.Get("Content-Type"), head)
+}
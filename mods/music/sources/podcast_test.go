@@ -0,0 +1,99 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+type fakeFeed struct {
+	sync.Mutex
+	episodes int
+}
+
+func (f *fakeFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	w.Header().Set("Content-Type", "application/rss+xml")
+	fmt.Fprint(w, `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel><title>Test Cast</title>`)
+	for i := f.episodes; i >= 1; i-- {
+		fmt.Fprintf(w, `<item><title>Episode %d</title><guid>ep-%d</guid><pubDate>%v</pubDate><enclosure url="https://cdn.example.com/%d.mp3" type="audio/mpeg"/><itunes:duration>10:00</itunes:duration></item>`,
+			i, i, time.Date(2024, 1, i, 0, 0, 0, 0, time.UTC).Format(time.RFC1123Z), i)
+	}
+	fmt.Fprint(w, `</channel></rss>`)
+}
+
+func TestResolvePodcast(t *testing.T) {
+	feed := &fakeFeed{episodes: 3}
+	srv := httptest.NewServer(feed)
+	defer srv.Close()
+
+	repos := db.NewMemoryRepositories()
+	registry := NewRegistryWithProviders(NewPodcastProvider("guild", repos), NewHistoryProvider("guild", repos))
+
+	songs, err := registry.Resolve(context.Background(), srv.URL+" 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 1 || songs[0].Title != "Episode 2" || songs[0].Filepath != "https://cdn.example.com/2.mp3" || songs[0].Duration != 10*time.Minute || songs[0].Source != media.SourceStream {
+		t.Errorf("unexpected songs: %+v", songs)
+	}
+
+	songs, err = registry.Resolve(context.Background(), srv.URL+" latest")
+	if err != nil || len(songs) != 1 || songs[0].Title != "Episode 3" {
+		t.Errorf("unexpected latest episode: %+v, %v", songs, err)
+	}
+
+	if _, err := registry.Resolve(context.Background(), srv.URL+" 9"); err == nil {
+		t.Error("expected an error for a missing episode")
+	}
+
+	feeds, _ := repos.Podcasts.GetAll("guild")
+	if len(feeds) != 1 || feeds[0].Title != "Test Cast" || feeds[0].LatestGUID != "ep-3" {
+		t.Fatalf("feed is not remembered: %+v", feeds)
+	}
+
+	feed.Lock()
+	feed.episodes = 5
+	feed.Unlock()
+
+	updates, err := CheckPodcasts(context.Background(), "guild", repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || len(updates[0].Episodes) != 2 || updates[0].Episodes[0].Title != "Episode 5" {
+		t.Errorf("unexpected updates: %+v", updates)
+	}
+
+	updates, _ = CheckPodcasts(context.Background(), "guild", repos)
+	if len(updates[0].Episodes) != 0 {
+		t.Errorf("episodes should be marked as seen, got %+v", updates[0].Episodes)
+	}
+}
+
+func TestResolvePodcastWithoutDuration(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Test Cast</title><item><title>Episode 1</title><guid>ep-1</guid><enclosure url="https://cdn.example.com/1.mp3" type="audio/mpeg"/></item></channel></rss>`)
+	}))
+	defer srv.Close()
+
+	registry := NewRegistryWithProviders(NewPodcastProvider("guild", db.NewMemoryRepositories()))
+
+	// The episode ends when its file does, it is not restarted as a live stream
+	songs, err := registry.Resolve(context.Background(), srv.URL+" latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 1 || songs[0].Duration != 0 || songs[0].Source != media.SourceStream || songs[0].Live {
+		t.Errorf("unexpected songs: %+v", songs)
+	}
+}
@@ -0,0 +1,259 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+// Provider turns a user query into playable songs.
+type Provider interface {
+	// Name is a short identifier used in logs and errors.
+	Name() string
+	// Match reports whether the provider understands a single query token
+	// (or the whole query for free text providers).
+	Match(query string) bool
+	// Resolve fetches the songs described by query.
+	Resolve(ctx context.Context, query string) ([]*media.Song, error)
+	// Refresh renews expiring data (e.g. stream URLs) of a song it produced,
+	// returning ErrUnsupported for songs of other providers.
+	Refresh(song *media.Song) (*media.Song, error)
+}
+
+// FreeTextProvider is implemented by providers that take the whole query as
+// free text (e.g. a title search) instead of single tokens like URLs or IDs.
+type FreeTextProvider interface {
+	FreeText() bool
+}
+
+func isFreeText(p Provider) bool {
+	f, ok := p.(FreeTextProvider)
+	return ok && f.FreeText()
+}
+
+// FallbackProvider is implemented by free text providers asked with the whole
+// query when the free text provider matching it finds nothing.
+type FallbackProvider interface {
+	Fallback() bool
+}
+
+func isFallback(p Provider) bool {
+	f, ok := p.(FallbackProvider)
+	return ok && f.Fallback()
+}
+
+// ArgumentProvider is implemented by providers accepting an argument after
+// their token, e.g. the episode number in `play <feed> 3`.
+type ArgumentProvider interface {
+	Argument(token string) bool
+}
+
+func acceptsArgument(p Provider, token string) bool {
+	a, ok := p.(ArgumentProvider)
+	return ok && a.Argument(token)
+}
+
+// ErrUnsupported is returned by Provider.Refresh for songs the provider does not handle.
+var ErrUnsupported = errors.New("song is not handled by this provider")
+
+// ProviderOptions carries what providers may need to resolve guild specific queries.
+type ProviderOptions struct {
+	GuildID string
+	Repos   *db.Repositories
+}
+
+// Factory creates a provider for a single registry.
+type Factory func(opts ProviderOptions) Provider
+
+type registration struct {
+	name     string
+	priority int
+	factory  Factory
+}
+
+var (
+	registrationsMu sync.RWMutex
+	registrations   []registration
+)
+
+// Register adds a provider factory. Providers with higher priority are asked first.
+// Registering a name twice replaces the previous registration.
+func Register(name string, priority int, factory Factory) {
+	registrationsMu.Lock()
+	defer registrationsMu.Unlock()
+
+	for i, r := range registrations {
+		if r.name == name {
+			registrations[i] = registration{name, priority, factory}
+			return
+		}
+	}
+	registrations = append(registrations, registration{name, priority, factory})
+}
+
+func init() {
+	Register("youtube", 100, func(ProviderOptions) Provider { return NewYoutubeProvider() })
+	Register("playlist", 95, func(opts ProviderOptions) Provider { return NewPlaylistProvider(opts.GuildID, opts.Repos) })
+	Register("history", 90, func(opts ProviderOptions) Provider { return NewHistoryProvider(opts.GuildID, opts.Repos) })
+	Register("localfile", 80, func(opts ProviderOptions) Provider { return NewLocalFileProvider(opts.GuildID, opts.Repos) })
+	Register("podcast", 60, func(opts ProviderOptions) Provider { return NewPodcastProvider(opts.GuildID, opts.Repos) })
+	Register("stream", 50, func(ProviderOptions) Provider { return NewStreamProvider() })
+	Register("subsonic", 20, func(ProviderOptions) Provider { return NewSubsonicProvider(NewSubsonicClient()) })
+	Register("library", 10, func(opts ProviderOptions) Provider { return NewLibraryProvider(NewLibrary(opts.Repos)) })
+	Register("youtube_search", 0, func(ProviderOptions) Provider { return NewYoutubeSearchProvider() })
+}
+
+type IRegistry interface {
+	Providers() []Provider
+	Resolve(ctx context.Context, query string) ([]*media.Song, error)
+	Refresh(song *media.Song) (*media.Song, error)
+}
+
+type Registry struct {
+	providers []Provider
+}
+
+// NewRegistry instantiates all registered providers ordered by priority.
+func NewRegistry(opts ProviderOptions) IRegistry {
+	registrationsMu.RLock()
+	sorted := append([]registration(nil), registrations...)
+	registrationsMu.RUnlock()
+
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].priority > sorted[j].priority })
+
+	providers := make([]Provider, 0, len(sorted))
+	for _, r := range sorted {
+		providers = append(providers, r.factory(opts))
+	}
+
+	return &Registry{providers: providers}
+}
+
+// NewRegistryWithProviders builds a registry from the given providers, in order.
+func NewRegistryWithProviders(providers ...Provider) IRegistry {
+	return &Registry{providers: providers}
+}
+
+func (r *Registry) Providers() []Provider {
+	return r.providers
+}
+
+func (r *Registry) match(query string) Provider {
+	for _, p := range r.providers {
+		if p.Match(query) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Resolve splits the query into space separated tokens when each of them is
+// understood on its own (several URLs or history IDs), otherwise the whole
+// query is passed to the first free text provider (e.g. a title search), then
+// to fallback providers when it finds nothing.
+// Modifiers (a `5-20` range, `reverse`, `shuffle`) apply to the token before them.
+func (r *Registry) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("empty query")
+	}
+
+	type job struct {
+		provider    Provider
+		query       string
+		hasArgument bool
+		modifiers   Modifiers
+		fallback    bool
+	}
+
+	var jobs []job
+	for _, token := range strings.Fields(query) {
+		if n := len(jobs); n > 0 {
+			last := &jobs[n-1]
+			if !last.hasArgument && acceptsArgument(last.provider, token) {
+				last.query += " " + token
+				last.hasArgument = true
+				continue
+			}
+			if last.modifiers.parseModifier(token) {
+				continue
+			}
+		}
+
+		p := r.match(token)
+		if p == nil || isFreeText(p) {
+			jobs = nil
+			break
+		}
+		jobs = append(jobs, job{provider: p, query: token})
+	}
+
+	if jobs == nil {
+		for _, p := range r.providers {
+			if isFreeText(p) && p.Match(query) {
+				jobs = []job{{provider: p, query: query}}
+				break
+			}
+		}
+		for _, p := range r.providers {
+			if isFallback(p) && (len(jobs) == 0 || jobs[0].provider != p) {
+				jobs = append(jobs, job{provider: p, query: query, fallback: true})
+			}
+		}
+	}
+	if jobs == nil {
+		return nil, fmt.Errorf("no source found for %q", query)
+	}
+
+	var songs []*media.Song
+	var errs []error
+	for _, j := range jobs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if j.fallback && len(songs) > 0 {
+			continue
+		}
+
+		slog.Infof("Resolving %q with %v provider", j.query, j.provider.Name())
+		resolved, err := j.provider.Resolve(ctx, j.query)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", j.provider.Name(), err))
+			continue
+		}
+		songs = append(songs, j.modifiers.Apply(resolved)...)
+	}
+
+	if len(songs) == 0 {
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("no songs found for %q", query)
+		}
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		slog.Warn("Some of the query could not be resolved:", err)
+	}
+
+	return songs, nil
+}
+
+// Refresh asks providers in order to renew the song, returning it unchanged
+// when none of them handles it.
+func (r *Registry) Refresh(song *media.Song) (*media.Song, error) {
+	for _, p := range r.providers {
+		refreshed, err := p.Refresh(song)
+		if errors.Is(err, ErrUnsupported) {
+			continue
+		}
+		return refreshed, err
+	}
+	return song, nil
+}
@@ -0,0 +1,204 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+type fakeProvider struct {
+	name     string
+	match    func(string) bool
+	freeText bool
+	resolved []string
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) FreeText() bool { return p.freeText }
+
+func (p *fakeProvider) Match(query string) bool { return p.match(query) }
+
+func (p *fakeProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	p.resolved = append(p.resolved, query)
+	return []*media.Song{{Title: p.name + ":" + query}}, nil
+}
+
+func (p *fakeProvider) Refresh(song *media.Song) (*media.Song, error) {
+	if !strings.HasPrefix(song.Title, p.name+":") {
+		return nil, ErrUnsupported
+	}
+	return &media.Song{Title: song.Title + " (refreshed)"}, nil
+}
+
+func newFakeRegistry() (*fakeProvider, *fakeProvider, *fakeProvider, IRegistry) {
+	url := &fakeProvider{name: "url", match: func(q string) bool { return strings.HasPrefix(q, "http") }}
+	id := &fakeProvider{name: "id", match: NewHistoryProvider("", nil).Match}
+	search := &fakeProvider{name: "search", match: func(q string) bool { return q != "" }, freeText: true}
+	return url, id, search, NewRegistryWithProviders(url, id, search)
+}
+
+func TestRegistryResolve(t *testing.T) {
+	tests := []struct {
+		query  string
+		titles []string
+	}{
+		{"http://a", []string{"url:http://a"}},
+		{"http://a http://b 3", []string{"url:http://a", "url:http://b", "id:3"}},
+		{"1 2", []string{"id:1", "id:2"}},
+		{"Never Gonna Give You Up", []string{"search:Never Gonna Give You Up"}},
+		{"Song 2", []string{"search:Song 2"}},
+	}
+
+	for _, tt := range tests {
+		_, _, _, registry := newFakeRegistry()
+
+		songs, err := registry.Resolve(context.Background(), tt.query)
+		if err != nil {
+			t.Fatalf("Resolve(%q) returned error: %v", tt.query, err)
+		}
+
+		var titles []string
+		for _, song := range songs {
+			titles = append(titles, song.Title)
+		}
+		if strings.Join(titles, "|") != strings.Join(tt.titles, "|") {
+			t.Errorf("Resolve(%q) = %v, want %v", tt.query, titles, tt.titles)
+		}
+	}
+}
+
+func TestRegistryRefresh(t *testing.T) {
+	_, _, _, registry := newFakeRegistry()
+
+	song, err := registry.Refresh(&media.Song{Title: "id:1"})
+	if err != nil || song.Title != "id:1 (refreshed)" {
+		t.Errorf("unexpected refresh result %+v (err %v)", song, err)
+	}
+
+	unknown := &media.Song{Title: "other"}
+	if song, _ := registry.Refresh(unknown); song != unknown {
+		t.Errorf("unhandled song should be returned unchanged, got %+v", song)
+	}
+}
+
+func TestDefaultProvidersOrder(t *testing.T) {
+	registry := NewRegistry(ProviderOptions{GuildID: "guild"})
+
+	tests := map[string]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ": "youtube",
+		"https://youtu.be/dQw4w9WgXcQ":                "youtube",
+		"http://stream.radioparadise.com/aac-128":     "stream",
+		"song.mp3":                "localfile",
+		"123":                     "history",
+		"Never Gonna Give You Up": "youtube_search",
+	}
+
+	for query, want := range tests {
+		var got string
+		for _, p := range registry.Providers() {
+			if p.Match(query) {
+				got = p.Name()
+				break
+			}
+		}
+		if got != want {
+			t.Errorf("provider for %q = %v, want %v", query, got, want)
+		}
+	}
+}
+
+type listProvider struct{}
+
+func (listProvider) Name() string { return "list" }
+
+func (listProvider) Match(query string) bool { return strings.HasPrefix(query, "http") }
+
+func (listProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	var songs []*media.Song
+	for _, title := range strings.Split("a b c d e f", " ") {
+		songs = append(songs, &media.Song{Title: title})
+	}
+	return songs, nil
+}
+
+func (listProvider) Refresh(song *media.Song) (*media.Song, error) { return nil, ErrUnsupported }
+
+func TestRegistryResolveModifiers(t *testing.T) {
+	tests := []struct {
+		query  string
+		titles string
+	}{
+		{"http://list 2-4", "bcd"},
+		{"http://list 4-", "def"},
+		{"http://list 5-20 reverse", "fe"},
+		{"http://list reverse http://list 1-2", "fedcbaab"},
+		{"http://list 7-9", ""},
+	}
+
+	registry := NewRegistryWithProviders(listProvider{})
+	for _, tt := range tests {
+		songs, err := registry.Resolve(context.Background(), tt.query)
+		if tt.titles == "" {
+			if err == nil {
+				t.Errorf("Resolve(%q) expected an error for an empty selection", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Resolve(%q) returned error: %v", tt.query, err)
+		}
+
+		var titles string
+		for _, song := range songs {
+			titles += song.Title
+		}
+		if titles != tt.titles {
+			t.Errorf("Resolve(%q) = %v, want %v", tt.query, titles, tt.titles)
+		}
+	}
+
+	songs, err := registry.Resolve(context.Background(), "http://list shuffle")
+	if err != nil || len(songs) != 6 {
+		t.Errorf("shuffle should keep all songs, got %d (err %v)", len(songs), err)
+	}
+}
+
+type fallbackProvider struct {
+	fakeProvider
+}
+
+func (p *fallbackProvider) Fallback() bool { return true }
+
+type emptyProvider struct {
+	fakeProvider
+}
+
+func (p *emptyProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	p.resolved = append(p.resolved, query)
+	return nil, errors.New("no results")
+}
+
+func TestRegistryResolveFallback(t *testing.T) {
+	lib := &fallbackProvider{fakeProvider{name: "lib", match: func(q string) bool { return strings.HasPrefix(q, "lib:") }, freeText: true}}
+	search := &fakeProvider{name: "search", match: func(q string) bool { return q != "" }, freeText: true}
+
+	// The fallback is not asked when the search finds something
+	songs, err := NewRegistryWithProviders(lib, search).Resolve(context.Background(), "Song")
+	if err != nil || len(songs) != 1 || songs[0].Title != "search:Song" || len(lib.resolved) != 0 {
+		t.Fatalf("unexpected result %+v (err %v), fallback asked %v", songs, err, lib.resolved)
+	}
+
+	if songs, err = NewRegistryWithProviders(lib, search).Resolve(context.Background(), "lib:Song"); err != nil || songs[0].Title != "lib:lib:Song" {
+		t.Errorf("unexpected result for a prefixed query %+v (err %v)", songs, err)
+	}
+
+	empty := &emptyProvider{fakeProvider{name: "search", match: func(q string) bool { return q != "" }, freeText: true}}
+	songs, err = NewRegistryWithProviders(lib, empty).Resolve(context.Background(), "Song")
+	if err != nil || len(songs) != 1 || songs[0].Title != "lib:Song" || len(empty.resolved) != 1 {
+		t.Errorf("expected the fallback to resolve the query, got %+v (err %v)", songs, err)
+	}
+}
@@ -1,6 +1,8 @@
 package sources
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"net/http"
@@ -9,11 +11,14 @@ import (
 	"github.com/gookit/slog"
 
 	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/mods/music/manifest"
 	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/playlist"
 )
 
 type IStream interface {
 	FetchManyByManyURLs(urls []string) ([]*media.Song, error)
+	FetchNamed(url, title string) ([]*media.Song, error)
 }
 
 type Stream struct{}
@@ -43,6 +48,30 @@ func (s *Stream) FetchManyByManyURLs(urls []string) ([]*media.Song, error) {
 			continue
 		}
 
+		// Playlists are expanded into their entries
+		isManifest := manifest.IsManifestContentType(contentType) || manifest.IsManifestFile(u.Path)
+		if playlist.IsPlaylistContentType(contentType) {
+			expanded, err := fetchRemotePlaylist(context.Background(), u.String())
+			if err == nil {
+				songs = append(songs, expanded...)
+				continue
+			}
+			if !errors.Is(err, playlist.ErrHLS) {
+				return nil, fmt.Errorf("error expanding playlist %v: %v", u.String(), err)
+			}
+			isManifest = true
+		}
+
+		// HLS and DASH manifests are played from the selected rendition
+		if isManifest {
+			song, err := fetchManifestSong(context.Background(), u.String())
+			if err != nil {
+				return nil, fmt.Errorf("error reading manifest %v: %v", u.String(), err)
+			}
+			songs = append(songs, song)
+			continue
+		}
+
 		if isValidStream(contentType) {
 			song = &media.Song{
 				Title:     u.Host,
@@ -52,6 +81,7 @@ func (s *Stream) FetchManyByManyURLs(urls []string) ([]*media.Song, error) {
 				Duration:  -1,
 				SongID:    fmt.Sprintf("%d", hash),
 				Source:    media.SourceStream,
+				Live:      true,
 			}
 			songs = append(songs, song)
 		} else {
@@ -62,6 +92,25 @@ func (s *Stream) FetchManyByManyURLs(urls []string) ([]*media.Song, error) {
 	return songs, nil
 }
 
+// FetchNamed validates a stream the same way and titles it with a known name,
+// such as a station from the radio directory. Such songs are identified by
+// their URL, as several stations are often served from the same host.
+func (s *Stream) FetchNamed(url, title string) ([]*media.Song, error) {
+	songs, err := s.FetchManyByManyURLs([]string{url})
+	if err != nil {
+		return nil, err
+	}
+	if len(songs) == 0 {
+		return nil, fmt.Errorf("no stream found at %v", url)
+	}
+
+	for _, song := range songs {
+		song.Title = title
+		song.SongID = fmt.Sprintf("%d", crc32.ChecksumIEEE([]byte(song.URL)))
+	}
+	return songs, nil
+}
+
 func getContentType(url string) (string, error) {
 	req, err := http.NewRequest(http.MethodHead, url, nil)
 	if err != nil {
@@ -126,3 +175,32 @@ func isValidStream(contentType string) bool {
 
 	return false
 }
+
+// StreamProvider resolves direct HTTP audio streams such as internet radio.
+type StreamProvider struct {
+	stream IStream
+}
+
+func NewStreamProvider() Provider {
+	return &StreamProvider{stream: NewStream()}
+}
+
+func (p *StreamProvider) Name() string {
+	return "stream"
+}
+
+func (p *StreamProvider) Match(query string) bool {
+	u, err := url.Parse(query)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (p *StreamProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	return p.stream.FetchManyByManyURLs([]string{query})
+}
+
+func (p *StreamProvider) Refresh(song *media.Song) (*media.Song, error) {
+	if song.Source != media.SourceStream || NeedsSignedURL(song) {
+		return nil, ErrUnsupported
+	}
+	return song, nil
+}
@@ -0,0 +1,65 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/history"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+func TestFetchNamedStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			w.Header().Set("Content-Type", "text/html")
+			return
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+	}))
+	defer server.Close()
+
+	stream := NewStream()
+
+	jazz, err := stream.FetchNamed(server.URL+"/jazz", "Jazz FM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rock, err := stream.FetchNamed(server.URL+"/rock", "Rock FM")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(jazz) != 1 || jazz[0].Title != "Jazz FM" || jazz[0].Source != media.SourceStream || jazz[0].Duration != -1 {
+		t.Fatalf("unexpected songs: %+v", jazz)
+	}
+	if jazz[0].SongID == rock[0].SongID {
+		t.Error("stations on the same host should have distinct song IDs")
+	}
+
+	if _, err := stream.FetchNamed(server.URL+"/page", "Not a station"); err == nil {
+		t.Error("expected an error for a non-audio URL")
+	}
+
+	// Replaying the station from history keeps its name
+	repos := db.NewMemoryRepositories()
+	song := jazz[0]
+	if err := history.NewHistory(repos).AddTrackToHistory("guild", &history.Song{Title: song.Title, URL: song.URL, SongID: song.SongID, Source: song.Source.String()}); err != nil {
+		t.Fatal(err)
+	}
+	track, err := repos.Tracks.GetBySongID(song.SongID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := NewHistoryProvider("guild", repos).Resolve(context.Background(), fmt.Sprint(track.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 1 || replayed[0].Title != "Jazz FM" || replayed[0].SongID != song.SongID {
+		t.Errorf("unexpected replayed songs: %+v", replayed)
+	}
+}
@@ -0,0 +1,178 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/subsonic"
+)
+
+const subsonicPrefix = "subsonic:"
+
+// subsonicSongIDPrefix marks songs of the media server so history can replay them.
+const subsonicSongIDPrefix = "subsonic-"
+
+// SubsonicProvider resolves `subsonic:<title>`, `subsonic:album:<name>`,
+// `subsonic:playlist:<name>` and `subsonic:song:<id>` against the configured
+// Subsonic compatible media server.
+type SubsonicProvider struct {
+	client *subsonic.Client
+}
+
+// NewSubsonicClient returns the client configured by subsonic.url, or nil when it is not set.
+func NewSubsonicClient() *subsonic.Client {
+	conf, err := config.NewConfig()
+	if err != nil || conf.SubsonicURL == "" {
+		return nil
+	}
+	return subsonic.NewClient(conf.SubsonicURL, conf.SubsonicUser, conf.SubsonicPassword)
+}
+
+func NewSubsonicProvider(client *subsonic.Client) Provider {
+	return &SubsonicProvider{client: client}
+}
+
+func (p *SubsonicProvider) Name() string {
+	return "subsonic"
+}
+
+func (p *SubsonicProvider) FreeText() bool {
+	return true
+}
+
+func (p *SubsonicProvider) Match(query string) bool {
+	return p.client != nil && strings.HasPrefix(strings.ToLower(strings.TrimSpace(query)), subsonicPrefix)
+}
+
+func (p *SubsonicProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	if p.client == nil {
+		return nil, fmt.Errorf("subsonic server is not configured")
+	}
+
+	query = strings.TrimSpace(query)[len(subsonicPrefix):]
+	kind, value, found := strings.Cut(query, ":")
+	if !found {
+		kind, value = "", query
+	}
+	value = strings.TrimSpace(value)
+
+	var songs []subsonic.Song
+	var err error
+
+	switch strings.ToLower(kind) {
+	case "song":
+		var song *subsonic.Song
+		song, err = p.client.Song(ctx, value)
+		if song != nil {
+			songs = []subsonic.Song{*song}
+		}
+	case "album":
+		songs, err = p.albumSongs(ctx, value)
+	case "playlist":
+		songs, err = p.playlistSongs(ctx, value)
+	default:
+		// Not a known kind, the colon is part of the title
+		songs, err = p.searchSong(ctx, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*media.Song, 0, len(songs))
+	for _, song := range songs {
+		result = append(result, p.song(song))
+	}
+	return result, nil
+}
+
+func (p *SubsonicProvider) Refresh(song *media.Song) (*media.Song, error) {
+	if !NeedsSignedURL(song) {
+		return nil, ErrUnsupported
+	}
+	if p.client == nil {
+		return nil, errors.New("no Subsonic server is configured")
+	}
+
+	refreshed := *song
+	refreshed.Filepath = p.client.StreamURL(strings.TrimPrefix(song.SongID, subsonicSongIDPrefix))
+	return &refreshed, nil
+}
+
+func (p *SubsonicProvider) searchSong(ctx context.Context, title string) ([]subsonic.Song, error) {
+	result, err := p.client.Search(ctx, title, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Songs) == 0 {
+		return nil, fmt.Errorf("no songs found for %q", title)
+	}
+	return result.Songs[:1], nil
+}
+
+func (p *SubsonicProvider) albumSongs(ctx context.Context, name string) ([]subsonic.Song, error) {
+	result, err := p.client.Search(ctx, name, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Albums) == 0 {
+		return nil, fmt.Errorf("no albums found for %q", name)
+	}
+
+	album, err := p.client.Album(ctx, result.Albums[0].ID)
+	if err != nil {
+		return nil, err
+	}
+	return album.Songs, nil
+}
+
+// playlistSongs imports a server playlist given by ID or by name.
+func (p *SubsonicProvider) playlistSongs(ctx context.Context, nameOrID string) ([]subsonic.Song, error) {
+	playlists, err := p.client.Playlists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, playlist := range playlists {
+		if playlist.ID == nameOrID || strings.EqualFold(playlist.Name, nameOrID) {
+			full, err := p.client.Playlist(ctx, playlist.ID)
+			if err != nil {
+				return nil, err
+			}
+			if len(full.Songs) == 0 {
+				return nil, errors.New("playlist is empty")
+			}
+			return full.Songs, nil
+		}
+		names = append(names, playlist.Name)
+	}
+
+	return nil, fmt.Errorf("no playlist %q, available playlists: %v", nameOrID, strings.Join(names, ", "))
+}
+
+// song leaves the media URLs out, they carry credentials of the server and
+// songs are shown in Discord and saved to history.
+func (p *SubsonicProvider) song(song subsonic.Song) *media.Song {
+	title := song.Title
+	if song.Artist != "" {
+		title = song.Artist + " - " + title
+	}
+
+	return &media.Song{
+		Title:    title,
+		Duration: time.Duration(song.Duration) * time.Second,
+		SongID:   subsonicSongIDPrefix + song.ID,
+		Source:   media.SourceStream,
+	}
+}
+
+// NeedsSignedURL tells whether the song is played from a signed URL, which
+// Refresh builds right before encoding.
+func NeedsSignedURL(song *media.Song) bool {
+	return song != nil && strings.HasPrefix(song.SongID, subsonicSongIDPrefix)
+}
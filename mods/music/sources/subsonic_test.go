@@ -0,0 +1,111 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/subsonic"
+)
+
+// fakeSubsonic implements the few Subsonic endpoints the provider uses.
+func fakeSubsonic() *httptest.Server {
+	song := func(id, title string) string {
+		return fmt.Sprintf(`{"id":%q,"title":%q,"artist":"Daft Punk","album":"Discovery","duration":320,"coverArt":"al-1"}`, id, title)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var body string
+
+		switch strings.TrimPrefix(r.URL.Path, "/rest/") {
+		case "search3":
+			if q.Get("songCount") == "1" && strings.Contains(q.Get("query"), "one more") {
+				body = `"searchResult3":{"song":[` + song("s1", "One More Time") + `]}`
+			} else if q.Get("albumCount") == "1" && q.Get("query") == "discovery" {
+				body = `"searchResult3":{"album":[{"id":"al-1","name":"Discovery"}]}`
+			} else {
+				body = `"searchResult3":{}`
+			}
+		case "getAlbum":
+			body = `"album":{"id":"al-1","name":"Discovery","song":[` + song("s1", "One More Time") + `,` + song("s2", "Aerodynamic") + `]}`
+		case "getPlaylists":
+			body = `"playlists":{"playlist":[{"id":"pl-1","name":"Friday","songCount":1}]}`
+		case "getPlaylist":
+			body = `"playlist":{"id":"pl-1","name":"Friday","entry":[` + song("s2", "Aerodynamic") + `]}`
+		case "getSong":
+			body = `"song":` + song(q.Get("id"), "Digital Love")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintf(w, `{"subsonic-response":{"status":"ok","version":"1.16.1",%v}}`, body)
+	}))
+}
+
+func TestResolveSubsonic(t *testing.T) {
+	srv := fakeSubsonic()
+	defer srv.Close()
+
+	provider := NewSubsonicProvider(subsonic.NewClient(srv.URL, "alice", "secret"))
+	registry := NewRegistryWithProviders(NewStreamProvider(), provider, NewYoutubeSearchProvider())
+
+	songs, err := registry.Resolve(context.Background(), "subsonic:one more time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 1 || songs[0].Title != "Daft Punk - One More Time" || songs[0].SongID != "subsonic-s1" || songs[0].Duration != 320*time.Second || songs[0].Source != media.SourceStream {
+		t.Fatalf("unexpected songs: %+v", songs)
+	}
+
+	// Signed URLs are only built by Refresh, songs are shown and saved
+	if songs[0].Filepath != "" || songs[0].Thumbnail.URL != "" || !NeedsSignedURL(songs[0]) {
+		t.Errorf("song holds media URLs: %+v", songs[0])
+	}
+	signed, err := registry.Refresh(songs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := url.Parse(signed.Filepath)
+	if err != nil || stream.Path != "/rest/stream" || stream.Query().Get("id") != "s1" || stream.Query().Get("t") == "" {
+		t.Errorf("unexpected stream URL: %v", signed.Filepath)
+	}
+	if songs[0].Filepath != "" {
+		t.Errorf("Refresh modified the song: %+v", songs[0])
+	}
+
+	tests := map[string][]string{
+		"subsonic:album:discovery": {"s1", "s2"},
+		"subsonic:playlist:friday": {"s2"},
+		"subsonic:song:s3":         {"s3"},
+	}
+	for query, want := range tests {
+		songs, err := registry.Resolve(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Resolve(%q) returned error: %v", query, err)
+		}
+
+		var ids []string
+		for _, song := range songs {
+			ids = append(ids, strings.TrimPrefix(song.SongID, "subsonic-"))
+		}
+		if strings.Join(ids, ",") != strings.Join(want, ",") {
+			t.Errorf("Resolve(%q) = %v, want %v", query, ids, want)
+		}
+	}
+
+	if _, err := registry.Resolve(context.Background(), "subsonic:playlist:monday"); err == nil || !strings.Contains(err.Error(), "Friday") {
+		t.Errorf("expected an error listing available playlists, got %v", err)
+	}
+
+	if NewSubsonicProvider(nil).Match("subsonic:anything") {
+		t.Error("unconfigured provider should not match")
+	}
+}
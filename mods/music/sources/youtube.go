@@ -1,20 +1,24 @@
 package sources
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"time"
 
 	"net/http"
 	"regexp"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/gookit/slog"
-	"github.com/keshon/melodix-player/mods/music/history"
+	"github.com/keshon/melodix-player/internal/config"
 	"github.com/keshon/melodix-player/mods/music/media"
+	"github.com/keshon/melodix-player/mods/music/utils"
 
 	kkdai_youtube "github.com/kkdai/youtube/v2"
 )
@@ -23,7 +27,6 @@ type IYoutube interface {
 	FetchOneByURL(url string) (*media.Song, error)
 	FetchManyByURL(url string) ([]*media.Song, error)
 	FetchManyByManyURLs(urls []string) ([]*media.Song, error)
-	FetchManyByManyIDs(guildID string, ids []int) ([]*media.Song, error)
 	FetchManyByTitle(title string) ([]*media.Song, error)
 }
 
@@ -31,127 +34,175 @@ type Youtube struct {
 	youtubeClient *kkdai_youtube.Client
 }
 
+// NewYoutube returns the resolver selected by youtube.resolver, falling back to
+// the other implementation when a yt-dlp binary is configured.
 func NewYoutube() IYoutube {
-	return &Youtube{
-		youtubeClient: &kkdai_youtube.Client{},
+	builtin := newKkdaiYoutube()
+
+	conf, err := config.NewConfig()
+	if err != nil || conf.YoutubeYtDlpPath == "" {
+		return builtin
 	}
+
+	ytdlp := NewYtDlp(conf.YoutubeYtDlpPath)
+	if conf.YoutubeResolver == "ytdlp" {
+		return NewFallbackYoutube(ytdlp, builtin)
+	}
+	return NewFallbackYoutube(builtin, ytdlp)
 }
 
-func (y *Youtube) parseSongInfo(url string) (*media.Song, error) {
-	// Experimental
-	y.youtubeClient.HTTPClient = &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
+func newKkdaiYoutube() IYoutube {
+	return &Youtube{
+		youtubeClient: &kkdai_youtube.Client{
+			// Experimental
+			HTTPClient: &http.Client{
+				Transport: &http.Transport{
+					Proxy: http.ProxyFromEnvironment,
+					DialContext: (&net.Dialer{
+						Timeout: 30 * time.Second,
+					}).DialContext,
+					MaxIdleConns:          10,
+					IdleConnTimeout:       30 * time.Second,
+					TLSHandshakeTimeout:   10 * time.Second,
+					ExpectContinueTimeout: 1 * time.Second,
+				},
 				Timeout: 30 * time.Second,
-			}).DialContext,
-			MaxIdleConns:          10,
-			IdleConnTimeout:       30 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
+			},
 		},
-		Timeout: 30 * time.Second,
 	}
+}
 
-	song, err := y.youtubeClient.GetVideo(url)
+// parseSongInfo returns the video along with its media URL.
+func (y *Youtube) parseSongInfo(url string) (*media.Song, error) {
+	video, err := y.youtubeClient.GetVideo(url)
 	if err != nil {
 		return nil, err
 	}
 
+	formats := video.Formats.WithAudioChannels()
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no audio format found for %v", url)
+	}
+
+	song := videoSong(video, url)
+	song.Filepath = formats[0].URL
+	return song, nil
+}
+
+// parseVideoInfo returns the video without its media URL, which is resolved
+// right before playing (see NeedsRefresh).
+func (y *Youtube) parseVideoInfo(url string) (*media.Song, error) {
+	video, err := y.youtubeClient.GetVideo(url)
+	if err != nil {
+		return nil, err
+	}
+	return videoSong(video, url), nil
+}
+
+func videoSong(video *kkdai_youtube.Video, url string) *media.Song {
 	var thumbnail media.Thumbnail
-	if len(song.Thumbnails) > 0 {
-		thumbnail = media.Thumbnail(song.Thumbnails[0])
+	if len(video.Thumbnails) > 0 {
+		thumbnail = media.Thumbnail(video.Thumbnails[0])
 	}
 
 	return &media.Song{
-		Title:     song.Title,
+		Title:     video.Title,
 		URL:       url,
-		Filepath:  song.Formats.WithAudioChannels()[0].URL,
-		Duration:  song.Duration,
+		Duration:  video.Duration,
 		Thumbnail: thumbnail,
-		SongID:    song.ID,
+		SongID:    video.ID,
 		Source:    media.SourceYouTube,
-	}, nil
+	}
 }
 
 func (y *Youtube) parseSongOrPlaylistInfo(url string) ([]*media.Song, error) {
-	var songs []*media.Song
-
-	if strings.Contains(url, "list=") {
-		// It's a playlist
-		playlistID := y.extractPlaylistID(url)
-		playlistVideos, err := y.youtubeClient.GetPlaylist(playlistID)
+	if !strings.Contains(url, "list=") {
+		// It's a single song
+		song, err := y.parseVideoInfo(url)
 		if err != nil {
-			if err.Error() == "extractPlaylistID failed: no playlist detected or invalid playlist ID" {
-				// we assume it's a 'Youtube Mix Playlist' that kkdai_youtube doesn't support
-				slog.Warn("Error fetching playlist, trying to fetchsongs via regex from url", url)
-
-				tracks, err := y.getVideoURLsFromYoutubeMixPlaylist(url)
-				if err != nil {
-					return nil, err
-				}
+			return nil, err
+		}
+		return []*media.Song{song}, nil
+	}
 
-				// slog.Warn(tracks)
+	// It's a playlist
+	playlistID := y.extractPlaylistID(url)
+	playlistVideos, err := y.youtubeClient.GetPlaylist(playlistID)
+	if err != nil {
+		if err.Error() != "extractPlaylistID failed: no playlist detected or invalid playlist ID" {
+			return nil, err
+		}
 
-				tracks = y.removeDuplicateStr(tracks)
+		// we assume it's a 'Youtube Mix Playlist' that kkdai_youtube doesn't support
+		slog.Warn("Error fetching playlist, trying to fetchsongs via regex from url", url)
 
-				playlistVideos = &kkdai_youtube.Playlist{
+		tracks, err := y.getVideoURLsFromYoutubeMixPlaylist(url)
+		if err != nil {
+			return nil, err
+		}
 
-					Videos: make([]*kkdai_youtube.PlaylistEntry, len(tracks)),
-				}
+		return y.parseSongsInfo(y.removeDuplicateStr(tracks)), nil
+	}
 
-				// Populate the Videos field
-				for i, track := range tracks {
-					playlistVideos.Videos[i] = &kkdai_youtube.PlaylistEntry{
-						ID: track,
-					}
-				}
-			} else {
-				return nil, err
-			}
+	// Playlist entries carry everything needed to queue the songs, their media
+	// URLs are resolved right before playing (see NeedsRefresh)
+	var songs []*media.Song
+	for _, video := range playlistVideos.Videos {
+		var thumbnail media.Thumbnail
+		if len(video.Thumbnails) > 0 {
+			thumbnail = media.Thumbnail(video.Thumbnails[0])
 		}
 
-		var wg sync.WaitGroup
+		songs = append(songs, &media.Song{
+			Title:     video.Title,
+			URL:       fmt.Sprintf("https://www.youtube.com/watch?v=%s", video.ID),
+			Duration:  video.Duration,
+			Thumbnail: thumbnail,
+			SongID:    video.ID,
+			Source:    media.SourceYouTube,
+		})
+	}
+
+	return songs, nil
+}
 
-		videoIndex := make(map[string]int)
-		for i, video := range playlistVideos.Videos {
-			videoIndex[video.ID] = i
-		}
+// YoutubeResolveWorkers bounds how many videos are fetched at once.
+var YoutubeResolveWorkers = 4
 
-		for _, video := range playlistVideos.Videos {
-			wg.Add(1)
-			go func(videoID string) {
-				defer wg.Done()
+// parseSongsInfo fetches videos in parallel without their media URLs, keeping
+// their order and skipping failed ones.
+func (y *Youtube) parseSongsInfo(videoIDs []string) []*media.Song {
+	var wg sync.WaitGroup
 
-				videoURL := fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID)
-				song, err := y.parseSongInfo(videoURL)
-				if err != nil {
-					fmt.Printf("Error fetching song for video ID %s: %v\n", videoID, err)
-					return
-				}
+	songs := make([]*media.Song, len(videoIDs))
+	indexes := make(chan int)
 
-				songs = append(songs, song)
-			}(video.ID)
-		}
+	for w := 0; w < min(YoutubeResolveWorkers, len(videoIDs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		wg.Wait()
+			for i := range indexes {
+				videoURL := fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoIDs[i])
+				song, err := y.parseVideoInfo(videoURL)
+				if err != nil {
+					slog.Warnf("Error fetching song for video ID %s: %v", videoIDs[i], err)
+					continue
+				}
 
-		sort.SliceStable(songs, func(i, j int) bool {
-			indexI := videoIndex[songs[i].SongID]
-			indexJ := videoIndex[songs[j].SongID]
+				songs[i] = song
+			}
+		}()
+	}
 
-			return indexI < indexJ
-		})
-	} else {
-		// It's a single song
-		song, err := y.parseSongInfo(url)
-		if err != nil {
-			return nil, err
-		}
-		songs = append(songs, song)
+	for i := range videoIDs {
+		indexes <- i
 	}
+	close(indexes)
 
-	return songs, nil
+	wg.Wait()
+
+	return slices.DeleteFunc(songs, func(song *media.Song) bool { return song == nil })
 }
 
 func (y *Youtube) extractPlaylistID(url string) string {
@@ -216,28 +267,6 @@ func (y *Youtube) FetchManyByManyURLs(urls []string) ([]*media.Song, error) {
 	return songs, nil
 }
 
-// -- ID --
-func (y *Youtube) FetchManyByManyIDs(guildID string, ids []int) ([]*media.Song, error) {
-	h := history.NewHistory()
-	var songs []*media.Song
-
-	for _, id := range ids {
-		track, err := h.GetTrackFromHistory(guildID, uint(id))
-		if err != nil {
-			return nil, fmt.Errorf("error getting track from history with ID %v", id)
-		}
-
-		song, err := y.parseSongOrPlaylistInfo(track.URL)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching new songs from URL: %v", err)
-		}
-
-		songs = append(songs, song...)
-	}
-
-	return songs, nil
-}
-
 // -- Title --
 func (y *Youtube) FetchManyByTitle(title string) ([]*media.Song, error) {
 	var songs []*media.Song
@@ -256,7 +285,7 @@ func (y *Youtube) FetchManyByTitle(title string) ([]*media.Song, error) {
 }
 
 func (y *Youtube) getVideoURLFromTitle(title string) (string, error) {
-	searchURL := fmt.Sprintf("https://www.youtube.com/results?search_query=%v", strings.ReplaceAll(title, " ", "+"))
+	searchURL := fmt.Sprintf("https://www.youtube.com/results?search_query=%v", url.QueryEscape(title))
 
 	resp, err := http.Get(searchURL)
 	if err != nil {
@@ -342,3 +371,115 @@ func (y *Youtube) removeDuplicateStr(strSlice []string) []string {
 	}
 	return list
 }
+
+// YoutubeProvider resolves YouTube video and playlist URLs.
+type YoutubeProvider struct {
+	youtube IYoutube
+}
+
+func NewYoutubeProvider() Provider {
+	return &YoutubeProvider{youtube: NewYoutube()}
+}
+
+func (p *YoutubeProvider) Name() string {
+	return "youtube"
+}
+
+func (p *YoutubeProvider) Match(query string) bool {
+	return utils.IsYouTubeURL(query)
+}
+
+func (p *YoutubeProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	songs, err := p.youtube.FetchManyByURL(query)
+	if err != nil {
+		return nil, err
+	}
+
+	videoID, startAt := youtubeStartAt(query)
+	if startAt > 0 {
+		for i, song := range songs {
+			if song.SongID == videoID || len(songs) == 1 {
+				songs[i].StartAt = startAt
+				break
+			}
+		}
+	}
+
+	return songs, nil
+}
+
+func (p *YoutubeProvider) Refresh(song *media.Song) (*media.Song, error) {
+	if song.Source != media.SourceYouTube {
+		return nil, ErrUnsupported
+	}
+
+	refreshed, err := p.youtube.FetchOneByURL(song.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshed.StartAt = song.StartAt
+	return refreshed, nil
+}
+
+// youtubeStartAt returns the video ID and the start position set by the `t` or
+// `start` parameter of a YouTube URL (`90`, `90s` or `1h2m3s`).
+func youtubeStartAt(rawURL string) (string, time.Duration) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", 0
+	}
+
+	query := parsedURL.Query()
+	videoID := query.Get("v")
+	if strings.Contains(parsedURL.Host, "youtu.be") {
+		videoID = strings.Trim(parsedURL.Path, "/")
+	}
+
+	value := query.Get("t")
+	if value == "" {
+		value = query.Get("start")
+	}
+	if value == "" {
+		return videoID, 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return videoID, time.Duration(seconds) * time.Second
+	}
+
+	startAt, err := time.ParseDuration(value)
+	if err != nil || startAt < 0 {
+		return videoID, 0
+	}
+	return videoID, startAt
+}
+
+// YoutubeSearchProvider treats any query as a YouTube title search.
+type YoutubeSearchProvider struct {
+	youtube IYoutube
+}
+
+func NewYoutubeSearchProvider() Provider {
+	return &YoutubeSearchProvider{youtube: NewYoutube()}
+}
+
+func (p *YoutubeSearchProvider) Name() string {
+	return "youtube_search"
+}
+
+func (p *YoutubeSearchProvider) Match(query string) bool {
+	return strings.TrimSpace(query) != ""
+}
+
+func (p *YoutubeSearchProvider) FreeText() bool {
+	return true
+}
+
+func (p *YoutubeSearchProvider) Resolve(ctx context.Context, query string) ([]*media.Song, error) {
+	return p.youtube.FetchManyByTitle(query)
+}
+
+func (p *YoutubeSearchProvider) Refresh(song *media.Song) (*media.Song, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,27 @@
+package sources
+
+import (
+	"testing"
+	"time"
+)
+
+func TestYoutubeStartAt(t *testing.T) {
+	tests := []struct {
+		url     string
+		videoID string
+		startAt time.Duration
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ", 0},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=43", "dQw4w9WgXcQ", 43 * time.Second},
+		{"https://youtu.be/dQw4w9WgXcQ?t=1m30s", "dQw4w9WgXcQ", 90 * time.Second},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=RDdQw4w9WgXcQ&start=1h0m5s", "dQw4w9WgXcQ", time.Hour + 5*time.Second},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=soon", "dQw4w9WgXcQ", 0},
+	}
+
+	for _, tt := range tests {
+		videoID, startAt := youtubeStartAt(tt.url)
+		if videoID != tt.videoID || startAt != tt.startAt {
+			t.Errorf("youtubeStartAt(%q) = %q, %v, want %q, %v", tt.url, videoID, startAt, tt.videoID, tt.startAt)
+		}
+	}
+}
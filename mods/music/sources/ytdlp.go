@@ -0,0 +1,223 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gookit/slog"
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+// YtDlpTimeout bounds a single yt-dlp run, playlists included.
+var YtDlpTimeout = 2 * time.Minute
+
+// YtDlp resolves YouTube songs by running a yt-dlp binary with JSON output.
+type YtDlp struct {
+	binaryPath string
+}
+
+type ytDlpThumbnail struct {
+	URL    string `json:"url"`
+	Width  uint   `json:"width"`
+	Height uint   `json:"height"`
+}
+
+type ytDlpInfo struct {
+	Type       string           `json:"_type"`
+	ID         string           `json:"id"`
+	Title      string           `json:"title"`
+	URL        string           `json:"url"`
+	WebpageURL string           `json:"webpage_url"`
+	Duration   float64          `json:"duration"`
+	Thumbnail  string           `json:"thumbnail"`
+	Thumbnails []ytDlpThumbnail `json:"thumbnails"`
+	Entries    []*ytDlpInfo     `json:"entries"`
+}
+
+func NewYtDlp(binaryPath string) IYoutube {
+	return &YtDlp{binaryPath: binaryPath}
+}
+
+func (y *YtDlp) run(args ...string) (*ytDlpInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), YtDlpTimeout)
+	defer cancel()
+
+	args = append([]string{"--dump-single-json", "--no-warnings", "--format", "bestaudio/best"}, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, y.binaryPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// With --ignore-errors yt-dlp exits non-zero when some playlist entries are
+	// unavailable but still prints the rest
+	runErr := cmd.Run()
+	if stdout.Len() == 0 {
+		if runErr == nil {
+			runErr = errors.New("empty output")
+		}
+		return nil, fmt.Errorf("yt-dlp failed: %v %v", runErr, strings.TrimSpace(stderr.String()))
+	}
+
+	var info ytDlpInfo
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
+		return nil, fmt.Errorf("error parsing yt-dlp output: %v", err)
+	}
+
+	return &info, nil
+}
+
+func (y *YtDlp) songs(info *ytDlpInfo) []*media.Song {
+	if info.Type != "playlist" {
+		return []*media.Song{y.song(info)}
+	}
+
+	var songs []*media.Song
+	for _, entry := range info.Entries {
+		// Unavailable entries are left as null
+		if entry == nil || entry.URL == "" {
+			continue
+		}
+		songs = append(songs, y.song(entry))
+	}
+	return songs
+}
+
+func (y *YtDlp) song(info *ytDlpInfo) *media.Song {
+	thumbnail := media.Thumbnail{URL: info.Thumbnail}
+	if len(info.Thumbnails) > 0 {
+		thumbnail = media.Thumbnail(info.Thumbnails[len(info.Thumbnails)-1])
+	}
+
+	pageURL, filepath := info.WebpageURL, info.URL
+	if info.Type == "url" {
+		// Flat playlist entries only point to the video page
+		pageURL, filepath = info.URL, ""
+	}
+	if pageURL == "" {
+		pageURL = fmt.Sprintf("https://www.youtube.com/watch?v=%s", info.ID)
+	}
+
+	return &media.Song{
+		Title:     info.Title,
+		URL:       pageURL,
+		Filepath:  filepath,
+		Duration:  time.Duration(info.Duration * float64(time.Second)),
+		Thumbnail: thumbnail,
+		SongID:    info.ID,
+		Source:    media.SourceYouTube,
+	}
+}
+
+func (y *YtDlp) FetchOneByURL(url string) (*media.Song, error) {
+	info, err := y.run("--no-playlist", url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching new songs from URL: %v", err)
+	}
+
+	songs := y.songs(info)
+	if len(songs) == 0 {
+		return nil, fmt.Errorf("error fetching new songs from URL: no playable video found")
+	}
+	return songs[0], nil
+}
+
+func (y *YtDlp) FetchManyByURL(url string) ([]*media.Song, error) {
+	info, err := y.run("--yes-playlist", "--flat-playlist", "--ignore-errors", url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching new songs from URL: %v", err)
+	}
+
+	songs := y.songs(info)
+	if len(songs) == 0 {
+		return nil, fmt.Errorf("error fetching new songs from URL: no playable video found")
+	}
+	return songs, nil
+}
+
+func (y *YtDlp) FetchManyByManyURLs(urls []string) ([]*media.Song, error) {
+	var songs []*media.Song
+
+	for _, url := range urls {
+		song, err := y.FetchManyByURL(url)
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, song...)
+	}
+
+	return songs, nil
+}
+
+func (y *YtDlp) FetchManyByTitle(title string) ([]*media.Song, error) {
+	info, err := y.run("--no-playlist", "ytsearch1:"+title)
+	if err != nil {
+		return nil, fmt.Errorf("error getting YouTube video URL by title: %v", err)
+	}
+
+	songs := y.songs(info)
+	if len(songs) == 0 {
+		return nil, fmt.Errorf("error getting YouTube video URL by title: no video found for the given title")
+	}
+	return songs, nil
+}
+
+// FallbackYoutube asks the primary resolver first and the secondary one when it fails.
+type FallbackYoutube struct {
+	primary   IYoutube
+	secondary IYoutube
+}
+
+func NewFallbackYoutube(primary, secondary IYoutube) IYoutube {
+	return &FallbackYoutube{primary: primary, secondary: secondary}
+}
+
+func (f *FallbackYoutube) FetchOneByURL(url string) (*media.Song, error) {
+	song, err := f.primary.FetchOneByURL(url)
+	if err == nil {
+		return song, nil
+	}
+
+	slog.Warnf("Primary YouTube resolver failed, falling back: %v", err)
+	return f.secondary.FetchOneByURL(url)
+}
+
+func (f *FallbackYoutube) FetchManyByURL(url string) ([]*media.Song, error) {
+	songs, err := f.primary.FetchManyByURL(url)
+	if err == nil && len(songs) > 0 {
+		return songs, nil
+	}
+
+	slog.Warnf("Primary YouTube resolver failed, falling back: %v", err)
+	return f.secondary.FetchManyByURL(url)
+}
+
+func (f *FallbackYoutube) FetchManyByManyURLs(urls []string) ([]*media.Song, error) {
+	var songs []*media.Song
+
+	for _, url := range urls {
+		song, err := f.FetchManyByURL(url)
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, song...)
+	}
+
+	return songs, nil
+}
+
+func (f *FallbackYoutube) FetchManyByTitle(title string) ([]*media.Song, error) {
+	songs, err := f.primary.FetchManyByTitle(title)
+	if err == nil && len(songs) > 0 {
+		return songs, nil
+	}
+
+	slog.Warnf("Primary YouTube resolver failed, falling back: %v", err)
+	return f.secondary.FetchManyByTitle(title)
+}
@@ -0,0 +1,121 @@
+package sources
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/mods/music/media"
+)
+
+const fakeYtDlpScript = `#!/bin/sh
+for last; do :; done
+case "$last" in
+ytsearch1:*)
+	echo '{"_type":"playlist","entries":[{"id":"abc","title":"Found","url":"https://cdn/abc","webpage_url":"https://www.youtube.com/watch?v=abc","duration":61.5}]}'
+	;;
+*list=*)
+	echo '{"_type":"playlist","title":"Mix","entries":[{"_type":"url","id":"one","title":"One","url":"https://www.youtube.com/watch?v=one","duration":10},null,{"_type":"url","id":"two","title":"Two","url":"https://www.youtube.com/watch?v=two","duration":20}]}'
+	exit 1
+	;;
+*broken*)
+	echo "ERROR: Video unavailable" >&2
+	exit 1
+	;;
+*)
+	echo '{"id":"xyz","title":"Single","url":"https://cdn/xyz","webpage_url":"https://www.youtube.com/watch?v=xyz","duration":200,"thumbnails":[{"url":"small"},{"url":"large","width":1280,"height":720}]}'
+	;;
+esac
+`
+
+func fakeYtDlp(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake yt-dlp is a shell script")
+	}
+
+	path := filepath.Join(t.TempDir(), "yt-dlp")
+	if err := os.WriteFile(path, []byte(fakeYtDlpScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestYtDlp(t *testing.T) {
+	yt := NewYtDlp(fakeYtDlp(t))
+
+	song, err := yt.FetchOneByURL("https://www.youtube.com/watch?v=xyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &media.Song{
+		Title:     "Single",
+		URL:       "https://www.youtube.com/watch?v=xyz",
+		Filepath:  "https://cdn/xyz",
+		Duration:  200 * time.Second,
+		Thumbnail: media.Thumbnail{URL: "large", Width: 1280, Height: 720},
+		SongID:    "xyz",
+		Source:    media.SourceYouTube,
+	}
+	if !reflect.DeepEqual(song, want) {
+		t.Errorf("got %+v, want %+v", song, want)
+	}
+
+	songs, err := yt.FetchManyByURL("https://www.youtube.com/watch?v=one&list=RDone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 2 || songs[0].SongID != "one" || songs[1].URL != "https://www.youtube.com/watch?v=two" || songs[1].Filepath != "" {
+		t.Errorf("unexpected playlist songs: %+v", songs)
+	}
+
+	songs, err = yt.FetchManyByTitle("never gonna give you up")
+	if err != nil || len(songs) != 1 || songs[0].Title != "Found" || songs[0].Duration != 61500*time.Millisecond {
+		t.Errorf("unexpected search result: %+v, %v", songs, err)
+	}
+
+	if _, err := yt.FetchOneByURL("https://www.youtube.com/watch?v=broken"); err == nil || !strings.Contains(err.Error(), "Video unavailable") {
+		t.Errorf("expected yt-dlp error, got %v", err)
+	}
+}
+
+type failingYoutube struct{}
+
+func (failingYoutube) FetchOneByURL(url string) (*media.Song, error) {
+	return nil, errors.New("broken")
+}
+
+func (failingYoutube) FetchManyByURL(url string) ([]*media.Song, error) {
+	return nil, errors.New("broken")
+}
+
+func (failingYoutube) FetchManyByManyURLs(urls []string) ([]*media.Song, error) {
+	return nil, errors.New("broken")
+}
+
+func (failingYoutube) FetchManyByTitle(title string) ([]*media.Song, error) {
+	return nil, errors.New("broken")
+}
+
+func TestFallbackYoutube(t *testing.T) {
+	yt := NewFallbackYoutube(failingYoutube{}, NewYtDlp(fakeYtDlp(t)))
+
+	songs, err := yt.FetchManyByTitle("anything")
+	if err != nil || len(songs) != 1 || songs[0].SongID != "abc" {
+		t.Errorf("expected the fallback resolver result, got %+v, %v", songs, err)
+	}
+
+	songs, err = yt.FetchManyByManyURLs([]string{"https://www.youtube.com/watch?v=xyz", "https://www.youtube.com/playlist?list=PL1"})
+	if err != nil || len(songs) != 3 {
+		t.Errorf("unexpected songs: %+v, %v", songs, err)
+	}
+
+	if _, err := NewFallbackYoutube(failingYoutube{}, failingYoutube{}).FetchOneByURL("x"); err == nil {
+		t.Error("expected an error when both resolvers fail")
+	}
+}
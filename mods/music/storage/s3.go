@@ -0,0 +1,445 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	emptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" // sha256 of nothing
+	amzDateFormat   = "20060102T150405Z"
+	maxPresign      = 7 * 24 * time.Hour
+)
+
+type S3Options struct {
+	Endpoint      string // e.g. https://s3.amazonaws.com or http://minio:9000
+	Region        string
+	Bucket        string
+	AccessKey     string
+	SecretKey     string
+	PathStyle     bool // bucket in the path instead of the host name, needed by MinIO
+	PresignExpiry time.Duration
+}
+
+// S3 stores files in an S3 compatible bucket, keyed by their path relative to
+// the cache folder. The local cache folder only stages files being imported.
+type S3 struct {
+	options S3Options
+	root    string
+	client  *http.Client
+	now     func() time.Time
+}
+
+func NewS3(options S3Options, root string) IStorage {
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+	if options.PresignExpiry <= 0 || options.PresignExpiry > maxPresign {
+		options.PresignExpiry = maxPresign
+	}
+	options.Endpoint = strings.TrimSuffix(options.Endpoint, "/")
+
+	return &S3{
+		options: options,
+		root:    root,
+		client:  &http.Client{},
+		now:     time.Now,
+	}
+}
+
+func (s *S3) Stat(ctx context.Context, p string) (*Object, error) {
+	k, err := key(s.root, p)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, k, nil, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &Object{Path: p, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// list returns the objects and folders directly in dir.
+func (s *S3) list(ctx context.Context, dir string) ([]Object, []string, error) {
+	prefix := ""
+	if !samePath(dir, s.root) {
+		k, err := key(s.root, dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		prefix = k + "/"
+	}
+
+	var objects []Object
+	var folders []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}, "delimiter": {"/"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing bucket listing: %v", err)
+		}
+
+		for _, content := range result.Contents {
+			name := strings.TrimPrefix(content.Key, prefix)
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			objects = append(objects, Object{Path: filepath.Join(dir, name), Size: content.Size, ModTime: content.LastModified})
+		}
+		for _, common := range result.CommonPrefixes {
+			if name := strings.TrimSuffix(strings.TrimPrefix(common.Prefix, prefix), "/"); name != "" {
+				folders = append(folders, name)
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sortObjects(objects)
+	sort.Strings(folders)
+	return objects, folders, nil
+}
+
+func (s *S3) List(ctx context.Context, dir string) ([]Object, error) {
+	objects, _, err := s.list(ctx, dir)
+	return objects, err
+}
+
+func (s *S3) Folders(ctx context.Context, dir string) ([]string, error) {
+	_, folders, err := s.list(ctx, dir)
+	return folders, err
+}
+
+func (s *S3) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	k, err := key(s.root, p)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, k, nil, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Import uploads a file of the local disk and removes it once stored.
+func (s *S3) Import(ctx context.Context, localPath, p string) error {
+	k, err := key(s.root, p)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, k, nil, nil, file, info.Size())
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("error uploading %v: %v", localPath, err)
+	}
+	resp.Body.Close()
+
+	return os.Remove(localPath)
+}
+
+// Rename copies the object to its new key and deletes the old one.
+func (s *S3) Rename(ctx context.Context, from, to string) error {
+	fromKey, err := key(s.root, from)
+	if err != nil {
+		return err
+	}
+	toKey, err := key(s.root, to)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"X-Amz-Copy-Source": {escapePath("/" + s.options.Bucket + "/" + fromKey)}}
+	resp, err := s.do(ctx, http.MethodPut, toKey, nil, header, nil, 0)
+	if err != nil {
+		return fmt.Errorf("error copying %v: %v", from, err)
+	}
+	resp.Body.Close()
+
+	return s.Remove(ctx, from)
+}
+
+func (s *S3) Remove(ctx context.Context, p string) error {
+	k, err := key(s.root, p)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, k, nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Location returns a presigned URL ffmpeg can read the file from.
+func (s *S3) Location(ctx context.Context, p string) (string, error) {
+	if _, err := s.Stat(ctx, p); err != nil {
+		return "", err
+	}
+	k, err := key(s.root, p)
+	if err != nil {
+		return "", err
+	}
+	return s.presign(http.MethodGet, k, s.options.PresignExpiry), nil
+}
+
+func (s *S3) IsLocal() bool {
+	return false
+}
+
+// url returns the URL of the key, or of the bucket when key is empty.
+func (s *S3) url(k string) *url.URL {
+	u, err := url.Parse(s.options.Endpoint)
+	if err != nil || u.Host == "" {
+		u = &url.URL{Scheme: "https", Host: s.options.Endpoint}
+	}
+
+	if s.options.PathStyle {
+		u.Path = path.Join("/", s.options.Bucket, k)
+	} else {
+		u.Host = s.options.Bucket + "." + u.Host
+		u.Path = "/" + k
+	}
+	if k == "" && s.options.PathStyle {
+		u.Path += "/"
+	}
+	// Send the path encoded the way it is signed
+	u.RawPath = escapePath(u.Path)
+	return u
+}
+
+// do sends a signed request and turns error responses into errors, a missing
+// key being ErrNotExist.
+func (s *S3) do(ctx context.Context, method, k string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	u := s.url(k)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	payload := emptyPayload
+	if body != nil {
+		payload = unsignedPayload
+	}
+	s.sign(req, payload)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotExist
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%v %v: %v %s", method, k, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to the request,
+// signing the host and every header set on the request.
+func (s *S3) sign(req *http.Request, payload string) {
+	now := s.now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+
+	values := map[string]string{"host": req.URL.Host}
+	for name, v := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "authorization" || lower == "user-agent" {
+			continue
+		}
+		trimmed := make([]string, len(v))
+		for i, value := range v {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		values[lower] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + values[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		req.URL.RawQuery,
+		headers.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := s.scope(now)
+	signature := s.signature(now, scope, canonical)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v", s.options.AccessKey, scope, signedHeaders, signature))
+}
+
+// presign returns a URL carrying its signature in the query, valid for expiry.
+func (s *S3) presign(method, k string, expiry time.Duration) string {
+	now := s.now().UTC()
+	u := s.url(k)
+	scope := s.scope(now)
+
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.options.AccessKey + "/" + scope},
+		"X-Amz-Date":          {now.Format(amzDateFormat)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expiry.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	canonical := strings.Join([]string{
+		method,
+		escapePath(u.Path),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	u.RawQuery += "&X-Amz-Signature=" + s.signature(now, scope, canonical)
+	return u.String()
+}
+
+func (s *S3) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.options.Region + "/s3/aws4_request"
+}
+
+func (s *S3) signature(now time.Time, scope, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format(amzDateFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.options.SecretKey), now.Format("20060102"))
+	signingKey = hmacSHA256(signingKey, s.options.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes the query sorted by key with spaces as %20.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath encodes every path segment, keeping the slashes.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape percent-encodes everything but unreserved characters, as required by
+// signature version 4.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,213 @@
+// Package storage keeps cached audio files on the local disk or in an S3
+// compatible bucket. Files are addressed by their path in the cache folder
+// (e.g. cache/<guild>/song.mp3), the path stored for tracks in the database, so
+// bots sharing a bucket and a database share the same library.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/config"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// ErrNotExist is returned for paths without a file.
+var ErrNotExist = errors.New("file does not exist")
+
+// Object describes a stored file.
+type Object struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Name returns the file name of the object.
+func (o Object) Name() string {
+	return filepath.Base(o.Path)
+}
+
+type IStorage interface {
+	Stat(ctx context.Context, path string) (*Object, error)
+	List(ctx context.Context, dir string) ([]Object, error)
+	Folders(ctx context.Context, dir string) ([]string, error)
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	Import(ctx context.Context, localPath, path string) error
+	Rename(ctx context.Context, from, to string) error
+	Remove(ctx context.Context, path string) error
+	Location(ctx context.Context, path string) (string, error)
+	IsLocal() bool
+}
+
+// NewStorage returns the storage of the configured backend for files of the
+// cache folder.
+func NewStorage(cacheFolder string) IStorage {
+	conf, err := config.NewConfig()
+	if err != nil {
+		slog.Errorf("Error loading config, storing files on the local disk: %v", err)
+		return NewLocal(cacheFolder)
+	}
+
+	if conf.StorageBackend != BackendS3 {
+		return NewLocal(cacheFolder)
+	}
+
+	return NewS3(S3Options{
+		Endpoint:      conf.StorageS3Endpoint,
+		Region:        conf.StorageS3Region,
+		Bucket:        conf.StorageS3Bucket,
+		AccessKey:     conf.StorageS3AccessKey,
+		SecretKey:     conf.StorageS3SecretKey,
+		PathStyle:     conf.StorageS3PathStyle,
+		PresignExpiry: time.Duration(conf.StorageS3PresignMinutes) * time.Minute,
+	}, cacheFolder)
+}
+
+// Local stores files in the cache folder itself.
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) IStorage {
+	return &Local{root: root}
+}
+
+func (l *Local) Stat(ctx context.Context, path string) (*Object, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%v is a folder", path)
+	}
+	return &Object{Path: path, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (l *Local) List(ctx context.Context, dir string) ([]Object, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, Object{Path: filepath.Join(dir, entry.Name()), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return objects, nil
+}
+
+func (l *Local) Folders(ctx context.Context, dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var folders []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			folders = append(folders, entry.Name())
+		}
+	}
+	return folders, nil
+}
+
+func (l *Local) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	return file, err
+}
+
+// Import moves a file of the local disk to path, files written to the cache
+// folder directly are already in place.
+func (l *Local) Import(ctx context.Context, localPath, path string) error {
+	if filepath.Clean(localPath) == filepath.Clean(path) {
+		return nil
+	}
+	return l.Rename(ctx, localPath, path)
+}
+
+func (l *Local) Rename(ctx context.Context, from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func (l *Local) Remove(ctx context.Context, path string) error {
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	return err
+}
+
+// Location returns the file itself.
+func (l *Local) Location(ctx context.Context, path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotExist
+	}
+	return path, nil
+}
+
+func (l *Local) IsLocal() bool {
+	return true
+}
+
+// key turns a path of the cache folder into a slash separated key relative to it.
+func key(root, path string) (string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%v is not in the cache folder %v", path, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func sortObjects(objects []Object) {
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+}
@@ -0,0 +1,154 @@
+// Package storagetest provides an in-memory S3 compatible server to run the
+// S3 storage against in tests, in the manner of a local MinIO.
+package storagetest
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AccessKey = "minio"
+	SecretKey = "minio-secret"
+)
+
+type object struct {
+	data    []byte
+	modTime time.Time
+}
+
+// S3Server serves a single bucket with path style addressing. Requests must be
+// signed with AccessKey, either in the Authorization header or presigned.
+type S3Server struct {
+	*httptest.Server
+	Bucket string
+
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+func NewS3Server(bucket string) *S3Server {
+	s := &S3Server{Bucket: bucket, objects: map[string]object{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Keys returns the keys of the stored objects, sorted.
+func (s *S3Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.objects))
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *S3Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential="+AccessKey+"/") &&
+		!strings.HasPrefix(r.URL.Query().Get("X-Amz-Credential"), AccessKey+"/") {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.Bucket {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.list(w, r.URL.Query())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, key, obj.modTime, strings.NewReader(string(obj.data)))
+	case http.MethodPut:
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			source, _ = url.PathUnescape(source)
+			obj, ok := s.objects[strings.TrimPrefix(source, "/"+s.Bucket+"/")]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			s.objects[key] = object{data: obj.data, modTime: time.Now()}
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.objects[key] = object{data: data, modTime: time.Now()}
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type listContent struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+type listBucketResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	Contents       []listContent
+	CommonPrefixes []listPrefix
+	IsTruncated    bool
+}
+
+func (s *S3Server) list(w http.ResponseWriter, query url.Values) {
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+
+	result := listBucketResult{}
+	seen := map[string]bool{}
+	for k, obj := range s.objects {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(k, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			common := prefix + rest[:i+len(delimiter)]
+			if !seen[common] {
+				seen[common] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{Prefix: common})
+			}
+			continue
+		}
+		result.Contents = append(result.Contents, listContent{Key: k, Size: int64(len(obj.data)), LastModified: obj.modTime})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,219 @@
+// Package subsonic is a minimal client of the Subsonic API implemented by
+// Navidrome, Airsonic, Gonic and other self-hosted media servers.
+package subsonic
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiVersion = "1.16.1"
+	clientName = "melodix"
+)
+
+type Song struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+	Duration int    `json:"duration"` // seconds
+	CoverArt string `json:"coverArt"`
+}
+
+type Album struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Artist   string `json:"artist"`
+	CoverArt string `json:"coverArt"`
+	Songs    []Song `json:"song"`
+}
+
+type Playlist struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	SongCount int    `json:"songCount"`
+	Songs     []Song `json:"entry"`
+}
+
+type SearchResult struct {
+	Songs  []Song  `json:"song"`
+	Albums []Album `json:"album"`
+}
+
+// Error is a failed API response.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("subsonic error %d: %v", e.Code, e.Message)
+}
+
+type response struct {
+	Status        string        `json:"status"`
+	Error         *Error        `json:"error"`
+	SearchResult3 *SearchResult `json:"searchResult3"`
+	Song          *Song         `json:"song"`
+	Album         *Album        `json:"album"`
+	Playlist      *Playlist     `json:"playlist"`
+	Playlists     *struct {
+		Playlist []Playlist `json:"playlist"`
+	} `json:"playlists"`
+}
+
+// Client talks to a server with token authentication, a fresh salt per request.
+type Client struct {
+	BaseURL    string
+	User       string
+	Password   string
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL, user, password string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		User:       user,
+		Password:   password,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *Client) endpoint(method string, params url.Values) string {
+	if params == nil {
+		params = url.Values{}
+	}
+
+	salt := make([]byte, 8)
+	rand.Read(salt)
+	saltHex := hex.EncodeToString(salt)
+	token := md5.Sum([]byte(c.Password + saltHex))
+
+	params.Set("u", c.User)
+	params.Set("t", hex.EncodeToString(token[:]))
+	params.Set("s", saltHex)
+	params.Set("v", apiVersion)
+	params.Set("c", clientName)
+
+	return fmt.Sprintf("%v/rest/%v?%v", c.BaseURL, method, params.Encode())
+}
+
+func (c *Client) call(ctx context.Context, method string, params url.Values) (*response, error) {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("f", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(method, params), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %v: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error calling %v: HTTP status %v", method, resp.StatusCode)
+	}
+
+	var envelope struct {
+		Response response `json:"subsonic-response"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("error decoding %v response: %v", method, err)
+	}
+
+	if envelope.Response.Status != "ok" {
+		if envelope.Response.Error != nil {
+			return nil, envelope.Response.Error
+		}
+		return nil, fmt.Errorf("error calling %v: status %q", method, envelope.Response.Status)
+	}
+
+	return &envelope.Response, nil
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.call(ctx, "ping", nil)
+	return err
+}
+
+func (c *Client) Search(ctx context.Context, query string, songCount, albumCount int) (*SearchResult, error) {
+	params := url.Values{
+		"query":       {query},
+		"songCount":   {strconv.Itoa(songCount)},
+		"albumCount":  {strconv.Itoa(albumCount)},
+		"artistCount": {"0"},
+	}
+
+	resp, err := c.call(ctx, "search3", params)
+	if err != nil {
+		return nil, err
+	}
+	if resp.SearchResult3 == nil {
+		return &SearchResult{}, nil
+	}
+	return resp.SearchResult3, nil
+}
+
+func (c *Client) Song(ctx context.Context, id string) (*Song, error) {
+	resp, err := c.call(ctx, "getSong", url.Values{"id": {id}})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Song == nil {
+		return nil, fmt.Errorf("song %v not found", id)
+	}
+	return resp.Song, nil
+}
+
+func (c *Client) Album(ctx context.Context, id string) (*Album, error) {
+	resp, err := c.call(ctx, "getAlbum", url.Values{"id": {id}})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Album == nil {
+		return nil, fmt.Errorf("album %v not found", id)
+	}
+	return resp.Album, nil
+}
+
+func (c *Client) Playlists(ctx context.Context) ([]Playlist, error) {
+	resp, err := c.call(ctx, "getPlaylists", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Playlists == nil {
+		return nil, nil
+	}
+	return resp.Playlists.Playlist, nil
+}
+
+func (c *Client) Playlist(ctx context.Context, id string) (*Playlist, error) {
+	resp, err := c.call(ctx, "getPlaylist", url.Values{"id": {id}})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Playlist == nil {
+		return nil, fmt.Errorf("playlist %v not found", id)
+	}
+	return resp.Playlist, nil
+}
+
+// StreamURL is a signed URL ffmpeg can read the song from.
+func (c *Client) StreamURL(songID string) string {
+	return c.endpoint("stream", url.Values{"id": {songID}})
+}
@@ -0,0 +1,36 @@
+package subsonic
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		token := md5.Sum([]byte("secret" + q.Get("s")))
+
+		if r.URL.Path != "/music/rest/ping" || q.Get("u") != "alice" || q.Get("t") != hex.EncodeToString(token[:]) || q.Get("p") != "" {
+			fmt.Fprint(w, `{"subsonic-response":{"status":"failed","error":{"code":40,"message":"Wrong username or password"}}}`)
+			return
+		}
+		fmt.Fprint(w, `{"subsonic-response":{"status":"ok","version":"1.16.1"}}`)
+	}))
+	defer srv.Close()
+
+	if err := NewClient(srv.URL+"/music/", "alice", "secret").Ping(context.Background()); err != nil {
+		t.Errorf("ping failed: %v", err)
+	}
+
+	err := NewClient(srv.URL+"/music", "alice", "wrong").Ping(context.Background())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 40 {
+		t.Errorf("expected a wrong credentials error, got %v", err)
+	}
+}
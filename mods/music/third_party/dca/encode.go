@@ -14,6 +14,7 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -68,6 +69,8 @@ type EncodeOptions struct {
 	FfmpegBinaryPath        string           // Specify path to ffmpeg binary location
 	EncodingLineLog         bool             // Print encoding line one by one
 	UserAgent               string           // Override the User-Agent header.
+	InputOptions            []string         // Extra ffmpeg options placed before the input (ex. for HLS/DASH manifests)
+	StreamMap               string           // ffmpeg -map selector of the input stream to encode, defaults to "0:a"
 
 	// The ffmpeg audio filters to use, see https://ffmpeg.org/ffmpeg-filters.html#Audio-Filters for more info
 	// Leave empty to use no filters.
@@ -255,12 +258,17 @@ func (e *EncodeSession) run() {
 	// slog.Info("VBR", e.options.VBR)
 	// slog.Info("Volume", e.options.Volume)
 
+	streamMap := "0:a"
+	if e.options.StreamMap != "" {
+		streamMap = e.options.StreamMap
+	}
+
 	// Launch ffmpeg with a variety of different fruits and goodies mixed togheter
 	args := []string{
 		"-stats", // not need to specify, on by default
 		"-i", inFile,
 		"-vn",
-		"-map", "0:a",
+		"-map", streamMap,
 		"-acodec", "libopus",
 		"-f", "ogg",
 		"-vbr", vbrStr,
@@ -287,6 +295,12 @@ func (e *EncodeSession) run() {
 		args = append(reconnectArgs, args...)
 	}
 
+	// Input options come last so they can override the reconnect defaults
+	if len(e.options.InputOptions) > 0 {
+		inputIndex := slices.Index(args, "-i")
+		args = slices.Insert(args, inputIndex, e.options.InputOptions...)
+	}
+
 	filters := []string{
 		fmt.Sprintf("volume=%v", e.options.Volume),
 	}
@@ -0,0 +1,298 @@
+// Package watcher picks up files copied to the upload and cache folders without
+// waiting for an admin to run `uploaded extract` or `cached sync`.
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	embed "github.com/Clinet/discordgo-embed"
+	"github.com/bwmarrin/discordgo"
+	"github.com/fsnotify/fsnotify"
+	"github.com/gookit/slog"
+
+	"github.com/keshon/melodix-player/internal/config"
+	"github.com/keshon/melodix-player/internal/db"
+	"github.com/keshon/melodix-player/mods/music/cache"
+	"github.com/keshon/melodix-player/mods/music/jobs"
+)
+
+// Debounce is how long the folders must stay quiet before changes are processed,
+// so files still being copied are not picked up halfway.
+var Debounce = 5 * time.Second
+
+type IWatcher interface {
+	Start() error
+	Stop()
+}
+
+// Watcher extracts the audio of videos copied to the upload folder and syncs
+// files added to, renamed in or removed from the guild cache folders.
+type Watcher struct {
+	uploadsFolder string
+	cacheFolder   string
+	uploadGuildID string
+	repos         *db.Repositories
+	notify        func(message string)
+	watcher       *fsnotify.Watcher
+	done          chan struct{}
+
+	mu         sync.Mutex
+	extracting bool
+	rescan     chan struct{}
+}
+
+// NewWatcher posts summaries to the admin channel, or only logs them when
+// no admin channel is set.
+func NewWatcher(session *discordgo.Session, repos *db.Repositories) IWatcher {
+	conf, err := config.NewConfig()
+	if err != nil {
+		slog.Errorf("Error loading config: %v", err)
+		conf = config.Default()
+	}
+	adminChannelID := conf.DiscordAdminChannelID
+
+	notify := func(message string) {
+		slog.Info(message)
+		if adminChannelID == "" {
+			return
+		}
+
+		embedBody := embed.NewEmbed().
+			SetDescription(message).
+			SetColor(0x9f00d4).MessageEmbed
+
+		if _, err := session.ChannelMessageSendEmbed(adminChannelID, embedBody); err != nil {
+			slog.Errorf("Error sending message to the admin channel: %v", err)
+		}
+	}
+
+	return newWatcher(conf.StorageUploadDir, conf.StorageCacheDir, conf.WatcherUploadGuildID, repos, notify)
+}
+
+func newWatcher(uploadsFolder, cacheFolder, uploadGuildID string, repos *db.Repositories, notify func(message string)) *Watcher {
+	return &Watcher{
+		uploadsFolder: filepath.Clean(uploadsFolder),
+		cacheFolder:   filepath.Clean(cacheFolder),
+		uploadGuildID: uploadGuildID,
+		repos:         repos,
+		notify:        notify,
+		done:          make(chan struct{}),
+		rescan:        make(chan struct{}, 1),
+	}
+}
+
+func (w *Watcher) Start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating folder watcher: %v", err)
+	}
+
+	for _, folder := range []string{w.uploadsFolder, w.cacheFolder} {
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			watcher.Close()
+			return fmt.Errorf("error creating folder %v: %v", folder, err)
+		}
+		if err := watcher.Add(folder); err != nil {
+			watcher.Close()
+			return fmt.Errorf("error watching %v: %v", folder, err)
+		}
+	}
+
+	entries, err := os.ReadDir(w.cacheFolder)
+	if err != nil {
+		watcher.Close()
+		return fmt.Errorf("error reading cache folder: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && cache.IsGuildFolder(entry.Name()) {
+			if err := watcher.Add(filepath.Join(w.cacheFolder, entry.Name())); err != nil {
+				slog.Errorf("Error watching cache folder of guild %v: %v", entry.Name(), err)
+			}
+		}
+	}
+
+	w.watcher = watcher
+	go w.run()
+
+	slog.Infof("Watching %v and %v for new files", w.uploadsFolder, w.cacheFolder)
+	return nil
+}
+
+func (w *Watcher) Stop() {
+	if w.watcher != nil {
+		w.watcher.Close()
+		<-w.done
+	}
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	var debounce <-chan time.Time
+	uploaded := false
+	guilds := map[string]bool{}
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if strings.HasSuffix(event.Name, cache.PartialExt) || event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			dir := filepath.Dir(event.Name)
+			switch {
+			case dir == w.uploadsFolder:
+				uploaded = true
+			case dir == w.cacheFolder:
+				// A guild cache folder was created
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && cache.IsGuildFolder(filepath.Base(event.Name)) {
+					if err := w.watcher.Add(event.Name); err != nil {
+						slog.Errorf("Error watching %v: %v", event.Name, err)
+						continue
+					}
+					guilds[filepath.Base(event.Name)] = true
+				} else {
+					continue
+				}
+			case filepath.Dir(dir) == w.cacheFolder && cache.IsGuildFolder(filepath.Base(dir)):
+				guilds[filepath.Base(dir)] = true
+			default:
+				continue
+			}
+			debounce = time.After(Debounce)
+		case <-w.rescan:
+			uploaded = true
+			debounce = time.After(Debounce)
+		case <-debounce:
+			debounce = nil
+
+			for guildID := range guilds {
+				w.syncCache(guildID)
+			}
+			guilds = map[string]bool{}
+
+			if uploaded {
+				uploaded = false
+				w.extractUploads()
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Errorf("Folder watcher error: %v", err)
+		}
+	}
+}
+
+func (w *Watcher) syncCache(guildID string) {
+	c := cache.NewCache(w.uploadsFolder, w.cacheFolder, guildID, w.repos)
+
+	added, updated, removed, err := c.SyncCachedDir()
+	if err != nil {
+		if _, statErr := os.Stat(filepath.Join(w.cacheFolder, guildID)); statErr == nil {
+			w.notify(fmt.Sprintf("❗️ Error syncing the cache of guild `%v`\n\n*details:*\n`%v`", guildID, err))
+		}
+		return
+	}
+
+	if added+updated+removed > 0 {
+		w.notify(fmt.Sprintf("🗂 Cache of guild `%v` synced\n\nAdded: %d\nUpdated: %d\nRemoved: %d", guildID, added, updated, removed))
+	}
+}
+
+// extractUploads runs a single extraction job at a time, uploads arriving in
+// the meantime are extracted once it is over.
+func (w *Watcher) extractUploads() {
+	w.mu.Lock()
+	if w.extracting {
+		w.mu.Unlock()
+		select {
+		case w.rescan <- struct{}{}:
+		default:
+		}
+		return
+	}
+	w.extracting = true
+	w.mu.Unlock()
+
+	submitted := false
+	defer func() {
+		if !submitted {
+			w.setExtracting(false)
+		}
+	}()
+
+	guildID, err := w.uploadGuild()
+	if err != nil {
+		slog.Warnf("Uploaded files are not extracted: %v", err)
+		return
+	}
+
+	c := cache.NewCache(w.uploadsFolder, w.cacheFolder, guildID, w.repos)
+
+	videos, err := c.ListUploadedFiles()
+	if err != nil || len(videos) == 0 {
+		return
+	}
+
+	run := func(ctx context.Context, report jobs.Report) (string, error) {
+		stats, err := c.ExtractAudioFromVideo(ctx, report)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(stats, "\n"), nil
+	}
+
+	notify := func(job db.Job) {
+		switch job.Status {
+		case jobs.StatusQueued, jobs.StatusRunning:
+			return
+		case jobs.StatusDone:
+			if job.Result != "" {
+				w.notify(fmt.Sprintf("💽 Audio of uploaded videos added to the cache of guild `%v`\n\n%v", guildID, job.Result))
+			}
+		default:
+			w.notify(fmt.Sprintf("❗️ Extracting uploaded videos for guild `%v` did not finish\n\n%v", guildID, jobs.Describe(job)))
+		}
+
+		w.setExtracting(false)
+	}
+
+	if _, err := jobs.Shared(w.repos).Submit(guildID, jobs.KindExtract, w.uploadsFolder, run, notify); err != nil {
+		slog.Errorf("Error starting extraction of uploaded files: %v", err)
+		return
+	}
+	submitted = true
+}
+
+func (w *Watcher) setExtracting(extracting bool) {
+	w.mu.Lock()
+	w.extracting = extracting
+	w.mu.Unlock()
+}
+
+// uploadGuild returns the guild receiving the audio of uploaded videos: the
+// configured one, or the only guild the bot is registered in.
+func (w *Watcher) uploadGuild() (string, error) {
+	if w.uploadGuildID != "" {
+		return w.uploadGuildID, nil
+	}
+
+	ids, err := w.repos.Guilds.GetAllIDs()
+	if err != nil {
+		return "", fmt.Errorf("error getting guilds: %v", err)
+	}
+	if len(ids) != 1 {
+		return "", fmt.Errorf("watcher.upload_guild_id must be set when the bot is registered in %d guilds", len(ids))
+	}
+	return ids[0], nil
+}
@@ -0,0 +1,51 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keshon/melodix-player/internal/db"
+)
+
+func TestWatcherSyncsCache(t *testing.T) {
+	Debounce = 50 * time.Millisecond
+
+	dir := t.TempDir()
+	uploads := filepath.Join(dir, "upload")
+	cacheFolder := filepath.Join(dir, "cache")
+	repos := db.NewMemoryRepositories()
+
+	messages := make(chan string, 10)
+	w := newWatcher(uploads, cacheFolder, "guild", repos, func(message string) { messages <- message })
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// The guild folder is created after the watcher started
+	guildFolder := filepath.Join(cacheFolder, "guild")
+	if err := os.Mkdir(guildFolder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(guildFolder, "song.mp3"), []byte("audio"), 0644)
+	os.WriteFile(filepath.Join(guildFolder, "other.mp3"+".part"), []byte("audio"), 0644)
+
+	select {
+	case message := <-messages:
+		if !strings.Contains(message, "Added: 1") {
+			t.Errorf("unexpected summary: %v", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cache is not synced")
+	}
+
+	tracks, _ := repos.Tracks.GetAll()
+	if len(tracks) != 1 || tracks[0].Filepath != filepath.Join(guildFolder, "song.mp3") {
+		t.Errorf("unexpected tracks: %+v", tracks)
+	}
+}